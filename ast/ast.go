@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"strings"
 )
 
 type Node interface {
@@ -25,6 +26,13 @@ type Expression interface {
 	expressionNode()
 }
 
+// A TypeExpr is a type written in the source as an annotation, for example
+// the int in let x: int = 5
+type TypeExpr interface {
+	Node
+	typeExprNode()
+}
+
 type Program struct {
 	Statements []Statement
 }
@@ -54,6 +62,7 @@ func (p *Program) String() string {
 type LetStatement struct {
 	Token token.Token // e.g. the token.LET token
 	Name  *Identifier
+	Type  TypeExpr // nil unless written like let x: int = 5
 	Value Expression
 }
 
@@ -67,6 +76,9 @@ func (ls *LetStatement) String() string {
 	out.WriteString(ls.TokenLiteral() + " ")
 
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -173,3 +185,345 @@ func (ie *InfixExpression) String() string {
 
 	return out.String()
 }
+
+type StringStyle int
+
+// how a string literal was written in the source, so that tools printing it
+// back out can keep the same style
+const (
+	QuotedString  StringStyle = iota // "hello\n"
+	RawString                        // `no escapes`
+	HeredocString                    // <<~EOF ... EOF
+)
+
+type StringLiteral struct {
+	Token token.Token // token.STRING, token.RAW_STRING or token.HEREDOC
+	Value string
+	Style StringStyle
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string {
+	switch sl.Style {
+	case RawString:
+		return "`" + sl.Value + "`"
+	case HeredocString:
+		value := sl.Value
+		if value != "" && !strings.HasSuffix(value, "\n") {
+			value += "\n"
+		}
+		tag := heredocTag(value)
+		return "<<~" + tag + "\n" + value + tag
+	default:
+		return "\"" + escape(sl.Value) + "\""
+	}
+}
+
+// escape puts back the escapes the lexer understands, including \$ for a ${
+// that isn't the start of an interpolation
+func escape(s string) string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(s[i])
+		case '$':
+			if i+1 < len(s) && s[i+1] == '{' {
+				out.WriteByte('\\')
+			}
+			out.WriteByte(s[i])
+		case '\n':
+			out.WriteString("\\n")
+		case '\t':
+			out.WriteString("\\t")
+		case '\r':
+			out.WriteString("\\r")
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+
+	return out.String()
+}
+
+// heredocTag picks a tag that none of the lines of the heredoc start with
+func heredocTag(value string) string {
+	tag := "EOF"
+	for {
+		clash := false
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimLeft(line, " \t")
+			if strings.HasPrefix(line, tag) && (len(line) == len(tag) || !isLetter(line[len(tag)]) && !isDigit(line[len(tag)])) {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return tag
+		}
+		tag += "_"
+	}
+}
+
+// isLetter and isDigit match the characters the lexer allows in
+// identifiers, which can only start with a letter
+func isLetter(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// InterpolatedString is a string with expressions in it, like
+// "Hello ${name}". There is always one more literal than there are
+// expressions, with empty strings where expressions are next to each other
+type InterpolatedString struct {
+	Token       token.Token // the token.INTERP_START token
+	Literals    []string
+	Expressions []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for i, lit := range is.Literals {
+		out.WriteString(escape(lit))
+		if i < len(is.Expressions) {
+			out.WriteString("${")
+			out.WriteString(is.Expressions[i].String())
+			out.WriteString("}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
+type Boolean struct {
+	Token token.Token // token.TRUE or token.FALSE
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) String() string       { return b.Token.Literal }
+
+// a block is the statements between { and }, as used by if and fn
+type BlockStatement struct {
+	Token      token.Token // the { token
+	Statements []Statement
+	Close      token.Token // the } token
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	for _, s := range bs.Statements {
+		out.WriteString(s.String())
+	}
+
+	return out.String()
+}
+
+// Alternative is nil when there is no else
+type IfExpression struct {
+	Token       token.Token // the if token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) expressionNode()      {}
+func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if")
+	out.WriteString(ie.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ie.Consequence.String())
+
+	if ie.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(ie.Alternative.String())
+	}
+
+	return out.String()
+}
+
+type FunctionLiteral struct {
+	Token      token.Token // the fn token
+	Parameters []*Identifier
+	// ParameterTypes is nil when none of the parameters have a type written
+	// for them, otherwise it has one entry per parameter which is nil for
+	// the ones without
+	ParameterTypes []TypeExpr
+	ReturnType     TypeExpr // nil unless written like fn(x) -> int { x }
+	Body           *BlockStatement
+}
+
+// ParameterType returns the type written for parameter i, or nil
+func (fl *FunctionLiteral) ParameterType(i int) TypeExpr {
+	if i < len(fl.ParameterTypes) {
+		return fl.ParameterTypes[i]
+	}
+	return nil
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for i, p := range fl.Parameters {
+		param := p.String()
+		if t := fl.ParameterType(i); t != nil {
+			param += ": " + t.String()
+		}
+		params = append(params, param)
+	}
+
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
+
+// Function is either an Identifier or a FunctionLiteral, as both
+// add(1, 2) and fn(a, b) { a + b }(1, 2) are calls
+type CallExpression struct {
+	Token     token.Token // the ( token
+	Function  Expression
+	Arguments []Expression
+	Close     token.Token // the ) token
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // the [ token
+	Elements []Expression
+	Close    token.Token // the ] token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token // the [ token
+	Left  Expression
+	Index Expression
+	Close token.Token // the ] token
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// NamedType is a type written as a name, like int or string
+type NamedType struct {
+	Token token.Token // the token.IDENT token
+	Name  string
+}
+
+func (nt *NamedType) typeExprNode()        {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType is written [int]
+type ArrayType struct {
+	Token   token.Token // the [ token
+	Element TypeExpr
+	Close   token.Token // the ] token
+}
+
+func (at *ArrayType) typeExprNode()        {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+// HashType is written {string: int}
+type HashType struct {
+	Token token.Token // the { token
+	Key   TypeExpr
+	Value TypeExpr
+	Close token.Token // the } token
+}
+
+func (ht *HashType) typeExprNode()        {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType is written fn(int, int) -> bool
+type FunctionType struct {
+	Token      token.Token // the fn token
+	Parameters []TypeExpr
+	Result     TypeExpr
+}
+
+func (ft *FunctionType) typeExprNode()        {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, p := range ft.Parameters {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + ft.Result.String()
+}
@@ -26,3 +26,38 @@ func TestString(t *testing.T) {
 		t.Errorf("program String wrong. got%q", program.String())
 	}
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		literal  *StringLiteral
+		expected string
+	}{
+		{&StringLiteral{Value: "say \"hi\"\n", Style: QuotedString}, `"say \"hi\"\n"`},
+		{&StringLiteral{Value: "a\\b", Style: RawString}, "`a\\b`"},
+		{&StringLiteral{Value: "select 1\n", Style: HeredocString}, "<<~EOF\nselect 1\nEOF"},
+		{&StringLiteral{Value: "EOF\n", Style: HeredocString}, "<<~EOF_\nEOF\nEOF_"},
+		{&StringLiteral{Value: "EOF;\nEOFS\n", Style: HeredocString}, "<<~EOF_\nEOF;\nEOFS\nEOF_"},
+		// digits carry the word on, so EOF1 doesn't end EOF
+		{&StringLiteral{Value: "EOF1\n", Style: HeredocString}, "<<~EOF\nEOF1\nEOF"},
+	}
+
+	for _, tt := range tests {
+		if tt.literal.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, tt.literal.String())
+		}
+	}
+}
+
+func TestInterpolatedStringString(t *testing.T) {
+	str := &InterpolatedString{
+		Literals: []string{"cost: $", " \"${literal}\""},
+		Expressions: []Expression{
+			&Identifier{Token: token.Token{Type: token.IDENT, Literal: "price"}, Value: "price"},
+		},
+	}
+
+	expected := `"cost: $${price} \"\${literal}\""`
+	if str.String() != expected {
+		t.Errorf("expected=%q, got=%q", expected, str.String())
+	}
+}
@@ -0,0 +1,143 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"monkey/ast"
+)
+
+// The dump package draws trees so it is easier to see what the parser made
+// of a program than with the brackets String() puts in. Tree gives an
+// indented drawing for the terminal and Dot gives a Graphviz graph
+
+// child is a node held by another node along with the field it is held in
+type child struct {
+	field string
+	node  ast.Node
+}
+
+// Tree draws node and everything under it like the tree command does
+//
+//	Program
+//	└── Statements[0]: ExpressionStatement
+//	    └── Expression: InfixExpression +
+//	        ├── Left: Identifier a
+//	        └── Right: IntegerLiteral 1
+func Tree(node ast.Node) string {
+	var out bytes.Buffer
+
+	out.WriteString(label(node))
+	out.WriteString("\n")
+	writeChildren(&out, node, "")
+
+	return out.String()
+}
+
+func writeChildren(out *bytes.Buffer, node ast.Node, indent string) {
+	kids := children(node)
+
+	for i, c := range kids {
+		branch, next := "├── ", "│   "
+		if i == len(kids)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		out.WriteString(indent + branch + c.field + ": " + label(c.node) + "\n")
+		writeChildren(out, c.node, indent+next)
+	}
+}
+
+// Dot gives node as a Graphviz digraph, with the field names on the edges
+func Dot(node ast.Node) string {
+	var out bytes.Buffer
+
+	out.WriteString("digraph ast {\n")
+	out.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+
+	next := 0
+	var write func(n ast.Node) int
+	write = func(n ast.Node) int {
+		id := next
+		next++
+		fmt.Fprintf(&out, "\tn%d [label=%s];\n", id, quote(label(n)))
+
+		for _, c := range children(n) {
+			childID := write(c.node)
+			fmt.Fprintf(&out, "\tn%d -> n%d [label=%s];\n", id, childID, quote(c.field))
+		}
+		return id
+	}
+	write(node)
+
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// quote makes a string safe to use as a label in dot
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// label is the type of the node with the parts of it that aren't nodes
+func label(node ast.Node) string {
+	name := reflect.TypeOf(node).Elem().Name()
+
+	switch n := node.(type) {
+	case *ast.Identifier:
+		return name + " " + n.Value
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("%s %d", name, n.Value)
+	case *ast.StringLiteral:
+		// quoted whatever way it was written, so raw strings and heredocs
+		// don't put newlines in the middle of a drawing
+		return name + " " + strconv.Quote(n.Value)
+	case *ast.PrefixExpression:
+		return name + " " + n.Operator
+	case *ast.InfixExpression:
+		return name + " " + n.Operator
+	case *ast.InterpolatedString:
+		return fmt.Sprintf("%s %q", name, n.Literals)
+	case *ast.Boolean:
+		return fmt.Sprintf("%s %t", name, n.Value)
+	case *ast.NamedType:
+		return name + " " + n.Name
+	}
+
+	return name
+}
+
+// children finds the nodes held by node using reflection, so that new node
+// types can be drawn without changing this package
+func children(node ast.Node) []child {
+	kids := []child{}
+	nodeType := reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+	v := reflect.ValueOf(node).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		name := v.Type().Field(i).Name
+
+		switch {
+		case f.Type().Implements(nodeType):
+			if !f.IsNil() {
+				kids = append(kids, child{field: name, node: f.Interface().(ast.Node)})
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Implements(nodeType):
+			for j := 0; j < f.Len(); j++ {
+				if !f.Index(j).IsNil() {
+					field := fmt.Sprintf("%s[%d]", name, j)
+					kids = append(kids, child{field: field, node: f.Index(j).Interface().(ast.Node)})
+				}
+			}
+		}
+	}
+
+	return kids
+}
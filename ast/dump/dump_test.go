@@ -0,0 +1,72 @@
+package dump
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestTree(t *testing.T) {
+	program := parser.New(lexer.New(`let x = -a + 2 * "s"; return "n${x}";`)).ParseProgram()
+
+	expected := `Program
+├── Statements[0]: LetStatement
+│   ├── Name: Identifier x
+│   └── Value: InfixExpression +
+│       ├── Left: PrefixExpression -
+│       │   └── Right: Identifier a
+│       └── Right: InfixExpression *
+│           ├── Left: IntegerLiteral 2
+│           └── Right: StringLiteral "s"
+└── Statements[1]: ReturnStatement
+    └── ReturnValue: InterpolatedString ["n" ""]
+        └── Expressions[0]: Identifier x
+`
+
+	if Tree(program) != expected {
+		t.Errorf("tree wrong.\nexpected=\n%s\ngot=\n%s", expected, Tree(program))
+	}
+}
+
+func TestDot(t *testing.T) {
+	program := parser.New(lexer.New(`a + "q\"";`)).ParseProgram()
+
+	expected := `digraph ast {
+	node [shape=box, fontname="monospace"];
+	n0 [label="Program"];
+	n1 [label="ExpressionStatement"];
+	n2 [label="InfixExpression +"];
+	n3 [label="Identifier a"];
+	n2 -> n3 [label="Left"];
+	n4 [label="StringLiteral \"q\\\"\""];
+	n2 -> n4 [label="Right"];
+	n1 -> n2 [label="Expression"];
+	n0 -> n1 [label="Statements[0]"];
+}
+`
+
+	if Dot(program) != expected {
+		t.Errorf("dot wrong.\nexpected=\n%s\ngot=\n%s", expected, Dot(program))
+	}
+}
+
+func TestStringLabels(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\tb"`, `StringLiteral "a\tb"`},
+		{"`a\nb`", `StringLiteral "a\nb"`},
+		{"<<~T\n  x\n  y\n  T\n", `StringLiteral "x\ny\n"`},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		node := program.Statements[0].(*ast.ExpressionStatement).Expression
+		if label(node) != tt.expected {
+			t.Errorf("%q: wrong label. expected=%q, got=%q", tt.input, tt.expected, label(node))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package ast
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"reflect"
+
+	"monkey/token"
+)
+
+// Equal, Clone and Hash look at every field of a node with reflection, so
+// they keep working as node types are added without needing to be updated
+
+var tokenType = reflect.TypeOf(token.Token{})
+
+type EqualOptions struct {
+	// IgnorePositions compares tokens by type and literal only, so the same
+	// code parsed from different places is equal
+	IgnorePositions bool
+}
+
+// Equal reports whether two trees are the same, all the way down. Nil
+// slices count as equal to empty ones
+func Equal(a, b Node, opts EqualOptions) bool {
+	return equal(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem(), opts)
+}
+
+func equal(a, b reflect.Value, opts EqualOptions) bool {
+	switch a.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() && b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return equal(a.Elem(), b.Elem(), opts)
+
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i), opts) {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Struct:
+		if a.Type() == tokenType && opts.IgnorePositions {
+			at, bt := a.Interface().(token.Token), b.Interface().(token.Token)
+			return at.Type == bt.Type && at.Literal == bt.Literal
+		}
+		for i := 0; i < a.NumField(); i++ {
+			if !equal(a.Field(i), b.Field(i), opts) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return a.Equal(b)
+}
+
+// Clone makes a deep copy of a node which shares nothing with the original
+func Clone[T Node](node T) T {
+	v := reflect.ValueOf(&node).Elem()
+	return clone(v).Interface().(T)
+}
+
+func clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		c := reflect.New(v.Type()).Elem()
+		if !v.IsNil() {
+			c.Set(clone(v.Elem()))
+		}
+		return c
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(clone(v.Elem()))
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(clone(v.Index(i)))
+		}
+		return c
+
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			c.Field(i).Set(clone(v.Field(i)))
+		}
+		return c
+	}
+
+	return v
+}
+
+// Hash gives a hash of the structure of a node, leaving out positions, so
+// nodes that are Equal when ignoring positions have the same hash
+func Hash(node Node) uint64 {
+	h := fnv.New64a()
+	hash(h, reflect.ValueOf(&node).Elem())
+	return h.Sum64()
+}
+
+func hash(w io.Writer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			io.WriteString(w, "nil;")
+			return
+		}
+		if v.Kind() == reflect.Interface {
+			io.WriteString(w, v.Elem().Type().String()+";")
+		}
+		hash(w, v.Elem())
+
+	case reflect.Slice:
+		binary.Write(w, binary.LittleEndian, int64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			hash(w, v.Index(i))
+		}
+
+	case reflect.Struct:
+		if v.Type() == tokenType {
+			tok := v.Interface().(token.Token)
+			io.WriteString(w, string(tok.Type)+";"+tok.Literal+";")
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			hash(w, v.Field(i))
+		}
+
+	case reflect.String:
+		binary.Write(w, binary.LittleEndian, int64(v.Len()))
+		io.WriteString(w, v.String())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		binary.Write(w, binary.LittleEndian, v.Int())
+
+	case reflect.Bool:
+		binary.Write(w, binary.LittleEndian, v.Bool())
+
+	case reflect.Float32, reflect.Float64:
+		binary.Write(w, binary.LittleEndian, math.Float64bits(v.Float()))
+	}
+}
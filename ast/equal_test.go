@@ -0,0 +1,101 @@
+package ast
+
+import (
+	"testing"
+
+	mtoken "monkey/token"
+)
+
+// at returns a copy of the identifier with its token moved to offset
+func at(i *Identifier, offset int) *Identifier {
+	c := Clone(i)
+	c.Token.Pos = mtoken.Position{Offset: offset, Line: 1, Column: offset + 1}
+	c.Token.End = mtoken.Position{Offset: offset + len(i.Value), Line: 1, Column: offset + len(i.Value) + 1}
+	return c
+}
+
+func TestEqual(t *testing.T) {
+	nodes := allNodes()
+	for i, n := range nodes {
+		if !Equal(n, Clone(n), EqualOptions{}) {
+			t.Errorf("%T is not equal to its clone", n)
+		}
+		for j, m := range nodes {
+			if i != j && Equal(n, m, EqualOptions{}) {
+				t.Errorf("%T should not equal %T", n, m)
+			}
+		}
+	}
+
+	a := &InfixExpression{Left: at(ident("a"), 0), Operator: "+", Right: at(ident("b"), 4)}
+	b := &InfixExpression{Left: at(ident("a"), 10), Operator: "+", Right: at(ident("b"), 14)}
+	c := &InfixExpression{Left: at(ident("a"), 0), Operator: "-", Right: at(ident("b"), 4)}
+
+	if Equal(a, b, EqualOptions{}) {
+		t.Errorf("nodes at different positions should not be equal")
+	}
+	if !Equal(a, b, EqualOptions{IgnorePositions: true}) {
+		t.Errorf("nodes at different positions should be equal when ignoring positions")
+	}
+	if Equal(a, c, EqualOptions{IgnorePositions: true}) {
+		t.Errorf("nodes with different operators should not be equal")
+	}
+	if !Equal(nil, nil, EqualOptions{}) || Equal(a, nil, EqualOptions{}) {
+		t.Errorf("nil handling wrong")
+	}
+	if !Equal(&Program{}, &Program{Statements: []Statement{}}, EqualOptions{}) {
+		t.Errorf("nil and empty statements should be equal")
+	}
+}
+
+func TestClone(t *testing.T) {
+	original := &LetStatement{
+		Token: mtoken.Token{Type: mtoken.LET, Literal: "let"},
+		Name:  ident("x"),
+		Value: &InterpolatedString{
+			Literals:    []string{"a", "b"},
+			Expressions: []Expression{ident("y")},
+		},
+	}
+
+	c := Clone(original)
+	if c == original || c.Name == original.Name || c.Value == original.Value {
+		t.Fatalf("clone shares nodes with the original")
+	}
+
+	c.Name.Value = "changed"
+	str := c.Value.(*InterpolatedString)
+	str.Literals[0] = "changed"
+	str.Expressions[0].(*Identifier).Value = "changed"
+
+	if original.String() != `let x = "a${y}b";` {
+		t.Errorf("changing the clone changed the original. got=%q", original.String())
+	}
+}
+
+func TestHash(t *testing.T) {
+	a := &InfixExpression{Left: at(ident("a"), 0), Operator: "+", Right: at(ident("b"), 4)}
+	b := &InfixExpression{Left: at(ident("a"), 10), Operator: "+", Right: at(ident("b"), 14)}
+	if Hash(a) != Hash(b) {
+		t.Errorf("hash should not depend on positions")
+	}
+
+	seen := map[uint64]Node{}
+	for _, n := range allNodes() {
+		h := Hash(n)
+		if other, ok := seen[h]; ok {
+			t.Errorf("%T and %T have the same hash", n, other)
+		}
+		seen[h] = n
+
+		if Hash(Clone(n)) != h {
+			t.Errorf("%T hashes differently to its clone", n)
+		}
+	}
+
+	// the same identifiers in a different shape should hash differently
+	swapped := &InfixExpression{Left: ident("b"), Operator: "+", Right: ident("a")}
+	if Hash(swapped) == Hash(a) {
+		t.Errorf("a + b and b + a should have different hashes")
+	}
+}
@@ -0,0 +1,499 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"monkey/token"
+)
+
+// MarshalJSON and UnmarshalJSON convert trees to and from JSON so tools
+// written in other languages can use them. Every node is an object with a
+// "type" naming the node, and apart from Program each one has
+//
+//	"token": {"type": "LET", "literal": "let", "start": pos, "end": pos}
+//	"span":  {"start": pos, "end": pos}
+//
+// where pos is {"offset": 0, "line": 1, "column": 1}. The token is the one
+// held by the node and the span runs from the start of the first token in
+// the node to the end of the last one, including its children. Blocks,
+// calls, arrays, indexes and array and hash types also have the bracket or
+// brace that closes them as "close", which is a token too. The other
+// fields depend on the type:
+//
+//	Program             "statements": [node]
+//	LetStatement        "name": Identifier, "typeAnnotation": type,
+//	                    "value": node
+//	ReturnStatement     "returnValue": node
+//	ExpressionStatement "expression": node
+//	Identifier          "value": string
+//	IntegerLiteral      "value": number
+//	StringLiteral       "value": string, "style": "quoted" | "raw" | "heredoc"
+//	PrefixExpression    "operator": string, "right": node
+//	InfixExpression     "left": node, "operator": string, "right": node
+//	InterpolatedString  "literals": [string], "expressions": [node]
+//	Boolean             "value": bool
+//	BlockStatement      "statements": [node]
+//	IfExpression        "condition": node, "consequence": BlockStatement,
+//	                    "alternative": BlockStatement
+//	FunctionLiteral     "parameters": [Identifier], "parameterTypes": [type],
+//	                    "returnType": type, "body": BlockStatement
+//	CallExpression      "function": node, "arguments": [node]
+//	ArrayLiteral        "elements": [node]
+//	IndexExpression     "left": node, "index": node
+//	NamedType           "value": string
+//	ArrayType           "element": type
+//	HashType            "key": type, "value": type
+//	FunctionType        "parameters": [type], "result": type
+//
+// where type is one of the last four. Children the parser couldn't fill in
+// because of errors are left out, or are null inside a list, and so are
+// parameters without a type
+
+type jsonNode struct {
+	Type        string          `json:"type"`
+	Token       *jsonToken      `json:"token,omitempty"`
+	Close       *jsonToken      `json:"close,omitempty"`
+	Span        *jsonSpan       `json:"span,omitempty"`
+	Statements  []*jsonNode     `json:"statements,omitempty"`
+	Name        *jsonNode       `json:"name,omitempty"`
+	ReturnValue *jsonNode       `json:"returnValue,omitempty"`
+	Expression  *jsonNode       `json:"expression,omitempty"`
+	Left        *jsonNode       `json:"left,omitempty"`
+	Operator    string          `json:"operator,omitempty"`
+	Right       *jsonNode       `json:"right,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Style       string          `json:"style,omitempty"`
+	Literals    []string        `json:"literals,omitempty"`
+	Expressions []*jsonNode     `json:"expressions,omitempty"`
+	Condition   *jsonNode       `json:"condition,omitempty"`
+	Consequence *jsonNode       `json:"consequence,omitempty"`
+	Alternative *jsonNode       `json:"alternative,omitempty"`
+	Parameters  []*jsonNode     `json:"parameters,omitempty"`
+	Body        *jsonNode       `json:"body,omitempty"`
+	Function    *jsonNode       `json:"function,omitempty"`
+	Arguments   []*jsonNode     `json:"arguments,omitempty"`
+	Elements    []*jsonNode     `json:"elements,omitempty"`
+	Index       *jsonNode       `json:"index,omitempty"`
+
+	TypeAnnotation *jsonNode   `json:"typeAnnotation,omitempty"`
+	ParameterTypes []*jsonNode `json:"parameterTypes,omitempty"`
+	ReturnType     *jsonNode   `json:"returnType,omitempty"`
+	Element        *jsonNode   `json:"element,omitempty"`
+	Key            *jsonNode   `json:"key,omitempty"`
+	Result         *jsonNode   `json:"result,omitempty"`
+}
+
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Start   jsonPos         `json:"start"`
+	End     jsonPos         `json:"end"`
+}
+
+type jsonSpan struct {
+	Start jsonPos `json:"start"`
+	End   jsonPos `json:"end"`
+}
+
+type jsonPos struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+var stringStyles = map[StringStyle]string{
+	QuotedString:  "quoted",
+	RawString:     "raw",
+	HeredocString: "heredoc",
+}
+
+func MarshalJSON(node Node) ([]byte, error) {
+	all := map[Node]span{}
+	if node != nil && !reflect.ValueOf(node).IsNil() {
+		spans(node, all)
+	}
+	jn, err := toJSON(node, all)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jn)
+}
+
+func UnmarshalJSON(data []byte) (Node, error) {
+	var jn jsonNode
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return nil, err
+	}
+	return fromJSON(&jn)
+}
+
+// toJSON converts node, taking the spans from spans so they aren't worked
+// out again for each node
+func toJSON(node Node, spans map[Node]span) (*jsonNode, error) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return nil, nil
+	}
+
+	jn := &jsonNode{Type: reflect.TypeOf(node).Elem().Name()}
+	if tok, ok := nodeToken(node); ok {
+		jn.Token = toJSONToken(tok)
+	}
+	if tok, ok := closeToken(node); ok {
+		jn.Close = toJSONToken(tok)
+	}
+	if s := spans[node]; s.found {
+		jn.Span = &jsonSpan{Start: jsonPos(s.start), End: jsonPos(s.end)}
+	}
+
+	var err error
+	switch n := node.(type) {
+	case *Program:
+		jn.Statements, err = toJSONList(n.Statements, spans)
+	case *LetStatement:
+		if jn.Name, err = toJSON(n.Name, spans); err != nil {
+			return nil, err
+		}
+		if jn.TypeAnnotation, err = toJSON(n.Type, spans); err != nil {
+			return nil, err
+		}
+		if jn.Value, err = rawNode(n.Value, spans); err != nil {
+			return nil, err
+		}
+	case *ReturnStatement:
+		jn.ReturnValue, err = toJSON(n.ReturnValue, spans)
+	case *ExpressionStatement:
+		jn.Expression, err = toJSON(n.Expression, spans)
+	case *Identifier:
+		jn.Value, err = json.Marshal(n.Value)
+	case *IntegerLiteral:
+		jn.Value, err = json.Marshal(n.Value)
+	case *StringLiteral:
+		jn.Style = stringStyles[n.Style]
+		jn.Value, err = json.Marshal(n.Value)
+	case *PrefixExpression:
+		jn.Operator = n.Operator
+		jn.Right, err = toJSON(n.Right, spans)
+	case *InfixExpression:
+		jn.Operator = n.Operator
+		if jn.Left, err = toJSON(n.Left, spans); err != nil {
+			return nil, err
+		}
+		jn.Right, err = toJSON(n.Right, spans)
+	case *InterpolatedString:
+		jn.Literals = n.Literals
+		jn.Expressions, err = toJSONList(n.Expressions, spans)
+	case *Boolean:
+		jn.Value, err = json.Marshal(n.Value)
+	case *BlockStatement:
+		jn.Statements, err = toJSONList(n.Statements, spans)
+	case *IfExpression:
+		if jn.Condition, err = toJSON(n.Condition, spans); err != nil {
+			return nil, err
+		}
+		if jn.Consequence, err = toJSON(n.Consequence, spans); err != nil {
+			return nil, err
+		}
+		jn.Alternative, err = toJSON(n.Alternative, spans)
+	case *FunctionLiteral:
+		if jn.Parameters, err = toJSONList(n.Parameters, spans); err != nil {
+			return nil, err
+		}
+		if jn.ParameterTypes, err = toJSONList(n.ParameterTypes, spans); err != nil {
+			return nil, err
+		}
+		if jn.ReturnType, err = toJSON(n.ReturnType, spans); err != nil {
+			return nil, err
+		}
+		jn.Body, err = toJSON(n.Body, spans)
+	case *CallExpression:
+		if jn.Function, err = toJSON(n.Function, spans); err != nil {
+			return nil, err
+		}
+		jn.Arguments, err = toJSONList(n.Arguments, spans)
+	case *ArrayLiteral:
+		jn.Elements, err = toJSONList(n.Elements, spans)
+	case *IndexExpression:
+		if jn.Left, err = toJSON(n.Left, spans); err != nil {
+			return nil, err
+		}
+		jn.Index, err = toJSON(n.Index, spans)
+	case *NamedType:
+		jn.Value, err = json.Marshal(n.Name)
+	case *ArrayType:
+		jn.Element, err = toJSON(n.Element, spans)
+	case *HashType:
+		if jn.Key, err = toJSON(n.Key, spans); err != nil {
+			return nil, err
+		}
+		jn.Value, err = rawNode(n.Value, spans)
+	case *FunctionType:
+		if jn.Parameters, err = toJSONList(n.Parameters, spans); err != nil {
+			return nil, err
+		}
+		jn.Result, err = toJSON(n.Result, spans)
+	default:
+		return nil, fmt.Errorf("ast.MarshalJSON: unexpected node type %T", n)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return jn, nil
+}
+
+func toJSONToken(tok token.Token) *jsonToken {
+	return &jsonToken{
+		Type:    tok.Type,
+		Literal: tok.Literal,
+		Start:   jsonPos(tok.Pos),
+		End:     jsonPos(tok.End),
+	}
+}
+
+func toJSONList[T Node](nodes []T, spans map[Node]span) ([]*jsonNode, error) {
+	var list []*jsonNode
+	for _, n := range nodes {
+		jn, err := toJSON(n, spans)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, jn)
+	}
+	return list, nil
+}
+
+// rawNode is for the fields holding a node that share their name with
+// fields holding plain values
+func rawNode(node Node, spans map[Node]span) (json.RawMessage, error) {
+	jn, err := toJSON(node, spans)
+	if err != nil || jn == nil {
+		return nil, err
+	}
+	return json.Marshal(jn)
+}
+
+func fromJSON(jn *jsonNode) (Node, error) {
+	if jn == nil {
+		return nil, nil
+	}
+
+	tok, closing := fromJSONToken(jn.Token), fromJSONToken(jn.Close)
+
+	switch jn.Type {
+	case "Program":
+		statements, err := fromJSONList[Statement](jn.Statements)
+		if statements == nil {
+			statements = []Statement{}
+		}
+		return &Program{Statements: statements}, err
+
+	case "LetStatement":
+		stmt := &LetStatement{Token: tok}
+		var err error
+		if stmt.Name, err = fromJSONAs[*Identifier](jn.Name); err != nil {
+			return nil, err
+		}
+		if stmt.Type, err = fromJSONAs[TypeExpr](jn.TypeAnnotation); err != nil {
+			return nil, err
+		}
+		stmt.Value, err = fromRawNode[Expression](jn.Value)
+		return stmt, err
+
+	case "ReturnStatement":
+		value, err := fromJSONAs[Expression](jn.ReturnValue)
+		return &ReturnStatement{Token: tok, ReturnValue: value}, err
+
+	case "ExpressionStatement":
+		exp, err := fromJSONAs[Expression](jn.Expression)
+		return &ExpressionStatement{Token: tok, Expression: exp}, err
+
+	case "Identifier":
+		ident := &Identifier{Token: tok}
+		return ident, json.Unmarshal(jn.Value, &ident.Value)
+
+	case "IntegerLiteral":
+		lit := &IntegerLiteral{Token: tok}
+		return lit, json.Unmarshal(jn.Value, &lit.Value)
+
+	case "StringLiteral":
+		lit := &StringLiteral{Token: tok}
+		found := false
+		for style, name := range stringStyles {
+			if name == jn.Style {
+				lit.Style = style
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("ast.UnmarshalJSON: unknown string style %q", jn.Style)
+		}
+		return lit, json.Unmarshal(jn.Value, &lit.Value)
+
+	case "PrefixExpression":
+		right, err := fromJSONAs[Expression](jn.Right)
+		return &PrefixExpression{Token: tok, Operator: jn.Operator, Right: right}, err
+
+	case "InfixExpression":
+		left, err := fromJSONAs[Expression](jn.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := fromJSONAs[Expression](jn.Right)
+		return &InfixExpression{Token: tok, Left: left, Operator: jn.Operator, Right: right}, err
+
+	case "InterpolatedString":
+		exps, err := fromJSONList[Expression](jn.Expressions)
+		return &InterpolatedString{Token: tok, Literals: jn.Literals, Expressions: exps}, err
+
+	case "Boolean":
+		b := &Boolean{Token: tok}
+		return b, json.Unmarshal(jn.Value, &b.Value)
+
+	case "BlockStatement":
+		statements, err := fromJSONList[Statement](jn.Statements)
+		if statements == nil {
+			statements = []Statement{}
+		}
+		return &BlockStatement{Token: tok, Statements: statements, Close: closing}, err
+
+	case "IfExpression":
+		exp := &IfExpression{Token: tok}
+		var err error
+		if exp.Condition, err = fromJSONAs[Expression](jn.Condition); err != nil {
+			return nil, err
+		}
+		if exp.Consequence, err = fromJSONAs[*BlockStatement](jn.Consequence); err != nil {
+			return nil, err
+		}
+		exp.Alternative, err = fromJSONAs[*BlockStatement](jn.Alternative)
+		return exp, err
+
+	case "FunctionLiteral":
+		params, err := fromJSONList[*Identifier](jn.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		if params == nil {
+			params = []*Identifier{}
+		}
+		fn := &FunctionLiteral{Token: tok, Parameters: params}
+		if fn.ParameterTypes, err = fromJSONList[TypeExpr](jn.ParameterTypes); err != nil {
+			return nil, err
+		}
+		if fn.ReturnType, err = fromJSONAs[TypeExpr](jn.ReturnType); err != nil {
+			return nil, err
+		}
+		fn.Body, err = fromJSONAs[*BlockStatement](jn.Body)
+		return fn, err
+
+	case "CallExpression":
+		function, err := fromJSONAs[Expression](jn.Function)
+		if err != nil {
+			return nil, err
+		}
+		args, err := fromJSONList[Expression](jn.Arguments)
+		if args == nil {
+			args = []Expression{}
+		}
+		return &CallExpression{Token: tok, Function: function, Arguments: args, Close: closing}, err
+
+	case "ArrayLiteral":
+		elements, err := fromJSONList[Expression](jn.Elements)
+		if elements == nil {
+			elements = []Expression{}
+		}
+		return &ArrayLiteral{Token: tok, Elements: elements, Close: closing}, err
+
+	case "IndexExpression":
+		left, err := fromJSONAs[Expression](jn.Left)
+		if err != nil {
+			return nil, err
+		}
+		index, err := fromJSONAs[Expression](jn.Index)
+		return &IndexExpression{Token: tok, Left: left, Index: index, Close: closing}, err
+
+	case "NamedType":
+		t := &NamedType{Token: tok}
+		return t, json.Unmarshal(jn.Value, &t.Name)
+
+	case "ArrayType":
+		elem, err := fromJSONAs[TypeExpr](jn.Element)
+		return &ArrayType{Token: tok, Element: elem, Close: closing}, err
+
+	case "HashType":
+		key, err := fromJSONAs[TypeExpr](jn.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := fromRawNode[TypeExpr](jn.Value)
+		return &HashType{Token: tok, Key: key, Value: value, Close: closing}, err
+
+	case "FunctionType":
+		params, err := fromJSONList[TypeExpr](jn.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		if params == nil {
+			params = []TypeExpr{}
+		}
+		result, err := fromJSONAs[TypeExpr](jn.Result)
+		return &FunctionType{Token: tok, Parameters: params, Result: result}, err
+	}
+
+	return nil, fmt.Errorf("ast.UnmarshalJSON: unknown node type %q", jn.Type)
+}
+
+// fromJSONAs converts a node and checks it can go where it is being put
+func fromJSONAs[T Node](jn *jsonNode) (T, error) {
+	var zero T
+
+	node, err := fromJSON(jn)
+	if err != nil || node == nil {
+		return zero, err
+	}
+	t, ok := node.(T)
+	if !ok {
+		return zero, fmt.Errorf("ast.UnmarshalJSON: %s can't be used as %s", jn.Type, reflect.TypeFor[T]())
+	}
+	return t, nil
+}
+
+// fromRawNode is the other half of rawNode
+func fromRawNode[T Node](raw json.RawMessage) (T, error) {
+	var zero T
+	if raw == nil {
+		return zero, nil
+	}
+
+	var jn jsonNode
+	if err := json.Unmarshal(raw, &jn); err != nil {
+		return zero, err
+	}
+	return fromJSONAs[T](&jn)
+}
+
+func fromJSONToken(jt *jsonToken) token.Token {
+	if jt == nil {
+		return token.Token{}
+	}
+	return token.Token{
+		Type:    jt.Type,
+		Literal: jt.Literal,
+		Pos:     token.Position(jt.Start),
+		End:     token.Position(jt.End),
+	}
+}
+
+func fromJSONList[T Node](list []*jsonNode) ([]T, error) {
+	var nodes []T
+	for _, jn := range list {
+		n, err := fromJSONAs[T](jn)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
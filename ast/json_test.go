@@ -0,0 +1,109 @@
+package ast
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	mtoken "monkey/token"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, n := range allNodes() {
+		data, err := MarshalJSON(n)
+		if err != nil {
+			t.Fatalf("MarshalJSON(%T) failed: %s", n, err)
+		}
+
+		back, err := UnmarshalJSON(data)
+		if err != nil {
+			t.Fatalf("UnmarshalJSON(%s) failed: %s", data, err)
+		}
+
+		if !reflect.DeepEqual(n, back) {
+			t.Errorf("%T did not round trip. got=%s", n, back.String())
+		}
+	}
+}
+
+func TestJSONSpans(t *testing.T) {
+	// f([x]) with the closing brackets last
+	at := func(offset int, typ mtoken.TokenType, literal string) mtoken.Token {
+		return mtoken.Token{Type: typ, Literal: literal,
+			Pos: mtoken.Position{Offset: offset, Line: 1, Column: offset + 1},
+			End: mtoken.Position{Offset: offset + 1, Line: 1, Column: offset + 2}}
+	}
+	array := &ArrayLiteral{
+		Token:    at(2, mtoken.LSQUARE, "["),
+		Elements: []Expression{&Identifier{Token: at(3, mtoken.IDENT, "x"), Value: "x"}},
+		Close:    at(4, mtoken.RSQUARE, "]"),
+	}
+	call := &CallExpression{
+		Token:     at(1, mtoken.LBRACKET, "("),
+		Function:  &Identifier{Token: at(0, mtoken.IDENT, "f"), Value: "f"},
+		Arguments: []Expression{array},
+		Close:     at(5, mtoken.RBRACKET, ")"),
+	}
+
+	data, err := MarshalJSON(call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jn jsonNode
+	if err := json.Unmarshal(data, &jn); err != nil {
+		t.Fatal(err)
+	}
+	if jn.Span.Start.Offset != 0 || jn.Span.End.Offset != 6 {
+		t.Errorf("wrong span for the call. got=%+v", jn.Span)
+	}
+	if span := jn.Arguments[0].Span; span.Start.Offset != 2 || span.End.Offset != 5 {
+		t.Errorf("wrong span for the array. got=%+v", span)
+	}
+	if jn.Close == nil || jn.Close.Literal != ")" {
+		t.Errorf("expected the closing token. got=%+v", jn.Close)
+	}
+}
+
+func TestJSONMissingChildren(t *testing.T) {
+	stmt := &LetStatement{Name: ident("x")}
+
+	data, err := MarshalJSON(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["value"]; ok {
+		t.Errorf("missing value should be left out. got=%s", data)
+	}
+
+	back, err := UnmarshalJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.(*LetStatement).Value != nil {
+		t.Errorf("missing value should stay nil")
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"type": "Nonsense"}`, `ast.UnmarshalJSON: unknown node type "Nonsense"`},
+		{`{"type": "ExpressionStatement", "expression": {"type": "Program"}}`,
+			"ast.UnmarshalJSON: Program can't be used as ast.Expression"},
+		{`{"type": "StringLiteral", "value": "a", "style": "fancy"}`,
+			`ast.UnmarshalJSON: unknown string style "fancy"`},
+	}
+
+	for _, tt := range tests {
+		_, err := UnmarshalJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("expected error %q. got=%v", tt.expected, err)
+		}
+	}
+}
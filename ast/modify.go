@@ -0,0 +1,289 @@
+package ast
+
+import "fmt"
+
+// ModifierFunc is called by Modify on every node, returning the node to put
+// in its place. Returning the node it was given leaves it alone
+type ModifierFunc func(Node) Node
+
+// Modify walks the tree depth first, calling modifier on the children of a
+// node before the node itself. The tree given to Modify is left as it was:
+// when a child is replaced its parent is copied with the new child in it,
+// and so on up to the root, while untouched nodes are shared with the
+// original tree so their tokens and positions are kept.
+//
+// A statement in a Program or BlockStatement can be removed by returning nil
+// for it. Replacing a node with one that can't go in the same place, like
+// putting a statement where an expression goes, panics
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		statements, changed := modifyStatements(n.Statements, modifier)
+		if changed {
+			c := *n
+			c.Statements = statements
+			node = &c
+		}
+
+	case *LetStatement:
+		name := modifyIdentifier(n.Name, modifier)
+		typ := modifyType(n.Type, modifier)
+		value := modifyExpression(n.Value, modifier)
+		if name != n.Name || typ != n.Type || value != n.Value {
+			c := *n
+			c.Name, c.Type, c.Value = name, typ, value
+			node = &c
+		}
+
+	case *ReturnStatement:
+		value := modifyExpression(n.ReturnValue, modifier)
+		if value != n.ReturnValue {
+			c := *n
+			c.ReturnValue = value
+			node = &c
+		}
+
+	case *ExpressionStatement:
+		exp := modifyExpression(n.Expression, modifier)
+		if exp != n.Expression {
+			c := *n
+			c.Expression = exp
+			node = &c
+		}
+
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *NamedType:
+		// no children to modify
+
+	case *PrefixExpression:
+		right := modifyExpression(n.Right, modifier)
+		if right != n.Right {
+			c := *n
+			c.Right = right
+			node = &c
+		}
+
+	case *InfixExpression:
+		left := modifyExpression(n.Left, modifier)
+		right := modifyExpression(n.Right, modifier)
+		if left != n.Left || right != n.Right {
+			c := *n
+			c.Left, c.Right = left, right
+			node = &c
+		}
+
+	case *InterpolatedString:
+		expressions, changed := modifyExpressions(n.Expressions, modifier)
+		if changed {
+			c := *n
+			c.Expressions = expressions
+			node = &c
+		}
+
+	case *BlockStatement:
+		statements, changed := modifyStatements(n.Statements, modifier)
+		if changed {
+			c := *n
+			c.Statements = statements
+			node = &c
+		}
+
+	case *IfExpression:
+		condition := modifyExpression(n.Condition, modifier)
+		consequence := modifyBlock(n.Consequence, modifier)
+		alternative := modifyBlock(n.Alternative, modifier)
+		if condition != n.Condition || consequence != n.Consequence || alternative != n.Alternative {
+			c := *n
+			c.Condition, c.Consequence, c.Alternative = condition, consequence, alternative
+			node = &c
+		}
+
+	case *FunctionLiteral:
+		changed := false
+		params := make([]*Identifier, len(n.Parameters))
+		for i, p := range n.Parameters {
+			params[i] = modifyIdentifier(p, modifier)
+			if params[i] != p {
+				changed = true
+			}
+		}
+		var types []TypeExpr
+		if n.ParameterTypes != nil {
+			var typesChanged bool
+			types, typesChanged = modifyTypes(n.ParameterTypes, modifier)
+			changed = changed || typesChanged
+		}
+		result := modifyType(n.ReturnType, modifier)
+		body := modifyBlock(n.Body, modifier)
+		if changed || result != n.ReturnType || body != n.Body {
+			c := *n
+			c.Parameters, c.ParameterTypes, c.ReturnType, c.Body = params, types, result, body
+			node = &c
+		}
+
+	case *CallExpression:
+		function := modifyExpression(n.Function, modifier)
+		args, changed := modifyExpressions(n.Arguments, modifier)
+		if changed || function != n.Function {
+			c := *n
+			c.Function, c.Arguments = function, args
+			node = &c
+		}
+
+	case *ArrayLiteral:
+		elements, changed := modifyExpressions(n.Elements, modifier)
+		if changed {
+			c := *n
+			c.Elements = elements
+			node = &c
+		}
+
+	case *IndexExpression:
+		left := modifyExpression(n.Left, modifier)
+		index := modifyExpression(n.Index, modifier)
+		if left != n.Left || index != n.Index {
+			c := *n
+			c.Left, c.Index = left, index
+			node = &c
+		}
+
+	case *ArrayType:
+		elem := modifyType(n.Element, modifier)
+		if elem != n.Element {
+			c := *n
+			c.Element = elem
+			node = &c
+		}
+
+	case *HashType:
+		key := modifyType(n.Key, modifier)
+		value := modifyType(n.Value, modifier)
+		if key != n.Key || value != n.Value {
+			c := *n
+			c.Key, c.Value = key, value
+			node = &c
+		}
+
+	case *FunctionType:
+		params, changed := modifyTypes(n.Parameters, modifier)
+		result := modifyType(n.Result, modifier)
+		if changed || result != n.Result {
+			c := *n
+			c.Parameters, c.Result = params, result
+			node = &c
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+
+	return modifier(node)
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+
+	modified := Modify(e, modifier)
+	if modified == nil {
+		return nil
+	}
+	exp, ok := modified.(Expression)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace expression %T with %T", e, modified))
+	}
+	return exp
+}
+
+func modifyIdentifier(i *Identifier, modifier ModifierFunc) *Identifier {
+	if i == nil {
+		return nil
+	}
+
+	modified := Modify(i, modifier)
+	ident, ok := modified.(*Identifier)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace *ast.Identifier with %T", modified))
+	}
+	return ident
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+
+	modified := Modify(b, modifier)
+	block, ok := modified.(*BlockStatement)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace *ast.BlockStatement with %T", modified))
+	}
+	return block
+}
+
+func modifyType(t TypeExpr, modifier ModifierFunc) TypeExpr {
+	if t == nil {
+		return nil
+	}
+
+	modified := Modify(t, modifier)
+	if modified == nil {
+		return nil
+	}
+	typ, ok := modified.(TypeExpr)
+	if !ok {
+		panic(fmt.Sprintf("ast.Modify: cannot replace type %T with %T", t, modified))
+	}
+	return typ
+}
+
+func modifyTypes(types []TypeExpr, modifier ModifierFunc) ([]TypeExpr, bool) {
+	changed := false
+	modified := make([]TypeExpr, len(types))
+
+	for i, t := range types {
+		modified[i] = modifyType(t, modifier)
+		if modified[i] != t {
+			changed = true
+		}
+	}
+
+	return modified, changed
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) ([]Expression, bool) {
+	changed := false
+	modified := make([]Expression, len(exps))
+
+	for i, e := range exps {
+		modified[i] = modifyExpression(e, modifier)
+		if modified[i] != e {
+			changed = true
+		}
+	}
+
+	return modified, changed
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) ([]Statement, bool) {
+	changed := false
+	modified := []Statement{}
+
+	for _, s := range stmts {
+		m := Modify(s, modifier)
+		if m == nil {
+			changed = true
+			continue
+		}
+		stmt, ok := m.(Statement)
+		if !ok {
+			panic(fmt.Sprintf("ast.Modify: cannot replace statement %T with %T", s, m))
+		}
+		if stmt != s {
+			changed = true
+		}
+		modified = append(modified, stmt)
+	}
+
+	return modified, changed
+}
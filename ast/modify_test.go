@@ -0,0 +1,136 @@
+package ast
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	mtoken "monkey/token"
+)
+
+func TestModifyReplacesEveryChild(t *testing.T) {
+	for _, n := range allNodes() {
+		for _, child := range children(n) {
+			// a shallow copy of the child is a different node of the same type
+			replacement := reflect.New(reflect.TypeOf(child).Elem())
+			replacement.Elem().Set(reflect.ValueOf(child).Elem())
+			r := replacement.Interface().(Node)
+
+			modified := Modify(n, func(node Node) Node {
+				if node == child {
+					return r
+				}
+				return node
+			})
+
+			found := false
+			for _, c := range children(modified) {
+				if c == r {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Modify did not replace %T child of %T", child, n)
+			}
+
+			for _, c := range children(n) {
+				if c == r {
+					t.Errorf("Modify changed the original %T", n)
+				}
+			}
+		}
+	}
+}
+
+func TestModifyConstantFolding(t *testing.T) {
+	five := &LetStatement{
+		Token: mtoken.Token{Type: mtoken.LET, Literal: "let", Pos: mtoken.Position{Offset: 0, Line: 1, Column: 1}},
+		Name:  ident("five"),
+		Value: integer(5, "5"),
+	}
+	program := &Program{Statements: []Statement{
+		five,
+		&LetStatement{
+			Token: mtoken.Token{Type: mtoken.LET, Literal: "let"},
+			Name:  ident("x"),
+			Value: &InfixExpression{
+				Left:     &InfixExpression{Left: integer(1, "1"), Operator: "+", Right: integer(2, "2")},
+				Operator: "*",
+				Right:    ident("y"),
+			},
+		},
+	}}
+
+	fold := func(node Node) Node {
+		infix, ok := node.(*InfixExpression)
+		if !ok || infix.Operator != "+" {
+			return node
+		}
+		left, ok := infix.Left.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		right, ok := infix.Right.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		value := left.Value + right.Value
+		return integer(value, strconv.FormatInt(value, 10))
+	}
+
+	modified := Modify(program, fold).(*Program)
+
+	if modified.String() != "let five = 5;let x = (3 * y);" {
+		t.Errorf("modified program wrong. got=%q", modified.String())
+	}
+	if program.String() != "let five = 5;let x = ((1 + 2) * y);" {
+		t.Errorf("original program was changed. got=%q", program.String())
+	}
+	if modified.Statements[0] != five {
+		t.Errorf("untouched statement was not kept")
+	}
+	if modified.Statements[0].(*LetStatement).Token.Pos.Line != 1 {
+		t.Errorf("position of the untouched statement was lost")
+	}
+	if modified.Statements[1] == program.Statements[1] {
+		t.Errorf("changed statement should have been copied")
+	}
+}
+
+func TestModifyRemovesStatements(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: ident("a")},
+		&ReturnStatement{Token: mtoken.Token{Type: mtoken.RETURN, Literal: "return"}, ReturnValue: ident("b")},
+		&ExpressionStatement{Expression: ident("c")},
+	}}
+
+	modified := Modify(program, func(node Node) Node {
+		if _, ok := node.(*ReturnStatement); ok {
+			return nil
+		}
+		return node
+	}).(*Program)
+
+	if modified.String() != "ac" {
+		t.Errorf("modified program wrong. got=%q", modified.String())
+	}
+	if len(program.Statements) != 3 {
+		t.Errorf("original program was changed")
+	}
+}
+
+func TestModifyWrongType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic when putting a statement in place of an expression")
+		}
+	}()
+
+	stmt := &ExpressionStatement{Expression: ident("a")}
+	Modify(stmt, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return &ExpressionStatement{Expression: ident("b")}
+		}
+		return node
+	})
+}
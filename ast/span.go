@@ -0,0 +1,127 @@
+package ast
+
+import (
+	"reflect"
+
+	"monkey/token"
+)
+
+// nodeToken returns the token held by a node, which every node apart from
+// Program has in a field called Token
+func nodeToken(node Node) (token.Token, bool) {
+	field := reflect.ValueOf(node).Elem().FieldByName("Token")
+	if !field.IsValid() {
+		return token.Token{}, false
+	}
+	tok, ok := field.Interface().(token.Token)
+	return tok, ok
+}
+
+// closeToken returns the bracket or brace that ends a node, for the nodes
+// that have one. A block the parser reached the end of the input in has
+// none
+func closeToken(node Node) (token.Token, bool) {
+	var tok token.Token
+	switch n := node.(type) {
+	case *BlockStatement:
+		tok = n.Close
+	case *CallExpression:
+		tok = n.Close
+	case *ArrayLiteral:
+		tok = n.Close
+	case *IndexExpression:
+		tok = n.Close
+	case *ArrayType:
+		tok = n.Close
+	case *HashType:
+		tok = n.Close
+	}
+	return tok, tok.Type != ""
+}
+
+type span struct {
+	start, end token.Position
+	found      bool
+}
+
+func (s span) add(other span) span {
+	if !other.found {
+		return s
+	}
+	if !s.found || other.start.Offset < s.start.Offset {
+		s.start = other.start
+	}
+	if !s.found || other.end.Offset > s.end.Offset {
+		s.end = other.end
+	}
+	s.found = true
+	return s
+}
+
+func tokenSpan(tok token.Token) span {
+	return span{start: tok.Pos, end: tok.End, found: true}
+}
+
+// Span finds the start of the first token and the end of the last token
+// anywhere in node, closing brackets included, which is the part of the
+// source the node was parsed from. It reports false if none of the nodes
+// have a token
+func Span(node Node) (token.Position, token.Position, bool) {
+	s := spans(node, nil)
+	return s.start, s.end, s.found
+}
+
+// Extent is where a node is in the source, as Span gives it
+type Extent struct {
+	Start, End token.Position
+}
+
+// Spans gives the Span of node and of every node in it, worked out in one
+// go rather than walking each node again. Nodes with no tokens are left out
+func Spans(node Node) map[Node]Extent {
+	all := map[Node]span{}
+	spans(node, all)
+	extents := map[Node]Extent{}
+	for n, s := range all {
+		if s.found {
+			extents[n] = Extent{Start: s.start, End: s.end}
+		}
+	}
+	return extents
+}
+
+// spans works out the span of node and everything in it in one go, from
+// the spans of the children of each node. If all isn't nil the span of
+// each node is put in it
+func spans(node Node, all map[Node]span) span {
+	// the spans of the nodes being walked, with the one for node's parent
+	// at the bottom
+	stack := []span{{}}
+	nodes := []Node{}
+
+	Inspect(node, func(n Node) bool {
+		if n == nil {
+			s := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			stack[len(stack)-1] = stack[len(stack)-1].add(s)
+			if all != nil {
+				all[nodes[len(nodes)-1]] = s
+			}
+			nodes = nodes[:len(nodes)-1]
+			return false
+		}
+
+		s := span{}
+		if tok, ok := nodeToken(n); ok {
+			s = s.add(tokenSpan(tok))
+		}
+		if tok, ok := closeToken(n); ok {
+			s = s.add(tokenSpan(tok))
+		}
+		stack = append(stack, s)
+		nodes = append(nodes, n)
+		return true
+	})
+
+	return stack[0]
+}
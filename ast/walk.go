@@ -0,0 +1,173 @@
+package ast
+
+import "fmt"
+
+// Walk and Inspect work the same way as the functions of the same name in
+// go/ast, so tools don't have to type switch over every node themselves
+
+// A Visitor's Visit method is called for each node found by Walk. If it
+// returns a non-nil visitor w, Walk visits each of the children of node
+// with w, followed by a call of w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order. Children the parser couldn't
+// fill in because of errors are nil and are skipped over
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Walk(v, s)
+		}
+
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Walk(v, n.ReturnValue)
+		}
+
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *NamedType:
+		// nothing to do
+
+	case *PrefixExpression:
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+
+	case *InfixExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+
+	case *InterpolatedString:
+		for _, e := range n.Expressions {
+			if e != nil {
+				Walk(v, e)
+			}
+		}
+
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Walk(v, s)
+		}
+
+	case *IfExpression:
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			if p != nil {
+				Walk(v, p)
+			}
+			if t := n.ParameterType(i); t != nil {
+				Walk(v, t)
+			}
+		}
+		if n.ReturnType != nil {
+			Walk(v, n.ReturnType)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *CallExpression:
+		if n.Function != nil {
+			Walk(v, n.Function)
+		}
+		for _, a := range n.Arguments {
+			if a != nil {
+				Walk(v, a)
+			}
+		}
+
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			if e != nil {
+				Walk(v, e)
+			}
+		}
+
+	case *IndexExpression:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Index != nil {
+			Walk(v, n.Index)
+		}
+
+	case *ArrayType:
+		if n.Element != nil {
+			Walk(v, n.Element)
+		}
+
+	case *HashType:
+		if n.Key != nil {
+			Walk(v, n.Key)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+
+	case *FunctionType:
+		for _, p := range n.Parameters {
+			if p != nil {
+				Walk(v, p)
+			}
+		}
+		if n.Result != nil {
+			Walk(v, n.Result)
+		}
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, calling f(node) for each
+// node and then f(nil) once its children are done. If f returns false the
+// children of node are skipped
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,250 @@
+package ast
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	mtoken "monkey/token"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: mtoken.Token{Type: mtoken.IDENT, Literal: name}, Value: name}
+}
+
+func integer(value int64, literal string) *IntegerLiteral {
+	return &IntegerLiteral{Token: mtoken.Token{Type: mtoken.INT, Literal: literal}, Value: value}
+}
+
+func named(name string) *NamedType {
+	return &NamedType{Token: mtoken.Token{Type: mtoken.IDENT, Literal: name}, Name: name}
+}
+
+// allNodes returns one of every node type with all of its children filled
+// in. When a node type is added it has to be added here as well, which
+// TestAllNodesCoversPackage checks for
+func allNodes() []Node {
+	return []Node{
+		&Program{Statements: []Statement{
+			&ExpressionStatement{Token: mtoken.Token{Type: mtoken.IDENT, Literal: "a"}, Expression: ident("a")},
+			&ReturnStatement{Token: mtoken.Token{Type: mtoken.RETURN, Literal: "return"}, ReturnValue: integer(1, "1")},
+		}},
+		&LetStatement{
+			Token: mtoken.Token{Type: mtoken.LET, Literal: "let"},
+			Name:  ident("x"),
+			Type:  named("int"),
+			Value: integer(5, "5"),
+		},
+		&ReturnStatement{Token: mtoken.Token{Type: mtoken.RETURN, Literal: "return"}, ReturnValue: ident("y")},
+		&ExpressionStatement{Token: mtoken.Token{Type: mtoken.IDENT, Literal: "z"}, Expression: ident("z")},
+		ident("foo"),
+		integer(10, "10"),
+		&StringLiteral{Token: mtoken.Token{Type: mtoken.STRING, Literal: "hi"}, Value: "hi"},
+		&PrefixExpression{Token: mtoken.Token{Type: mtoken.MINUS, Literal: "-"}, Operator: "-", Right: integer(3, "3")},
+		&InfixExpression{
+			Token:    mtoken.Token{Type: mtoken.PLUS, Literal: "+"},
+			Left:     ident("a"),
+			Operator: "+",
+			Right:    ident("b"),
+		},
+		&InterpolatedString{
+			Token:       mtoken.Token{Type: mtoken.INTERP_START, Literal: "a "},
+			Literals:    []string{"a ", " b ", ""},
+			Expressions: []Expression{ident("x"), integer(2, "2")},
+		},
+		&Boolean{Token: mtoken.Token{Type: mtoken.TRUE, Literal: "true"}, Value: true},
+		&BlockStatement{
+			Token:      mtoken.Token{Type: mtoken.LBRACE, Literal: "{"},
+			Statements: []Statement{&ExpressionStatement{Expression: ident("b")}},
+			Close:      mtoken.Token{Type: mtoken.RBRACE, Literal: "}"},
+		},
+		&IfExpression{
+			Token:       mtoken.Token{Type: mtoken.IF, Literal: "if"},
+			Condition:   ident("c"),
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("d")}}},
+			Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("e")}}},
+		},
+		&FunctionLiteral{
+			Token:          mtoken.Token{Type: mtoken.FUNCTION, Literal: "fn"},
+			Parameters:     []*Identifier{ident("p"), ident("q")},
+			ParameterTypes: []TypeExpr{named("int"), nil},
+			ReturnType:     named("int"),
+			Body:           &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: ident("p")}}},
+		},
+		&CallExpression{
+			Token:     mtoken.Token{Type: mtoken.LBRACKET, Literal: "("},
+			Function:  ident("f"),
+			Arguments: []Expression{integer(1, "1"), ident("g")},
+			Close:     mtoken.Token{Type: mtoken.RBRACKET, Literal: ")"},
+		},
+		&ArrayLiteral{
+			Token:    mtoken.Token{Type: mtoken.LSQUARE, Literal: "["},
+			Elements: []Expression{integer(1, "1"), integer(2, "2")},
+			Close:    mtoken.Token{Type: mtoken.RSQUARE, Literal: "]"},
+		},
+		&IndexExpression{
+			Token: mtoken.Token{Type: mtoken.LSQUARE, Literal: "["},
+			Left:  ident("arr"),
+			Index: integer(0, "0"),
+			Close: mtoken.Token{Type: mtoken.RSQUARE, Literal: "]"},
+		},
+		named("bool"),
+		&ArrayType{Token: mtoken.Token{Type: mtoken.LSQUARE, Literal: "["}, Element: named("string"), Close: mtoken.Token{Type: mtoken.RSQUARE, Literal: "]"}},
+		&HashType{Token: mtoken.Token{Type: mtoken.LBRACE, Literal: "{"}, Key: named("string"), Value: named("int"), Close: mtoken.Token{Type: mtoken.RBRACE, Literal: "}"}},
+		&FunctionType{
+			Token:      mtoken.Token{Type: mtoken.FUNCTION, Literal: "fn"},
+			Parameters: []TypeExpr{named("int"), named("null")},
+			Result:     named("bool"),
+		},
+	}
+}
+
+// nodeTypes finds every node type declared in the package by looking for
+// the statementNode, expressionNode and typeExprNode methods in the source
+func nodeTypes(t *testing.T) []string {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	types := []string{"Program"}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil {
+				continue
+			}
+			if fn.Name.Name != "statementNode" && fn.Name.Name != "expressionNode" && fn.Name.Name != "typeExprNode" {
+				continue
+			}
+			recv := fn.Recv.List[0].Type.(*ast.StarExpr).X.(*ast.Ident)
+			types = append(types, recv.Name)
+		}
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+// children uses reflection to find the nodes held directly by a node
+func children(n Node) []Node {
+	kids := []Node{}
+	nodeType := reflect.TypeOf((*Node)(nil)).Elem()
+
+	v := reflect.ValueOf(n).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch {
+		case f.Type().Implements(nodeType):
+			if !f.IsNil() {
+				kids = append(kids, f.Interface().(Node))
+			}
+		case f.Kind() == reflect.Slice && f.Type().Elem().Implements(nodeType):
+			for j := 0; j < f.Len(); j++ {
+				if !f.Index(j).IsNil() {
+					kids = append(kids, f.Index(j).Interface().(Node))
+				}
+			}
+		}
+	}
+
+	return kids
+}
+
+func TestAllNodesCoversPackage(t *testing.T) {
+	sampled := map[string]bool{}
+	for _, n := range allNodes() {
+		sampled[reflect.TypeOf(n).Elem().Name()] = true
+	}
+
+	for _, name := range nodeTypes(t) {
+		if !sampled[name] {
+			t.Errorf("node type %s is missing from allNodes, add it there and to Walk", name)
+		}
+	}
+}
+
+func TestWalkVisitsAllChildren(t *testing.T) {
+	for _, n := range allNodes() {
+		visited := map[Node]bool{}
+		Inspect(n, func(node Node) bool {
+			if node != nil {
+				visited[node] = true
+			}
+			return true
+		})
+
+		for _, child := range children(n) {
+			if !visited[child] {
+				t.Errorf("Walk did not visit %T child of %T", child, n)
+			}
+		}
+	}
+}
+
+func TestInspectOrder(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{
+			Token: mtoken.Token{Type: mtoken.LET, Literal: "let"},
+			Name:  ident("x"),
+			Value: &InfixExpression{
+				Left:     &PrefixExpression{Operator: "-", Right: ident("a")},
+				Operator: "+",
+				Right:    integer(5, "5"),
+			},
+		},
+		&ExpressionStatement{Expression: ident("x")},
+	}}
+
+	var got []string
+	Inspect(program, func(n Node) bool {
+		if n == nil {
+			got = append(got, "end")
+			return false
+		}
+		got = append(got, reflect.TypeOf(n).Elem().Name())
+		// don't look inside prefix expressions
+		_, prefix := n.(*PrefixExpression)
+		return !prefix
+	})
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier", "end",
+		"InfixExpression", "PrefixExpression", "IntegerLiteral", "end", "end", "end",
+		"ExpressionStatement", "Identifier", "end", "end",
+		"end",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong visit order.\nexpected=%v\ngot=%v", expected, got)
+	}
+}
+
+func TestWalkSkipsMissingChildren(t *testing.T) {
+	// a let statement the parser couldn't finish has no value
+	stmt := &LetStatement{Name: ident("x")}
+
+	count := 0
+	Inspect(stmt, func(n Node) bool {
+		if n != nil {
+			count++
+		}
+		return true
+	})
+	if count != 2 {
+		t.Errorf("expected to visit 2 nodes. got=%d", count)
+	}
+}
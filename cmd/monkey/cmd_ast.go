@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"monkey/ast"
+	"monkey/ast/dump"
+)
+
+// runAST prints the tree for a file
+//
+//	monkey ast [--format=string|tree|dot|json] [--json] [file.mk]
+func runAST(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "string", "how to print the tree: string, tree, dot or json")
+	asJSON := flags.Bool("json", false, "print the tree as JSON, the same as --format=json")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *asJSON {
+		*format = "json"
+	}
+	if *format != "string" && *format != "tree" && *format != "dot" && *format != "json" {
+		fmt.Fprintf(stderr, "monkey ast: unknown format %q\n", *format)
+		return 2
+	}
+
+	src, err := readSource(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+	program := parseSource(sourceName(flags.Args()), src, stderr)
+	if program == nil {
+		return 1
+	}
+
+	switch *format {
+	case "string":
+		fmt.Fprintln(stdout, program.String())
+		return 0
+	case "tree":
+		fmt.Fprint(stdout, dump.Tree(program))
+		return 0
+	case "dot":
+		fmt.Fprint(stdout, dump.Dot(program))
+		return 0
+	}
+
+	data, err := ast.MarshalJSON(program)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+	var out bytes.Buffer
+	json.Indent(&out, data, "", "  ")
+	out.WriteByte('\n')
+	stdout.Write(out.Bytes())
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/resolver"
+	"monkey/token"
+	"monkey/types"
+)
+
+// runCheck type checks files without running them, exiting with 1 if there
+// are any errors. With -types it also prints the type of each top level let
+//
+//	monkey check [-types] files...
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	showTypes := flags.Bool("types", false, "print the type of each top level let")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+
+	code := 0
+	for _, file := range files {
+		var names []string
+		if file != "" {
+			names = []string{file}
+		}
+		src, err := readSource(names)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey check: %s\n", err)
+			code = 1
+			continue
+		}
+		name := sourceName(names)
+		program := parseSource(name, src, stderr)
+		if program == nil {
+			code = 1
+			continue
+		}
+
+		if errs := checkErrors(program); len(errs) != 0 {
+			for _, e := range errs {
+				fmt.Fprintf(stdout, "%s:%d:%d: %s\n", name, e.pos.Line, e.pos.Column, e.message)
+			}
+			code = 1
+			continue
+		}
+
+		if *showTypes {
+			info := types.Check(program, types.Builtins())
+			for _, s := range program.Statements {
+				if let, ok := s.(*ast.LetStatement); ok {
+					fmt.Fprintf(stdout, "%s: %s\n", let.Name.Value, types.TypeString(info.Defs[let.Name]))
+				}
+			}
+		}
+	}
+
+	return code
+}
+
+type checkError struct {
+	pos     token.Position
+	message string
+}
+
+// checkErrors gives the resolver's errors along with the type errors, in the
+// order they appear in the file
+func checkErrors(program *ast.Program) []checkError {
+	errs := []checkError{}
+
+	for _, d := range resolver.Resolve(program, evaluator.BuiltinNames()...).Errors() {
+		errs = append(errs, checkError{pos: d.Pos, message: d.Message})
+	}
+	for _, e := range types.Check(program, types.Builtins()).Errors {
+		errs = append(errs, checkError{pos: e.Pos, message: e.Message})
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].pos.Offset < errs[j].pos.Offset
+	})
+	return errs
+}
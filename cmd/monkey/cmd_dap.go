@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey/dap"
+)
+
+// runDAP runs a debug adapter so editors can debug programs, talking to
+// them over stdin and stdout
+//
+//	monkey dap
+func runDAP(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := dap.NewServer(os.Stdin, stdout, stderr).Run(); err != nil {
+		fmt.Fprintf(stderr, "monkey dap: %s\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"monkey/format"
+)
+
+// runFmt formats files, printing the result unless -w or -d is given
+//
+//	monkey fmt [-w] [-d] files...
+func runFmt(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result back to the file instead of printing it")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the result")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			return 1
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(stderr, "<stdin>: %s\n", err)
+			return 1
+		}
+		stdout.Write(formatted)
+		return 0
+	}
+
+	code := 0
+	for _, file := range flags.Args() {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+			code = 1
+			continue
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", file, err)
+			code = 1
+			continue
+		}
+
+		if *diff && !bytes.Equal(src, formatted) {
+			fmt.Fprint(stdout, unifiedDiff(file, string(src), string(formatted)))
+		}
+		if *write && !bytes.Equal(src, formatted) {
+			if err := os.WriteFile(file, formatted, 0644); err != nil {
+				fmt.Fprintf(stderr, "monkey fmt: %s\n", err)
+				code = 1
+			}
+		}
+		if !*diff && !*write {
+			stdout.Write(formatted)
+		}
+	}
+
+	return code
+}
+
+// how many unchanged lines to show around each change
+const diffContext = 3
+
+// unifiedDiff compares two versions of a file line by line, in the same
+// format as diff -u
+func unifiedDiff(name, a, b string) string {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// each line of the diff is marked with ' ', '-' or '+'
+	type line struct {
+		op   byte
+		text string
+	}
+	lines := []line{}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, line{' ', x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', x[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', y[j]})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+
+	// group the changes into hunks with some context around them
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		from := max(start-diffContext, 0)
+		end := start
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			// carry on if another change is close enough to join this hunk
+			next := end
+			for next < len(lines) && lines[next].op == ' ' {
+				next++
+			}
+			if next < len(lines) && next-end <= 2*diffContext {
+				end = next
+				continue
+			}
+			break
+		}
+		to := min(end+diffContext, len(lines))
+
+		// work out the line numbers the hunk starts at in each version
+		aStart, bStart, aLen, bLen := 1, 1, 0, 0
+		for _, l := range lines[:from] {
+			if l.op != '+' {
+				aStart++
+			}
+			if l.op != '-' {
+				bStart++
+			}
+		}
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				aLen++
+			}
+			if l.op != '-' {
+				bLen++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, l := range lines[from:to] {
+			fmt.Fprintf(&out, "%c%s\n", l.op, l.text)
+		}
+		start = to
+	}
+
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"monkey/lint"
+)
+
+// runLint prints what the linter finds in each file, exiting with 1 if it
+// finds anything
+//
+//	monkey lint [-config file] [-enable rules] [-disable rules] [-rules] files...
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	configFile := flags.String("config", "", "JSON file turning rules on and off")
+	enable := flags.String("enable", "", "comma separated rules to turn on")
+	disable := flags.String("disable", "", "comma separated rules to turn off")
+	list := flags.Bool("rules", false, "list the rules and exit")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *list {
+		for _, r := range lint.DefaultRules() {
+			fmt.Fprintf(stdout, "%-20s %s\n", r.Name(), r.Doc())
+		}
+		return 0
+	}
+
+	config := lint.Config{}
+	if *configFile != "" {
+		data, err := os.ReadFile(*configFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+			return 2
+		}
+		if config, err = lint.ParseConfig(data); err != nil {
+			fmt.Fprintf(stderr, "monkey lint: %s: %s\n", *configFile, err)
+			return 2
+		}
+	}
+	// the flags win over the config file
+	if config.Enabled == nil {
+		config.Enabled = map[string]bool{}
+	}
+	for _, name := range splitList(*enable) {
+		config.Enabled[name] = true
+	}
+	for _, name := range splitList(*disable) {
+		config.Enabled[name] = false
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+		return 2
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+
+	code := 0
+	for _, file := range files {
+		var names []string
+		if file != "" {
+			names = []string{file}
+		}
+		src, err := readSource(names)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey lint: %s\n", err)
+			code = 1
+			continue
+		}
+
+		diagnostics, err := lint.Source(src, config)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", sourceName(names), err)
+			code = 1
+			continue
+		}
+		for _, d := range diagnostics {
+			fmt.Fprintf(stdout, "%s:%s\n", sourceName(names), d)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+func splitList(s string) []string {
+	names := []string{}
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"monkey/lsp"
+)
+
+// runLSP runs a language server for editors, talking to them over stdin
+// and stdout
+//
+//	monkey lsp
+func runLSP(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, stdout, stderr).Run(); err != nil {
+		fmt.Fprintf(stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
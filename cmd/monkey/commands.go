@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+// each command gets its arguments after the command name and returns the
+// exit code for the program
+type command func(args []string, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"ast":   runAST,
+	"check": runCheck,
+	"dap":   runDAP,
+	"fmt":   runFmt,
+	"lint":  runLint,
+	"lsp":   runLSP,
+}
+
+func runCommand(name string, args []string) int {
+	cmd, ok := commands[name]
+	if !ok {
+		names := []string{}
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "monkey: unknown command %q, expected one of %v\n", name, names)
+		return 2
+	}
+	return cmd(args, os.Stdout, os.Stderr)
+}
+
+// readSource reads the file named by the command line, or stdin when there
+// isn't one
+func readSource(args []string) (string, error) {
+	switch len(args) {
+	case 0:
+		src, err := io.ReadAll(os.Stdin)
+		return string(src), err
+	case 1:
+		src, err := os.ReadFile(args[0])
+		return string(src), err
+	default:
+		return "", fmt.Errorf("expected one file, got %d", len(args))
+	}
+}
+
+func sourceName(args []string) string {
+	if len(args) == 0 {
+		return "<stdin>"
+	}
+	return args[0]
+}
+
+// parseSource parses src and prints any parser errors, returning nil if
+// there were some
+func parseSource(name, src string, stderr io.Writer) *ast.Program {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "%s: %s\n", name, msg)
+		}
+		return nil
+	}
+
+	return program
+}
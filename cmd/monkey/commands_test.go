@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden runs a command over each .mk file in testdata/dir and compares
+// what it prints with the file of the same name ending in ext
+func checkGolden(t *testing.T, dir, ext string, cmd command, flags ...string) {
+	checkGoldenExit(t, dir, ext, 0, cmd, flags...)
+}
+
+// checkGoldenExit is checkGolden for commands that exit with something
+// other than 0 when they work, like lint when it finds problems
+func checkGoldenExit(t *testing.T, dir, ext string, exit int, cmd command, flags ...string) {
+	files, err := filepath.Glob(filepath.Join("testdata", dir, "*.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no test files in testdata/%s", dir)
+	}
+
+	for _, file := range files {
+		var stdout, stderr bytes.Buffer
+		if code := cmd(append(flags, file), &stdout, &stderr); code != exit {
+			t.Errorf("%s: exit code %d, stderr=%q", file, code, stderr.String())
+			continue
+		}
+
+		golden := strings.TrimSuffix(file, ".mk") + ext
+		if *update {
+			if err := os.WriteFile(golden, stdout.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != string(expected) {
+			t.Errorf("%s: output differs from %s.\ngot=\n%s", file, golden, stdout.String())
+		}
+	}
+}
+
+func TestASTJSON(t *testing.T) {
+	checkGolden(t, "ast", ".json", runAST, "--json")
+}
+
+func TestASTTree(t *testing.T) {
+	checkGolden(t, "ast", ".tree", runAST, "--format=tree")
+}
+
+func TestASTDot(t *testing.T) {
+	checkGolden(t, "ast", ".dot", runAST, "--format=dot")
+}
+
+func TestASTParseErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "bad.mk")
+	os.WriteFile(file, []byte("let = 5;"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := runAST([]string{file}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1. got=%d", code)
+	}
+	if !strings.Contains(stderr.String(), "expected next token to be IDENT") {
+		t.Errorf("parser error not printed. got=%q", stderr.String())
+	}
+}
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mk")
+	os.WriteFile(file, []byte("let x=1;\nlet y=(x+2)*3;\nlet z = 3;\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := runFmt([]string{"-d", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr=%q", code, stderr.String())
+	}
+	expectedDiff := "--- " + file + "\n+++ " + file + "\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		"-let x=1;\n" +
+		"-let y=(x+2)*3;\n" +
+		"+let x = 1;\n" +
+		"+let y = (x + 2) * 3;\n" +
+		" let z = 3;\n"
+	if stdout.String() != expectedDiff {
+		t.Errorf("diff wrong.\nexpected=\n%s\ngot=\n%s", expectedDiff, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runFmt([]string{"-w", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code %d, stderr=%q", code, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("-w should not print anything. got=%q", stdout.String())
+	}
+	written, _ := os.ReadFile(file)
+	if string(written) != "let x = 1;\nlet y = (x + 2) * 3;\nlet z = 3;\n" {
+		t.Errorf("file not formatted. got=%q", written)
+	}
+}
+
+func TestLint(t *testing.T) {
+	checkGoldenExit(t, "lint", ".lint", 1, runLint)
+}
+
+func TestLintFlags(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mk")
+	os.WriteFile(file, []byte("let x = 1;\nlet y = 2;\ny / 0;\n"), 0644)
+	config := filepath.Join(dir, "lint.json")
+	os.WriteFile(config, []byte(`{"rules": {"unused": false}}`), 0644)
+
+	tests := []struct {
+		args     []string
+		code     int
+		expected string
+	}{
+		{[]string{"-disable", "unused,division-by-zero"}, 0, ""},
+		{[]string{"-config", config}, 1, file + ":3:1: division by zero (division-by-zero)\n"},
+		{[]string{"-config", config, "-enable", "unused", "-disable", "division-by-zero"}, 1,
+			file + ":1:5: x is declared but never used (unused)\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := runLint(append(tt.args, file), &stdout, &stderr); code != tt.code {
+			t.Errorf("%v: expected exit code %d. got=%d, stderr=%q", tt.args, tt.code, code, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("%v: expected=%q, got=%q", tt.args, tt.expected, stdout.String())
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := runLint([]string{"-disable", "nonsense", file}, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 for an unknown rule. got=%d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown lint rule "nonsense"`) {
+		t.Errorf("unknown rule not reported. got=%q", stderr.String())
+	}
+}
+
+func TestCheck(t *testing.T) {
+	checkGolden(t, "check", ".types", runCheck, "-types")
+}
+
+func TestCheckErrors(t *testing.T) {
+	checkGoldenExit(t, "check/errors", ".errors", 1, runCheck)
+}
@@ -0,0 +1,2 @@
+let greeting = "Hello ${name}";
+return -a + b * 2; // done
@@ -0,0 +1,8 @@
+let add = fn(a, b) { a + b };
+add(1, true);
+add(1);
+let xs = [1, "two"];
+if (xs) { 1 } else { "one" };
+missing + 1;
+upper(1);
+push([1], "a");
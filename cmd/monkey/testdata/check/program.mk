@@ -0,0 +1,9 @@
+let id = fn(x) { x };
+let compose = fn(f, g) { fn(x) { g(f(x)) } };
+let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+let map = fn(xs, f) { [f(xs[0])] };
+let names = map([1, 2], fn(n) { "n${n}" });
+let greeting = "hello " + names[0];
+let flag = id(true);
+let words = split(upper(greeting), " ");
+let count = len(words) + reduce([1, 2], 0, fn(acc, n) { acc + n });
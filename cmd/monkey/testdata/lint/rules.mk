@@ -0,0 +1,20 @@
+// one of everything the linter looks for
+let unused = 1;
+let _ignored = 2;
+
+let max = fn(a, b) {
+	if (a > b) {
+		return a;
+		a + 1;
+	}
+	return b;
+};
+
+if (true) { max(1, 2) };
+max(1, 2) == max(1, 2);
+let x = 10;
+x == x;
+x / 0;
+
+// lint:ignore unused
+let quiet = x / 0; // lint:ignore division-by-zero
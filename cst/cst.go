@@ -0,0 +1,268 @@
+package cst
+
+import (
+	"bytes"
+	"strings"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+)
+
+// The ast throws away everything that doesn't change the meaning of a
+// program, like whitespace, comments and extra brackets. The concrete syntax
+// tree keeps all of it, so printing a File gives back exactly the bytes it was
+// parsed from, which is what source to source refactors need.
+//
+// The tokens of each top level statement are kept in one run, with a tree of
+// Nodes over them giving the tokens of every block, statement and
+// expression in it. Editing the tokens doesn't change the tree, so after
+// an edit Program parses the printed file again to see what it means
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota
+	Comment               // a // comment, not including the newline that ends it
+)
+
+// Trivia is a piece of source between two tokens
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+// Token is a lexer token along with the exact source text it was read from
+// and the trivia either side of it
+type Token struct {
+	token.Token
+	Text string // the source text of the token, which can differ from Literal
+
+	// trivia on the same line as the token after it belongs to the token,
+	// everything from the following newline onwards belongs to the next token
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// Statement is the run of tokens that make up a single top level statement
+type Statement struct {
+	Tokens []*Token
+	// the statement the parser made from the tokens, this is nil for
+	// tokens at the start of a file the parser couldn't make sense of
+	AST ast.Statement
+	// Node is the tree for AST, nil when AST is
+	Node *Node
+}
+
+// Node is an ast node along with where its tokens are in the Tokens of the
+// top level statement it is in, from Start up to but not including End.
+// Children are the nodes in it that have tokens, in the order ast.Walk
+// visits them
+type Node struct {
+	AST        ast.Node
+	Start, End int
+	Children   []*Node
+}
+
+type File struct {
+	Statements []*Statement
+	EOF        *Token // holds any trivia at the end of the file
+}
+
+func Parse(src string) *File {
+	file := &File{}
+
+	program := parser.New(lexer.New(src)).ParseProgram()
+	// the statements are matched up with their tokens by where they start
+	starts := map[int]ast.Statement{}
+	for _, stmt := range program.Statements {
+		starts[stmtPos(stmt).Offset] = stmt
+	}
+
+	l := lexer.New(src)
+	var prev *Token
+	prevEnd := 0
+	var current *Statement
+
+	for {
+		tok := l.NextToken()
+		ct := &Token{Token: tok, Text: src[tok.Pos.Offset:tok.End.Offset]}
+
+		gap := src[prevEnd:tok.Pos.Offset]
+		if prev != nil {
+			trailing := gap
+			if i := strings.IndexByte(gap, '\n'); i >= 0 {
+				trailing = gap[:i]
+			}
+			prev.Trailing = splitTrivia(trailing)
+			gap = gap[len(trailing):]
+		}
+		ct.Leading = splitTrivia(gap)
+
+		if tok.Type == token.EOF {
+			file.EOF = ct
+			for _, stmt := range file.Statements {
+				stmt.Node = buildTree(stmt)
+			}
+			return file
+		}
+
+		if stmt, ok := starts[tok.Pos.Offset]; ok || current == nil {
+			current = &Statement{AST: stmt}
+			file.Statements = append(file.Statements, current)
+		}
+		current.Tokens = append(current.Tokens, ct)
+
+		prev = ct
+		prevEnd = tok.End.Offset
+	}
+}
+
+// buildTree matches every node in stmt.AST with its tokens, by where the
+// first and last of them are. Nodes with no tokens, or whose tokens aren't
+// all in stmt, are left out and their children go to their parent
+func buildTree(stmt *Statement) *Node {
+	if stmt.AST == nil {
+		return nil
+	}
+	starts, ends := map[int]int{}, map[int]int{}
+	for i, tok := range stmt.Tokens {
+		starts[tok.Pos.Offset] = i
+		ends[tok.End.Offset] = i + 1
+	}
+	extents := ast.Spans(stmt.AST)
+
+	root := &Node{}
+	// the node each node walked into was added to, or nil if it was left
+	// out, with the one for its parent at the top
+	parents := []*Node{root}
+	stack := []*Node{}
+	ast.Inspect(stmt.AST, func(n ast.Node) bool {
+		if n == nil {
+			if top := stack[len(stack)-1]; top != nil {
+				parents = parents[:len(parents)-1]
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+
+		var node *Node
+		e, ok := extents[n]
+		start, okStart := starts[e.Start.Offset]
+		end, okEnd := ends[e.End.Offset]
+		if ok && okStart && okEnd {
+			node = &Node{AST: n, Start: start, End: end}
+			parent := parents[len(parents)-1]
+			parent.Children = append(parent.Children, node)
+			parents = append(parents, node)
+		}
+		stack = append(stack, node)
+		return true
+	})
+
+	if len(root.Children) != 1 {
+		return nil
+	}
+	return root.Children[0]
+}
+
+// Find gives the node for n in the tree under node, or nil if it isn't
+// there
+func (node *Node) Find(n ast.Node) *Node {
+	if node == nil {
+		return nil
+	}
+	if node.AST == n {
+		return node
+	}
+	for _, child := range node.Children {
+		if found := child.Find(n); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func stmtPos(stmt ast.Statement) token.Position {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		return s.Token.Pos
+	case *ast.ReturnStatement:
+		return s.Token.Pos
+	case *ast.ExpressionStatement:
+		return s.Token.Pos
+	}
+	return token.Position{Offset: -1}
+}
+
+func splitTrivia(s string) []Trivia {
+	trivia := []Trivia{}
+
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "//") {
+			end := strings.IndexByte(s, '\n')
+			if end < 0 {
+				end = len(s)
+			}
+			trivia = append(trivia, Trivia{Kind: Comment, Text: s[:end]})
+			s = s[end:]
+			continue
+		}
+
+		end := strings.Index(s, "//")
+		if end < 0 {
+			end = len(s)
+		}
+		trivia = append(trivia, Trivia{Kind: Whitespace, Text: s[:end]})
+		s = s[end:]
+	}
+
+	return trivia
+}
+
+func (t *Token) String() string {
+	var out bytes.Buffer
+
+	for _, tr := range t.Leading {
+		out.WriteString(tr.Text)
+	}
+	out.WriteString(t.Text)
+	for _, tr := range t.Trailing {
+		out.WriteString(tr.Text)
+	}
+
+	return out.String()
+}
+
+func (s *Statement) String() string {
+	var out bytes.Buffer
+
+	for _, t := range s.Tokens {
+		out.WriteString(t.String())
+	}
+
+	return out.String()
+}
+
+// String prints the file back out, for a File returned by Parse this is
+// exactly the source it was given
+func (f *File) String() string {
+	var out bytes.Buffer
+
+	for _, s := range f.Statements {
+		out.WriteString(s.String())
+	}
+	out.WriteString(f.EOF.String())
+
+	return out.String()
+}
+
+// Program converts the tree into an ast.Program along with any parser errors.
+// The file is printed and parsed again so that any edits made to the tokens
+// show up in the result
+func (f *File) Program() (*ast.Program, []string) {
+	p := parser.New(lexer.New(f.String()))
+	program := p.ParseProgram()
+	return program, p.Errors()
+}
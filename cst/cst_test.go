@@ -0,0 +1,146 @@
+package cst
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t ",
+		"let x = 5;",
+		"let x   =   5 ;   // five\n\n\nlet y=x;\n",
+		"// only a comment",
+		"// leading comment\nreturn 10; // trailing\n// end of file",
+		"a + b * c\r\n-5 / (x - 1);",
+		"let = ;; 5 @ ~ let",
+		"10 == 10;\n\t10 != 9;\n!-/*5;",
+		"x / / y // not / a / comment\n",
+		"let add = fn(x, y) {\n\tx + y;\n};\nlet result = add(five, ten);",
+		"let s = \"a // b\\\"\"; `raw\n// x` <<~SQL  \n  select 1\n  SQL\n",
+		"\"unterminated",
+		"<<~EOF\n unterminated",
+		"\"Hello ${ user }, ${ { \"n ${1}\" } }\" // greet\n",
+		// a NUL byte isn't the end of the input
+		"let x = 1;\x00let y = 2;",
+		"\x00",
+		"// a\x00b\n\"c\x00\" `\x00` <<~T\n\x00\nT\n",
+	}
+
+	for _, input := range inputs {
+		file := Parse(input)
+		if file.String() != input {
+			t.Errorf("round trip failed. expected=%q, got=%q", input, file.String())
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "let x = 5; // five\n// next\nx"
+	file := Parse(input)
+
+	if len(file.Statements) != 2 {
+		t.Fatalf("file does not have 2 statements. got=%d", len(file.Statements))
+	}
+
+	semicolon := file.Statements[0].Tokens[4]
+	if semicolon.Text != ";" {
+		t.Fatalf("expected the fifth token to be ';'. got=%q", semicolon.Text)
+	}
+	if len(semicolon.Trailing) != 2 ||
+		semicolon.Trailing[0] != (Trivia{Kind: Whitespace, Text: " "}) ||
+		semicolon.Trailing[1] != (Trivia{Kind: Comment, Text: "// five"}) {
+		t.Errorf("wrong trailing trivia for ';'. got=%+v", semicolon.Trailing)
+	}
+
+	x := file.Statements[1].Tokens[0]
+	if len(x.Leading) != 3 ||
+		x.Leading[0] != (Trivia{Kind: Whitespace, Text: "\n"}) ||
+		x.Leading[1] != (Trivia{Kind: Comment, Text: "// next"}) ||
+		x.Leading[2] != (Trivia{Kind: Whitespace, Text: "\n"}) {
+		t.Errorf("wrong leading trivia for 'x'. got=%+v", x.Leading)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	input := "let x = 5;\n// comment\nreturn x;\na + b * c"
+	file := Parse(input)
+
+	program, errors := file.Program()
+	if len(errors) != 0 {
+		t.Fatalf("unexpected parser errors: %v", errors)
+	}
+
+	expected := parser.New(lexer.New(input)).ParseProgram()
+	if program.String() != expected.String() {
+		t.Errorf("program wrong. expected=%q, got=%q", expected.String(), program.String())
+	}
+
+	for i, stmt := range file.Statements {
+		if stmt.AST == nil {
+			t.Errorf("file.Statements[%d] has no ast statement", i)
+		}
+	}
+
+	// renaming an identifier in the tree should show up in the program
+	file.Statements[2].Tokens[0].Text = "alpha"
+	program, _ = file.Program()
+	if program.String() != "let x = 5;return x;(alpha + (b * c))" {
+		t.Errorf("edited program wrong. got=%q", program.String())
+	}
+}
+
+func TestTree(t *testing.T) {
+	input := "let f = fn(x) {\n\t// add one\n\tx + 1;\n\tx * 2\n};"
+	file := Parse(input)
+	stmt := file.Statements[0]
+
+	if stmt.Node == nil || stmt.Node.AST != stmt.AST {
+		t.Fatalf("wrong root node. got=%+v", stmt.Node)
+	}
+
+	text := func(n *Node) string {
+		var out strings.Builder
+		for i, tok := range stmt.Tokens[n.Start:n.End] {
+			if i > 0 {
+				out.WriteString(" ")
+			}
+			out.WriteString(tok.Text)
+		}
+		return out.String()
+	}
+
+	fn := stmt.AST.(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		// semicolons aren't in the ast, so they aren't in the nodes
+		{stmt.AST, "let f = fn ( x ) { x + 1 ; x * 2 }"},
+		{fn, "fn ( x ) { x + 1 ; x * 2 }"},
+		{fn.Body, "{ x + 1 ; x * 2 }"},
+		{fn.Body.Statements[0], "x + 1"},
+		{fn.Body.Statements[1].(*ast.ExpressionStatement).Expression, "x * 2"},
+	}
+
+	for _, tt := range tests {
+		n := stmt.Node.Find(tt.node)
+		if n == nil {
+			t.Errorf("%q: no node", tt.expected)
+			continue
+		}
+		if text(n) != tt.expected {
+			t.Errorf("wrong tokens. expected=%q, got=%q", tt.expected, text(n))
+		}
+	}
+
+	body := stmt.Node.Find(fn.Body)
+	if len(body.Children) != 2 || body.Children[0].AST != fn.Body.Statements[0] {
+		t.Errorf("expected the block's children to be its statements. got=%+v", body.Children)
+	}
+}
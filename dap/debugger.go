@@ -0,0 +1,216 @@
+package dap
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"monkey/ast"
+	"monkey/evaluator"
+)
+
+// errDisconnected stops the program when the client goes away
+var errDisconnected = errors.New("the debugger disconnected")
+
+// stepMode is how the program carries on after stopping
+type stepMode int
+
+const (
+	stepContinue stepMode = iota
+	// stepOver stops at the next statement in the same call, or in its
+	// caller once it returns
+	stepOver
+	// stepIn stops at the next statement wherever it is
+	stepIn
+	// stepOut stops once the call returns
+	stepOut
+	// stepAbort ends the program
+	stepAbort
+)
+
+// debugger decides when the program stops, and keeps what the client can
+// look at while it is stopped
+type debugger struct {
+	// mu guards the fields the request loop and the program share
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	stopped     bool
+	aborted     bool
+	frames      []*evaluator.Frame
+	refs        map[int]any
+	done        chan struct{}
+	resumed     chan stepMode
+	paused      atomic.Bool
+
+	// set up by the request loop before the program starts
+	stopOnEntry bool
+	isReady     bool
+	started     bool
+
+	// only used by the program's goroutine
+	entered   bool
+	mode      stepMode
+	stepStmt  ast.Statement
+	stepDepth int
+	lastLine  int
+	lastDepth int
+}
+
+func newDebugger() *debugger {
+	return &debugger{
+		breakpoints: map[int]bool{},
+		refs:        map[int]any{},
+		done:        make(chan struct{}),
+		resumed:     make(chan stepMode),
+	}
+}
+
+// configured is true once the client has said it has set its breakpoints
+func (d *debugger) configured() bool {
+	return d.isReady
+}
+
+func (d *debugger) setBreakpoints(lines map[int]bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = lines
+}
+
+// statement makes the hook that stops the program, telling the client
+// through s when it does
+func (d *debugger) statement(s *Server) func(stmt ast.Statement, frames []*evaluator.Frame) error {
+	return func(stmt ast.Statement, frames []*evaluator.Frame) error {
+		pos, _, _ := ast.Span(stmt)
+		reason := d.stopReason(stmt, pos.Line, len(frames))
+		d.lastLine, d.lastDepth = pos.Line, len(frames)
+
+		d.mu.Lock()
+		if d.aborted {
+			d.mu.Unlock()
+			return errDisconnected
+		}
+		if reason == "" {
+			d.mu.Unlock()
+			return nil
+		}
+		d.stopped = true
+		d.frames = append([]*evaluator.Frame{}, frames...)
+		d.refs = map[int]any{}
+		d.mu.Unlock()
+
+		body := StoppedEventBody{Reason: reason, ThreadID: threadID, AllThreadsStopped: true}
+		if err := s.event("stopped", body); err != nil {
+			fmt.Fprintln(s.log, err)
+		}
+
+		mode := <-d.resumed
+		if mode == stepAbort {
+			return errDisconnected
+		}
+		d.mode, d.stepStmt, d.stepDepth = mode, stmt, len(frames)
+		return nil
+	}
+}
+
+// stopReason gives why the program should stop before stmt, or "" if it
+// shouldn't. depth is the number of frames
+func (d *debugger) stopReason(stmt ast.Statement, line, depth int) string {
+	if !d.entered {
+		d.entered = true
+		if d.stopOnEntry {
+			return "entry"
+		}
+	}
+	if d.paused.Swap(false) {
+		return "pause"
+	}
+
+	switch d.mode {
+	case stepOver:
+		if depth < d.stepDepth || depth == d.stepDepth && stmt != d.stepStmt {
+			return "step"
+		}
+	case stepIn:
+		return "step"
+	case stepOut:
+		if depth < d.stepDepth {
+			return "step"
+		}
+	}
+
+	d.mu.Lock()
+	breakpoint := d.breakpoints[line]
+	d.mu.Unlock()
+	// several statements on a breakpoint's line only stop at the first
+	if breakpoint && (line != d.lastLine || depth != d.lastDepth) {
+		return "breakpoint"
+	}
+	return ""
+}
+
+// resume carries on running a stopped program
+func (d *debugger) resume(mode stepMode) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.mu.Unlock()
+		return errors.New("the program isn't stopped")
+	}
+	d.stopped, d.frames = false, nil
+	d.mu.Unlock()
+
+	d.resumed <- mode
+	return nil
+}
+
+func (d *debugger) pause() {
+	d.paused.Store(true)
+}
+
+// disconnect ends the program if it is running and waits for it
+func (d *debugger) disconnect() {
+	d.mu.Lock()
+	d.aborted = true
+	stopped := d.stopped
+	d.stopped, d.frames = false, nil
+	d.mu.Unlock()
+
+	if stopped {
+		d.resumed <- stepAbort
+	}
+	if d.started {
+		<-d.done
+	}
+}
+
+// stoppedFrames gives the frames the program stopped in, innermost last
+func (d *debugger) stoppedFrames() ([]*evaluator.Frame, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped {
+		return nil, errors.New("the program isn't stopped")
+	}
+	return d.frames, nil
+}
+
+// reference gives the number the client uses to ask for the variables in
+// v, which is an environment or an array. The numbers last until the
+// program carries on
+func (d *debugger) reference(v any) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for ref, known := range d.refs {
+		if known == v {
+			return ref
+		}
+	}
+	ref := len(d.refs) + 1
+	d.refs[ref] = v
+	return ref
+}
+
+func (d *debugger) referenced(ref int) any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.refs[ref]
+}
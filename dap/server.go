@@ -0,0 +1,467 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+)
+
+// The dap package is a Debug Adapter Protocol server, which lets editors
+// run a Monkey program with breakpoints, step through it a statement at a
+// time and look at the call stack and variables while it is stopped.
+//
+// The program runs on its own goroutine with a statement hook that checks
+// whether it should stop. While it is stopped the hook waits for the client
+// to say how to carry on, and the client can look at the frames the hook was
+// given, as nothing else changes them until it carries on
+
+// threadID is the only thread, Monkey programs don't have more than one
+const threadID = 1
+
+// Server handles the messages from one client
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	log io.Writer
+
+	// writes come from both the request loop and the program's goroutine
+	writeMu sync.Mutex
+	seq     int
+
+	linesStartAt1   bool
+	columnsStartAt1 bool
+
+	path     string
+	program  *ast.Program
+	launched bool
+	noDebug  bool
+	running  bool
+
+	debugger *debugger
+}
+
+// NewServer makes a server reading messages from in and writing them to out.
+// Problems that can't be sent back to the client are written to log
+func NewServer(in io.Reader, out, log io.Writer) *Server {
+	return &Server{
+		in:              bufio.NewReader(in),
+		out:             out,
+		log:             log,
+		linesStartAt1:   true,
+		columnsStartAt1: true,
+		debugger:        newDebugger(),
+	}
+}
+
+var handlers = map[string]func(s *Server, args json.RawMessage) (any, error){
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launch,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"continue":          resumeWith(stepContinue),
+	"next":              resumeWith(stepOver),
+	"stepIn":            resumeWith(stepIn),
+	"stepOut":           resumeWith(stepOut),
+	"pause":             (*Server).pause,
+}
+
+// Run handles requests until the client disconnects
+func (s *Server) Run() error {
+	for {
+		req, err := readRequest(s.in)
+		if err == io.EOF {
+			s.debugger.disconnect()
+			return errors.New("client closed the connection without disconnecting")
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.Command == "disconnect" {
+			s.debugger.disconnect()
+			return s.respond(req, nil, nil)
+		}
+
+		var body any
+		handler, ok := handlers[req.Command]
+		if ok {
+			body, err = handler(s, req.Arguments)
+		} else {
+			err = fmt.Errorf("unknown command %s", req.Command)
+		}
+		if err := s.respond(req, body, err); err != nil {
+			return err
+		}
+		if err := s.after(req.Command); err != nil {
+			return err
+		}
+	}
+}
+
+// after does what has to come after the response to a command
+func (s *Server) after(command string) error {
+	switch command {
+	case "initialize":
+		return s.event("initialized", nil)
+	case "launch", "configurationDone":
+		return s.start()
+	}
+	return nil
+}
+
+func (s *Server) respond(req *request, body any, err error) error {
+	r := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		r.Message = err.Error()
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	r.Seq = s.seq
+	return writeMessage(s.out, r)
+}
+
+func (s *Server) event(name string, body any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	return writeMessage(s.out, &event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+// decode reads the arguments of a request into v
+func decode(args json.RawMessage, v any) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func (s *Server) initialize(args json.RawMessage) (any, error) {
+	var a InitializeArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if a.LinesStartAt1 != nil {
+		s.linesStartAt1 = *a.LinesStartAt1
+	}
+	if a.ColumnsStartAt1 != nil {
+		s.columnsStartAt1 = *a.ColumnsStartAt1
+	}
+	return Capabilities{SupportsConfigurationDoneRequest: true}, nil
+}
+
+func (s *Server) launch(args json.RawMessage) (any, error) {
+	var a LaunchArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.launched {
+		return nil, errors.New("a program has already been launched")
+	}
+
+	src, err := os.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := []string{}
+		for _, e := range p.ErrorList() {
+			msgs = append(msgs, fmt.Sprintf("%s:%s", a.Program, e))
+		}
+		return nil, errors.New(strings.Join(msgs, "\n"))
+	}
+
+	s.path, s.program, s.launched = a.Program, program, true
+	s.noDebug = a.NoDebug
+	s.debugger.stopOnEntry = a.StopOnEntry && !a.NoDebug
+	return nil, nil
+}
+
+// statementLines gives the lines a statement starts on, which are the lines
+// a breakpoint can be on
+func statementLines(program *ast.Program) []int {
+	found := map[int]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if stmt, ok := n.(ast.Statement); ok {
+			if pos, _, ok := ast.Span(stmt); ok {
+				found[pos.Line] = true
+			}
+		}
+		return true
+	})
+
+	lines := []int{}
+	for line := range found {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// setBreakpoints replaces the breakpoints. A breakpoint on a line without a
+// statement on it moves down to the next line that has one
+func (s *Server) setBreakpoints(args json.RawMessage) (any, error) {
+	var a SetBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if !s.launched {
+		return nil, errors.New("no program has been launched")
+	}
+
+	lines := statementLines(s.program)
+	set := map[int]bool{}
+	breakpoints := []Breakpoint{}
+	for _, bp := range a.Breakpoints {
+		line := s.fromClientLine(bp.Line)
+		i := sort.SearchInts(lines, line)
+		if i == len(lines) {
+			breakpoints = append(breakpoints, Breakpoint{Verified: false, Message: "no code on or after this line"})
+			continue
+		}
+		set[lines[i]] = true
+		breakpoints = append(breakpoints, Breakpoint{Verified: true, Line: s.toClientLine(lines[i])})
+	}
+
+	s.debugger.setBreakpoints(set)
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+func (s *Server) configurationDone(args json.RawMessage) (any, error) {
+	s.debugger.isReady = true
+	return nil, nil
+}
+
+// start runs the program once it has been launched and the client has set
+// its breakpoints
+func (s *Server) start() error {
+	if s.running || !s.launched || !s.debugger.configured() {
+		return nil
+	}
+	s.running = true
+	s.debugger.started = true
+
+	in := evaluator.New()
+	in.Stdout = &outputWriter{s: s, category: "stdout"}
+	if !s.noDebug {
+		in.Hooks.Statement = s.debugger.statement(s)
+	}
+
+	go func() {
+		defer close(s.debugger.done)
+
+		result := in.Eval(s.program, object.NewEnvironment())
+		code := 0
+		if err, ok := result.(*object.Error); ok {
+			if errors.Is(err, errDisconnected) {
+				return
+			}
+			s.output("stderr", fmt.Sprintf("%s:%s\n", s.path, err.Error()))
+			code = 1
+		}
+
+		if err := s.event("exited", ExitedEventBody{ExitCode: code}); err != nil {
+			fmt.Fprintln(s.log, err)
+		}
+		if err := s.event("terminated", nil); err != nil {
+			fmt.Fprintln(s.log, err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) output(category, text string) {
+	if err := s.event("output", OutputEventBody{Category: category, Output: text}); err != nil {
+		fmt.Fprintln(s.log, err)
+	}
+}
+
+// outputWriter sends what the program prints to the client
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.s.output(w.category, string(p))
+	return len(p), nil
+}
+
+func (s *Server) threads(args json.RawMessage) (any, error) {
+	return map[string]any{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+}
+
+func (s *Server) stackTrace(args json.RawMessage) (any, error) {
+	var a StackTraceArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frames, err := s.debugger.stoppedFrames()
+	if err != nil {
+		return nil, err
+	}
+
+	source := Source{Name: filepath.Base(s.path), Path: s.path}
+	stack := []StackFrame{}
+	// the innermost frame comes first
+	for i := len(frames) - 1; i >= 0; i-- {
+		f := frames[i]
+		pos, _, _ := ast.Span(f.Statement)
+		stack = append(stack, StackFrame{
+			ID:     len(frames) - i,
+			Name:   f.Name,
+			Source: source,
+			Line:   s.toClientLine(pos.Line),
+			Column: s.toClientColumn(pos.Column),
+		})
+	}
+
+	total := len(stack)
+	start := min(a.StartFrame, total)
+	end := total
+	if a.Levels > 0 {
+		end = min(start+a.Levels, total)
+	}
+	return map[string]any{"stackFrames": stack[start:end], "totalFrames": total}, nil
+}
+
+// scopes gives one scope for each environment around the frame's statement,
+// leaving out the empty ones in between
+func (s *Server) scopes(args json.RawMessage) (any, error) {
+	var a ScopesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	frames, err := s.debugger.stoppedFrames()
+	if err != nil {
+		return nil, err
+	}
+	if a.FrameID < 1 || a.FrameID > len(frames) {
+		return nil, fmt.Errorf("no frame %d", a.FrameID)
+	}
+	frame := frames[len(frames)-a.FrameID]
+
+	scopes := []Scope{}
+	for env := frame.Env; env != nil; env = env.Outer() {
+		name := "Enclosing"
+		switch {
+		case env == frame.Env:
+			name = "Locals"
+		case env.Outer() == nil:
+			name = "Globals"
+		case len(env.Names()) == 0:
+			continue
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.debugger.reference(env)})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(args json.RawMessage) (any, error) {
+	var a VariablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if _, err := s.debugger.stoppedFrames(); err != nil {
+		return nil, err
+	}
+
+	variables := []Variable{}
+	switch v := s.debugger.referenced(a.VariablesReference).(type) {
+	case *object.Environment:
+		for _, name := range v.Names() {
+			obj, _ := v.Get(name)
+			variables = append(variables, s.variable(name, obj))
+		}
+	case *object.Array:
+		for i, obj := range v.Elements {
+			variables = append(variables, s.variable(fmt.Sprintf("[%d]", i), obj))
+		}
+	default:
+		return nil, fmt.Errorf("no variables with reference %d", a.VariablesReference)
+	}
+	return map[string]any{"variables": variables}, nil
+}
+
+func (s *Server) variable(name string, obj object.Object) Variable {
+	v := Variable{Name: name, Value: display(obj), Type: string(obj.Type())}
+	if array, ok := obj.(*object.Array); ok && len(array.Elements) > 0 {
+		v.VariablesReference = s.debugger.reference(array)
+	}
+	return v
+}
+
+// display gives a value the way it is shown in the variables view, which
+// has room for a line at most
+func display(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return fmt.Sprintf("%q", obj.Value)
+	case *object.Function:
+		params := []string{}
+		for _, p := range obj.Parameters {
+			params = append(params, p.Value)
+		}
+		return "fn(" + strings.Join(params, ", ") + ")"
+	}
+	return obj.Inspect()
+}
+
+// resumeWith makes the handler for a request that carries on running
+func resumeWith(mode stepMode) func(s *Server, args json.RawMessage) (any, error) {
+	return func(s *Server, args json.RawMessage) (any, error) {
+		if err := s.debugger.resume(mode); err != nil {
+			return nil, err
+		}
+		if mode == stepContinue {
+			return map[string]any{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+	}
+}
+
+func (s *Server) pause(args json.RawMessage) (any, error) {
+	s.debugger.pause()
+	return nil, nil
+}
+
+func (s *Server) toClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line - 1
+}
+
+func (s *Server) fromClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line + 1
+}
+
+func (s *Server) toClientColumn(column int) int {
+	if s.columnsStartAt1 {
+		return column
+	}
+	return column - 1
+}
@@ -0,0 +1,437 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"monkey/wire"
+)
+
+// message is a response or an event from the server
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client is a fake editor talking to a server running in the same process
+type client struct {
+	t        *testing.T
+	toServer *io.PipeWriter
+	messages chan *message
+	done     chan error
+	seq      int
+
+	// events that came while waiting for a response
+	pending []*message
+	// output is everything the program printed so far
+	output strings.Builder
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, toServer := io.Pipe()
+	fromServer, serverOut := io.Pipe()
+	c := &client{
+		t:        t,
+		toServer: toServer,
+		messages: make(chan *message),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut, io.Discard).Run()
+		serverOut.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(fromServer)
+		for {
+			body, err := wire.ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var m message
+			if err := json.Unmarshal(body, &m); err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- &m
+		}
+	}()
+	t.Cleanup(func() { toServer.Close() })
+
+	return c
+}
+
+// launch starts a client running src from a file, stopping on the first
+// statement if stopOnEntry is set. The program doesn't run until configured
+// is called
+func launch(t *testing.T, src string, stopOnEntry bool) (*client, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.mk")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	var caps Capabilities
+	if msg := c.request("initialize", map[string]any{"adapterID": "monkey"}, &caps); msg != "" {
+		t.Fatalf("initialize failed: %s", msg)
+	}
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Errorf("expected configurationDone to be supported. got=%+v", caps)
+	}
+	c.event("initialized")
+
+	args := LaunchArguments{Program: path, StopOnEntry: stopOnEntry}
+	if msg := c.request("launch", args, nil); msg != "" {
+		t.Fatalf("launch failed: %s", msg)
+	}
+	return c, path
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// request sends a request and waits for the response, decoding its body
+// into body. It gives the error message if the request failed
+func (c *client) request(command string, args any, body any) string {
+	c.t.Helper()
+	data, err := json.Marshal(args)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.seq++
+	req := request{Seq: c.seq, Type: "request", Command: command, Arguments: data}
+	if err := writeMessage(c.toServer, &req); err != nil {
+		c.t.Fatalf("sending %s: %v", command, err)
+	}
+
+	for {
+		m := c.receive()
+		if m.Type == "event" {
+			c.pending = append(c.pending, m)
+			continue
+		}
+		if m.RequestSeq != req.Seq || m.Command != command {
+			c.t.Fatalf("response for %s is for %s (%d), expected %d", command, m.Command, m.RequestSeq, req.Seq)
+		}
+		if !m.Success {
+			if m.Message == "" {
+				c.t.Fatalf("failed response for %s has no message", command)
+			}
+			return m.Message
+		}
+		if body != nil {
+			if err := json.Unmarshal(m.Body, body); err != nil {
+				c.t.Fatalf("decoding body of %s: %v", command, err)
+			}
+		}
+		return ""
+	}
+}
+
+// event waits for the next event called name, keeping the output from any
+// output events before it
+func (c *client) event(name string) json.RawMessage {
+	c.t.Helper()
+	for {
+		var m *message
+		if len(c.pending) > 0 {
+			m, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			m = c.receive()
+		}
+		if m.Type != "event" {
+			c.t.Fatalf("expected the %s event, got a response to %s", name, m.Command)
+		}
+		if m.Event == "output" {
+			var body OutputEventBody
+			if err := json.Unmarshal(m.Body, &body); err != nil {
+				c.t.Fatal(err)
+			}
+			c.output.WriteString(body.Category + ": " + body.Output)
+		}
+		if m.Event == name {
+			return m.Body
+		}
+	}
+}
+
+// stopped waits for the program to stop, giving the reason
+func (c *client) stopped() string {
+	c.t.Helper()
+	var body StoppedEventBody
+	if err := json.Unmarshal(c.event("stopped"), &body); err != nil {
+		c.t.Fatal(err)
+	}
+	if body.ThreadID != threadID {
+		c.t.Errorf("stopped on thread %d", body.ThreadID)
+	}
+	return body.Reason
+}
+
+// exited waits for the program to end, giving its exit code
+func (c *client) exited() int {
+	c.t.Helper()
+	var body ExitedEventBody
+	if err := json.Unmarshal(c.event("exited"), &body); err != nil {
+		c.t.Fatal(err)
+	}
+	c.event("terminated")
+	return body.ExitCode
+}
+
+func (c *client) disconnect() {
+	c.t.Helper()
+	if msg := c.request("disconnect", nil, nil); msg != "" {
+		c.t.Fatalf("disconnect failed: %s", msg)
+	}
+	if err := <-c.done; err != nil {
+		c.t.Errorf("expected a clean exit. got=%v", err)
+	}
+}
+
+// stack gives the frames as "name line:column", innermost first
+func (c *client) stack() []string {
+	c.t.Helper()
+	var body struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+		TotalFrames int          `json:"totalFrames"`
+	}
+	if msg := c.request("stackTrace", StackTraceArguments{ThreadID: threadID}, &body); msg != "" {
+		c.t.Fatalf("stackTrace failed: %s", msg)
+	}
+	frames := []string{}
+	for _, f := range body.StackFrames {
+		frames = append(frames, f.Name+" "+itoa(f.Line)+":"+itoa(f.Column))
+	}
+	return frames
+}
+
+// scopes gives the variables in each scope of a frame as "scope: name=value"
+func (c *client) scopes(frame int) []string {
+	c.t.Helper()
+	var body struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	if msg := c.request("scopes", ScopesArguments{FrameID: frame}, &body); msg != "" {
+		c.t.Fatalf("scopes failed: %s", msg)
+	}
+	got := []string{}
+	for _, scope := range body.Scopes {
+		for _, v := range c.variables(scope.VariablesReference) {
+			got = append(got, scope.Name+": "+v.Name+"="+v.Value)
+		}
+	}
+	return got
+}
+
+func (c *client) variables(ref int) []Variable {
+	c.t.Helper()
+	var body struct {
+		Variables []Variable `json:"variables"`
+	}
+	if msg := c.request("variables", VariablesArguments{VariablesReference: ref}, &body); msg != "" {
+		c.t.Fatalf("variables failed: %s", msg)
+	}
+	return body.Variables
+}
+
+func itoa(i int) string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+func check(t *testing.T, what string, got, expected []string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong %s.\nexpected=%q\ngot=%q", what, expected, got)
+	}
+}
+
+const program = `let double = fn(x) {
+	let y = x * 2;
+	y
+};
+
+let a = double(1);
+puts(a);
+let b = [a, "s"];
+puts(b[1]);
+`
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c, _ := launch(t, program, false)
+
+	var bps struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	args := SetBreakpointsArguments{Breakpoints: []SourceBreakpoint{{Line: 2}, {Line: 5}, {Line: 9}, {Line: 20}}}
+	if msg := c.request("setBreakpoints", args, &bps); msg != "" {
+		t.Fatalf("setBreakpoints failed: %s", msg)
+	}
+	expected := []Breakpoint{
+		{Verified: true, Line: 2},
+		// there's nothing on line 5, so it moves down to 6
+		{Verified: true, Line: 6},
+		{Verified: true, Line: 9},
+		{Verified: false, Message: "no code on or after this line"},
+	}
+	if !reflect.DeepEqual(bps.Breakpoints, expected) {
+		t.Errorf("wrong breakpoints.\nexpected=%+v\ngot=%+v", expected, bps.Breakpoints)
+	}
+
+	if msg := c.request("continue", nil, nil); msg != "the program isn't stopped" {
+		t.Errorf("expected continue to fail before the program runs. got=%q", msg)
+	}
+	if msg := c.request("configurationDone", nil, nil); msg != "" {
+		t.Fatalf("configurationDone failed: %s", msg)
+	}
+
+	if reason := c.stopped(); reason != "breakpoint" {
+		t.Errorf("expected to stop at a breakpoint. got=%s", reason)
+	}
+	check(t, "stack", c.stack(), []string{"main 6:1"})
+
+	c.request("continue", nil, nil)
+	if reason := c.stopped(); reason != "breakpoint" {
+		t.Errorf("expected to stop at a breakpoint. got=%s", reason)
+	}
+	check(t, "stack", c.stack(), []string{"double 2:2", "main 6:1"})
+	check(t, "variables", c.scopes(1), []string{"Locals: x=1", "Globals: double=fn(x)"})
+
+	c.request("next", nil, nil)
+	if reason := c.stopped(); reason != "step" {
+		t.Errorf("expected to stop after a step. got=%s", reason)
+	}
+	check(t, "stack", c.stack(), []string{"double 3:2", "main 6:1"})
+	check(t, "variables", c.scopes(1), []string{"Locals: x=1", "Locals: y=2", "Globals: double=fn(x)"})
+	check(t, "caller's variables", c.scopes(2), []string{"Locals: double=fn(x)"})
+
+	c.request("stepOut", nil, nil)
+	c.stopped()
+	check(t, "stack", c.stack(), []string{"main 7:1"})
+
+	c.request("next", nil, nil)
+	c.stopped()
+	check(t, "stack", c.stack(), []string{"main 8:1"})
+	if c.output.String() != "stdout: 2\n" {
+		t.Errorf("wrong output. got=%q", c.output.String())
+	}
+
+	c.request("continue", nil, nil)
+	if reason := c.stopped(); reason != "breakpoint" {
+		t.Errorf("expected to stop at a breakpoint. got=%s", reason)
+	}
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.request("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	vars := c.variables(scopes.Scopes[0].VariablesReference)
+	if len(vars) != 3 || vars[1].Name != "b" || vars[1].Value != `[2, "s"]` || vars[1].VariablesReference == 0 {
+		t.Fatalf("wrong variables. got=%+v", vars)
+	}
+	elements := c.variables(vars[1].VariablesReference)
+	expectedElements := []Variable{
+		{Name: "[0]", Value: "2", Type: "INTEGER"},
+		{Name: "[1]", Value: `"s"`, Type: "STRING"},
+	}
+	if !reflect.DeepEqual(elements, expectedElements) {
+		t.Errorf("wrong elements.\nexpected=%+v\ngot=%+v", expectedElements, elements)
+	}
+
+	c.request("continue", nil, nil)
+	if code := c.exited(); code != 0 {
+		t.Errorf("expected exit code 0. got=%d", code)
+	}
+	if c.output.String() != "stdout: 2\nstdout: s\n" {
+		t.Errorf("wrong output. got=%q", c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestStepIn(t *testing.T) {
+	c, _ := launch(t, program, true)
+	c.request("configurationDone", nil, nil)
+	if reason := c.stopped(); reason != "entry" {
+		t.Errorf("expected to stop on entry. got=%s", reason)
+	}
+	check(t, "stack", c.stack(), []string{"main 1:1"})
+
+	lines := []string{}
+	for range 4 {
+		c.request("stepIn", nil, nil)
+		c.stopped()
+		lines = append(lines, c.stack()[0])
+	}
+	check(t, "steps", lines, []string{"main 6:1", "double 2:2", "double 3:2", "main 7:1"})
+	c.disconnect()
+}
+
+func TestRuntimeError(t *testing.T) {
+	c, path := launch(t, "let x = 1;\nputs(x);\nx + true;\n", false)
+	c.request("configurationDone", nil, nil)
+
+	if code := c.exited(); code != 1 {
+		t.Errorf("expected exit code 1. got=%d", code)
+	}
+	expected := "stdout: 1\nstderr: " + path + ":3:1: type mismatch: INTEGER + BOOLEAN\n"
+	if c.output.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, c.output.String())
+	}
+	c.disconnect()
+}
+
+func TestDisconnectWhileStopped(t *testing.T) {
+	c, _ := launch(t, program, true)
+	c.request("configurationDone", nil, nil)
+	c.stopped()
+	c.disconnect()
+	if c.output.String() != "" {
+		t.Errorf("expected the program not to carry on. got=%q", c.output.String())
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.mk")
+	if err := os.WriteFile(path, []byte("let x = ;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+	if msg := c.request("launch", LaunchArguments{Program: path}, nil); msg != path+":1:9: no prefix parse function for ; found" {
+		t.Errorf("wrong error for a program that doesn't parse. got=%q", msg)
+	}
+	if msg := c.request("evaluate", nil, nil); msg != "unknown command evaluate" {
+		t.Errorf("wrong error for an unknown command. got=%q", msg)
+	}
+	c.disconnect()
+}
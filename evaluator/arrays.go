@@ -0,0 +1,288 @@
+package evaluator
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"monkey/object"
+)
+
+// arrayBuiltins are the builtins for working with arrays. None of them
+// change the arrays they are given, they make new ones instead. The ones
+// taking a function call it the same way the program would, so it can be a
+// closure or a builtin
+func (in *Interpreter) arrayBuiltins() map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"map": {Name: "map", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("map", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+			elements := arrayArg(args, 0)
+			result := make([]object.Object, len(elements))
+			for i, e := range elements {
+				val := in.Call(args[1], e)
+				if isError(val) {
+					return val
+				}
+				result[i] = val
+			}
+			return &object.Array{Elements: result}
+		}},
+
+		"filter": {Name: "filter", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("filter", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+			result := []object.Object{}
+			for _, e := range arrayArg(args, 0) {
+				keep := in.Call(args[1], e)
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					result = append(result, e)
+				}
+			}
+			return &object.Array{Elements: result}
+		}},
+
+		"reduce": {Name: "reduce", Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return wrongArguments("reduce", 3, len(args))
+			}
+			if err := checkArgs("reduce", []object.Object{args[0], args[2]}, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+			acc := args[1]
+			for _, e := range arrayArg(args, 0) {
+				acc = in.Call(args[2], acc, e)
+				if isError(acc) {
+					return acc
+				}
+			}
+			return acc
+		}},
+
+		"sort": {Name: "sort", Fn: func(args ...object.Object) object.Object {
+			if len(args) == 1 {
+				if err := checkArgs("sort", args, object.ARRAY_OBJ); err != nil {
+					return err
+				}
+				return sortArray(arrayArg(args, 0))
+			}
+			if err := checkArgs("sort", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+			return in.sortArrayBy(arrayArg(args, 0), args[1])
+		}},
+
+		"reverse": {Name: "reverse", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("reverse", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			result := slices.Clone(arrayArg(args, 0))
+			slices.Reverse(result)
+			return &object.Array{Elements: result}
+		}},
+
+		"zip": {Name: "zip", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("zip", args, object.ARRAY_OBJ, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			a, b := arrayArg(args, 0), arrayArg(args, 1)
+			result := make([]object.Object, min(len(a), len(b)))
+			for i := range result {
+				result[i] = &object.Array{Elements: []object.Object{a[i], b[i]}}
+			}
+			return &object.Array{Elements: result}
+		}},
+
+		"range": {Name: "range", Fn: in.rangeBuiltin},
+
+		"first": {Name: "first", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("first", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			if elements := arrayArg(args, 0); len(elements) > 0 {
+				return elements[0]
+			}
+			return NULL
+		}},
+
+		"rest": {Name: "rest", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("rest", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			if elements := arrayArg(args, 0); len(elements) > 0 {
+				return &object.Array{Elements: slices.Clone(elements[1:])}
+			}
+			return NULL
+		}},
+
+		"push": {Name: "push", Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return wrongArguments("push", 2, len(args))
+			}
+			if err := checkArgs("push", args[:1], object.ARRAY_OBJ); err != nil {
+				return err
+			}
+			elements := arrayArg(args, 0)
+			return &object.Array{Elements: append(slices.Clip(elements), args[1])}
+		}},
+
+		"concat": {Name: "concat", Fn: func(args ...object.Object) object.Object {
+			result := []object.Object{}
+			for i, arg := range args {
+				array, ok := arg.(*object.Array)
+				if !ok {
+					return &object.Error{Message: fmt.Sprintf("argument %d to `concat` must be ARRAY, got %s", i+1, arg.Type())}
+				}
+				result = append(result, array.Elements...)
+			}
+			return &object.Array{Elements: result}
+		}},
+
+		"any": {Name: "any", Fn: func(args ...object.Object) object.Object {
+			return in.findTruthy("any", args, true)
+		}},
+
+		"all": {Name: "all", Fn: func(args ...object.Object) object.Object {
+			return in.findTruthy("all", args, false)
+		}},
+	}
+}
+
+// arrayArg gives the elements of argument i, which checkArgs has made sure
+// is an array
+func arrayArg(args []object.Object, i int) []object.Object {
+	return args[i].(*object.Array).Elements
+}
+
+// findTruthy is any and all: it calls the function given to name for each
+// element until one gives want, which is what it then gives
+func (in *Interpreter) findTruthy(name string, args []object.Object, want bool) object.Object {
+	if err := checkArgs(name, args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+		return err
+	}
+	for _, e := range arrayArg(args, 0) {
+		val := in.Call(args[1], e)
+		if isError(val) {
+			return val
+		}
+		if isTruthy(val) == want {
+			return nativeBoolToBooleanObject(want)
+		}
+	}
+	return nativeBoolToBooleanObject(!want)
+}
+
+// sortArray sorts elements that are all integers or all strings
+func sortArray(elements []object.Object) object.Object {
+	result := slices.Clone(elements)
+	if len(result) == 0 {
+		return &object.Array{Elements: result}
+	}
+
+	t := result[0].Type()
+	for _, e := range result {
+		if e.Type() != t || t != object.INTEGER_OBJ && t != object.STRING_OBJ {
+			return &object.Error{Message: fmt.Sprintf("`sort` can only compare INTEGER or STRING values without a function, got %s and %s", t, e.Type())}
+		}
+	}
+
+	slices.SortStableFunc(result, func(a, b object.Object) int {
+		if t == object.INTEGER_OBJ {
+			return cmp.Compare(a.(*object.Integer).Value, b.(*object.Integer).Value)
+		}
+		return cmp.Compare(a.(*object.String).Value, b.(*object.String).Value)
+	})
+	return &object.Array{Elements: result}
+}
+
+// sortArrayBy sorts elements with less, a function giving whether its first
+// argument comes before its second
+func (in *Interpreter) sortArrayBy(elements []object.Object, less object.Object) object.Object {
+	result := slices.Clone(elements)
+	var failed object.Object
+
+	compare := func(a, b object.Object) (bool, bool) {
+		val := in.Call(less, a, b)
+		if isError(val) {
+			failed = val
+			return false, false
+		}
+		before, ok := val.(*object.Boolean)
+		if !ok {
+			failed = &object.Error{Message: fmt.Sprintf("function given to `sort` must give BOOLEAN, got %s", val.Type())}
+			return false, false
+		}
+		return before.Value, true
+	}
+
+	slices.SortStableFunc(result, func(a, b object.Object) int {
+		if failed != nil {
+			return 0
+		}
+		if before, ok := compare(a, b); !ok || before {
+			return -1
+		}
+		if after, ok := compare(b, a); ok && after {
+			return 1
+		}
+		return 0
+	})
+
+	if failed != nil {
+		return failed
+	}
+	return &object.Array{Elements: result}
+}
+
+// rangeBuiltin gives the integers from start up to but not including end,
+// going up by step: range(end), range(start, end) or range(start, end, step)
+func (in *Interpreter) rangeBuiltin(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `range`: want 1 to 3, got=%d", len(args))}
+	}
+	bounds := []int64{0, 0, 1}
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return &object.Error{Message: fmt.Sprintf("argument %d to `range` must be INTEGER, got %s", i+1, arg.Type())}
+		}
+		bounds[i] = n.Value
+	}
+	if len(args) == 1 {
+		bounds[0], bounds[1] = 0, bounds[0]
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return &object.Error{Message: "step given to `range` can't be 0"}
+	}
+
+	// the distance is worked out unsigned, as it can be too big for an int64
+	var distance, stride uint64 = 0, 1
+	if step > 0 && end > start {
+		distance, stride = uint64(end)-uint64(start), uint64(step)
+	} else if step < 0 && start > end {
+		distance, stride = uint64(start)-uint64(end), -uint64(step)
+	}
+	count := distance / stride
+	if distance%stride != 0 {
+		count++
+	}
+	// check before making the array, which could be far too big
+	if count > 1<<31 {
+		return &object.Error{Message: "result of `range` is too long"}
+	}
+	if err := in.checkAlloc(8 * int(count)); err != nil {
+		return err
+	}
+
+	elements := make([]object.Object, count)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: start + int64(i)*step}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,118 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+
+	"monkey/object"
+)
+
+func TestArrayBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", []any{2, 4, 6}},
+		{"map([], fn(x) { x })", []any{}},
+		{`map(["a", "bc"], len)`, []any{1, 2}},
+		{"let n = 10; map([1, 2], fn(x) { x + n })", []any{11, 12}},
+		{"filter([1, 2, 3, 4], fn(x) { x > 2 })", []any{3, 4}},
+		{"filter([1, 2], fn(x) { false })", []any{}},
+		{"reduce([1, 2, 3, 4], 0, fn(acc, x) { acc + x })", 10},
+		{"reduce([], 5, fn(acc, x) { acc + x })", 5},
+		{`reduce(["a", "b"], "", fn(acc, x) { x + acc })`, "ba"},
+		{"sort([3, 1, 2])", []any{1, 2, 3}},
+		{`sort(["b", "c", "a"])`, []any{"a", "b", "c"}},
+		{"sort([])", []any{}},
+		{"sort([3, 1, 2], fn(a, b) { a > b })", []any{3, 2, 1}},
+		// sorting is stable
+		{`sort(["bb", "a", "cc", "d"], fn(a, b) { len(a) < len(b) })`, []any{"a", "d", "bb", "cc"}},
+		{"reverse([1, 2, 3])", []any{3, 2, 1}},
+		{"zip([1, 2, 3], [true, false])", []any{[]any{1, true}, []any{2, false}}},
+		{"range(4)", []any{0, 1, 2, 3}},
+		{"range(2, 5)", []any{2, 3, 4}},
+		{"range(0, 10, 4)", []any{0, 4, 8}},
+		{"range(5, 0, -2)", []any{5, 3, 1}},
+		{"range(5, 0)", []any{}},
+		{"range(-9223372036854775807, 9223372036854775807, 9223372036854775807)", []any{-9223372036854775807, 0}},
+		{"first([1, 2])", 1},
+		{"first([])", nil},
+		{"rest([1, 2, 3])", []any{2, 3}},
+		{"rest([1])", []any{}},
+		{"rest([])", nil},
+		{"push([1], 2)", []any{1, 2}},
+		{"concat([1], [], [2, 3])", []any{1, 2, 3}},
+		{"concat()", []any{}},
+		{"any([1, 2, 3], fn(x) { x > 2 })", true},
+		{"any([], fn(x) { true })", false},
+		{"all([1, 2, 3], fn(x) { x > 0 })", true},
+		{"all([1, 2, 3], fn(x) { x > 1 })", false},
+		{"all([], fn(x) { false })", true},
+		// a recursive function using them
+		{`let flatten = fn(xs) { reduce(xs, [], fn(acc, x) { concat(acc, if (len(x) > 0) { x } else { [] }) }) };
+		  flatten([[1], [], [2, 3]])`, []any{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestArrayBuiltinsDontChangeArrays(t *testing.T) {
+	input := `let a = [3, 1, 2];
+let b = push(a, 4);
+let c = push(a, 5);
+sort(a); reverse(a); rest(a); map(a, fn(x) { x * 2 }); concat(a, b);
+[a, b, c]`
+	expected := []any{[]any{3, 1, 2}, []any{3, 1, 2, 4}, []any{3, 1, 2, 5}}
+	checkObject(t, input, testEval(t, input), expected)
+}
+
+func TestArrayBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map(1, fn(x) { x })", "1:1: argument 1 to `map` must be ARRAY, got INTEGER"},
+		{"map([1], 1)", "1:1: argument 2 to `map` must be FUNCTION, got INTEGER"},
+		{"filter([1])", "1:1: wrong number of arguments to `filter`: want=2, got=1"},
+		{"reduce([1], fn(a, x) { a })", "1:1: wrong number of arguments to `reduce`: want=3, got=2"},
+		{"reduce([1], 0, 0)", "1:1: argument 2 to `reduce` must be FUNCTION, got INTEGER"},
+		{`sort([1, "a"])`, "1:1: `sort` can only compare INTEGER or STRING values without a function, got INTEGER and STRING"},
+		{"sort([true, false])", "1:1: `sort` can only compare INTEGER or STRING values without a function, got BOOLEAN and BOOLEAN"},
+		{"sort([1, 2], fn(a, b) { 1 })", "1:1: function given to `sort` must give BOOLEAN, got INTEGER"},
+		{"zip([1], 2)", "1:1: argument 2 to `zip` must be ARRAY, got INTEGER"},
+		{"range()", "1:1: wrong number of arguments to `range`: want 1 to 3, got=0"},
+		{`range("a")`, "1:1: argument 1 to `range` must be INTEGER, got STRING"},
+		{"range(0, 5, 0)", "1:1: step given to `range` can't be 0"},
+		{"range(9223372036854775807)", "1:1: result of `range` is too long"},
+		{"push(1, 2)", "1:1: argument 1 to `push` must be ARRAY, got INTEGER"},
+		{"concat([1], 2)", "1:1: argument 2 to `concat` must be ARRAY, got INTEGER"},
+		{"any([1], fn() { true })", "1:1: wrong number of arguments: want=0, got=1"},
+		// the function's own errors keep their position
+		{"let x = 1;\nmap([1, 2], fn(x) {\n  x + true\n})", "3:3: type mismatch: INTEGER + BOOLEAN"},
+		// a builtin's error is at the call that gave it the builtin
+		{"let x = 1;\nlet y = map([1], len);", "2:9: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(t, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestArrayBuiltinLimits(t *testing.T) {
+	in := New()
+	in.Limits = Limits{MaxAlloc: 1000}
+	result := testEvalWith(t, in, "range(1000000)")
+	var alloc *AllocLimitError
+	if err, ok := result.(*object.Error); !ok || !errors.As(err, &alloc) {
+		t.Errorf("expected an allocation limit error. got=%v", result)
+	}
+}
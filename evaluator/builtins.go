@@ -0,0 +1,61 @@
+package evaluator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf8"
+
+	"monkey/object"
+)
+
+// builtins gives the standard builtins. They are made for each interpreter
+// as some of them use it, like puts writing to its Stdout
+func (in *Interpreter) builtins() map[string]*object.Builtin {
+	builtins := map[string]*object.Builtin{
+		"len": {Name: "len", Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return wrongArguments("len", 1, len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
+			}
+			return &object.Error{Message: fmt.Sprintf("argument to `len` not supported, got %s", args[0].Type())}
+		}},
+
+		"puts": {Name: "puts", Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				s, err := in.inspect(arg, false)
+				if err != nil {
+					return err
+				}
+				if err := in.output(s); err != nil {
+					return err
+				}
+				fmt.Fprintln(in.Stdout, s)
+			}
+			return NULL
+		}},
+	}
+
+	maps.Copy(builtins, in.stringBuiltins())
+	maps.Copy(builtins, in.arrayBuiltins())
+	maps.Copy(builtins, in.jsonBuiltins())
+	maps.Copy(builtins, in.ioBuiltins())
+	return builtins
+}
+
+// BuiltinNames gives the names of the standard builtins, sorted, for tools
+// that look at programs without running them
+func BuiltinNames() []string {
+	return slices.Sorted(maps.Keys(New().builtins()))
+}
+
+func wrongArguments(name string, want, got int) *object.Error {
+	return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `%s`: want=%d, got=%d", name, want, got)}
+}
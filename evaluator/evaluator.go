@@ -0,0 +1,536 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// The evaluator package runs programs by walking their AST. Scopes follow
+// the resolver: the program, each function call and each branch of an if
+// get their own environment.
+//
+// An Interpreter can be followed as it runs through its Hooks, which is how
+// the debugger stops at breakpoints, and Frames tells it which functions are
+// running and with which variables
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// Hooks are called as a program runs, any of them can be left nil
+type Hooks struct {
+	// Statement is called before each statement runs, with the innermost
+	// frame last. Returning an error stops the program with it
+	Statement func(stmt ast.Statement, frames []*Frame) error
+}
+
+// Frame is a function call that hasn't returned yet, or the program itself
+// for the outermost one
+type Frame struct {
+	// Name is the name the function was called by, or "fn" when it wasn't
+	// called through a name, and "main" for the program
+	Name string
+	// Function is nil for the program
+	Function *object.Function
+	// Call is the call expression that made the frame, nil for the program
+	Call *ast.CallExpression
+	// Statement is the statement running in the frame and Env is the
+	// innermost scope it is running in
+	Statement ast.Statement
+	Env       *object.Environment
+}
+
+type Interpreter struct {
+	// Builtins are the names every program can use without declaring them
+	Builtins map[string]*object.Builtin
+	Hooks    Hooks
+	Limits   Limits
+	// Stdout is where puts, print and println write to
+	Stdout io.Writer
+	// IO is what read_file, write_file, list_dir and read_line use, they
+	// fail with ErrNoIO when it is nil
+	IO IO
+
+	frames []*Frame
+
+	// what the running program is counted against, set when it starts
+	running   bool
+	ctx       context.Context
+	done      <-chan struct{}
+	steps     int
+	depth     int
+	allocated int
+}
+
+// New makes an interpreter with the standard builtins, writing to os.Stdout
+func New() *Interpreter {
+	in := &Interpreter{Stdout: os.Stdout}
+	in.Builtins = in.builtins()
+	return in
+}
+
+// Frames gives the calls running right now, with the innermost last. It is
+// meant to be called from a hook
+func (in *Interpreter) Frames() []*Frame {
+	return in.frames
+}
+
+// Eval runs node in env. A program that fails gives an *object.Error
+func (in *Interpreter) Eval(node ast.Node, env *object.Environment) object.Object {
+	return in.EvalContext(context.Background(), node, env)
+}
+
+// EvalContext is Eval, stopping the program with ctx's error once ctx is
+// done. Called from a builtin while a program is running, it carries on
+// with the context and limits of that program
+func (in *Interpreter) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	if !in.running {
+		defer in.start(ctx)()
+		in.frames = append(in.frames, &Frame{Name: "main", Env: env})
+	}
+
+	result := in.eval(node, env)
+	if rv, ok := result.(*object.ReturnValue); ok {
+		return rv.Value
+	}
+	return result
+}
+
+// Call calls fn with args the same way a call expression in the program
+// would, for Go code calling back into a program
+func (in *Interpreter) Call(fn object.Object, args ...object.Object) object.Object {
+	return in.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext is Call, with a context in the same way as EvalContext
+func (in *Interpreter) CallContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	if !in.running {
+		defer in.start(ctx)()
+	}
+	return in.applyFunction(nil, fn, args)
+}
+
+// start sets up for running a program, giving the func that cleans up
+// after it
+func (in *Interpreter) start(ctx context.Context) func() {
+	in.running, in.ctx, in.done = true, ctx, ctx.Done()
+	in.steps, in.depth, in.allocated = 0, 0, 0
+	return func() {
+		in.running, in.ctx, in.done = false, nil, nil
+		in.frames = in.frames[:0]
+	}
+}
+
+func (in *Interpreter) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := in.step(node); err != nil {
+		return err
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		return in.evalStatements(node.Statements, env)
+
+	case *ast.BlockStatement:
+		return in.evalStatements(node.Statements, object.NewEnclosedEnvironment(env))
+
+	case *ast.ExpressionStatement:
+		return in.eval(node.Expression, env)
+
+	case *ast.LetStatement:
+		val := in.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.Name.Value, val)
+		return NULL
+
+	case *ast.ReturnStatement:
+		val := in.eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *ast.InterpolatedString:
+		return in.evalInterpolatedString(node, env)
+
+	case *ast.PrefixExpression:
+		right := in.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node, right)
+
+	case *ast.InfixExpression:
+		left := in.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		right := in.eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return in.alloc(node, evalInfixExpression(node, left, right))
+
+	case *ast.IfExpression:
+		return in.evalIfExpression(node, env)
+
+	case *ast.Identifier:
+		return in.evalIdentifier(node, env)
+
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env, Literal: node}
+
+	case *ast.CallExpression:
+		function := in.eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := in.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return in.applyFunction(node, function, args)
+
+	case *ast.ArrayLiteral:
+		elements := in.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return in.alloc(node, &object.Array{Elements: elements})
+
+	case *ast.IndexExpression:
+		left := in.eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := in.eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(node, left, index)
+	}
+
+	return newError(node, "cannot evaluate %T", node)
+}
+
+// evalStatements runs the statements of a program or block, stopping at a
+// return or an error. A block's value is the value of its last statement
+func (in *Interpreter) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	frame := in.frames[len(in.frames)-1]
+
+	for _, stmt := range stmts {
+		frame.Statement, frame.Env = stmt, env
+		if in.Hooks.Statement != nil {
+			if err := in.Hooks.Statement(stmt, in.frames); err != nil {
+				return wrapError(stmt, err)
+			}
+		}
+
+		result = in.eval(stmt, env)
+		switch result.(type) {
+		case *object.ReturnValue, *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+func (in *Interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+
+	for _, e := range exps {
+		evaluated := in.eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+func (in *Interpreter) evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	p := &inspector{in: in}
+
+	for i, lit := range node.Literals {
+		p.out.WriteString(lit)
+		if i < len(node.Expressions) {
+			val := in.eval(node.Expressions[i], env)
+			if isError(val) {
+				return val
+			}
+			if err := p.write(val, false); err != nil {
+				return wrapError(node, err.Err)
+			}
+		}
+	}
+
+	return in.alloc(node, &object.String{Value: p.out.String()})
+}
+
+func (in *Interpreter) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := in.eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return in.eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return in.eval(ie.Alternative, env)
+	}
+	return NULL
+}
+
+func (in *Interpreter) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := in.Builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError(node, "identifier not found: %s", node.Value)
+}
+
+// applyFunction calls fn for call, which is nil when Go code called it
+func (in *Interpreter) applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError(call, "wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+
+		env := object.NewEnclosedEnvironment(fn.Env)
+		for i, param := range fn.Parameters {
+			env.Set(param.Value, args[i])
+		}
+
+		name := "fn"
+		if call != nil {
+			if ident, ok := call.Function.(*ast.Identifier); ok {
+				name = ident.Value
+			}
+		}
+		if in.Limits.MaxDepth > 0 && in.depth >= in.Limits.MaxDepth {
+			return wrapError(call, &DepthLimitError{Limit: in.Limits.MaxDepth})
+		}
+		in.depth++
+		in.frames = append(in.frames, &Frame{Name: name, Function: fn, Call: call, Env: env})
+		result := in.evalStatements(fn.Body.Statements, env)
+		in.frames = in.frames[:len(in.frames)-1]
+		in.depth--
+
+		if rv, ok := result.(*object.ReturnValue); ok {
+			return rv.Value
+		}
+		return result
+
+	case *object.Builtin:
+		result := fn.Fn(args...)
+		// builtins don't know where they were called from
+		if err, ok := result.(*object.Error); ok && err.Pos.Line == 0 {
+			err.Pos = position(call)
+		}
+		return in.alloc(call, result)
+	}
+
+	return newError(call, "not a function: %s", fn.Type())
+}
+
+func evalPrefixExpression(node *ast.PrefixExpression, right object.Object) object.Object {
+	switch node.Operator {
+	case "!":
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case "-":
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		}
+		return newError(node, "unknown operator: -%s", right.Type())
+	}
+	return newError(node, "unknown operator: %s%s", node.Operator, right.Type())
+}
+
+func evalInfixExpression(node *ast.InfixExpression, left, right object.Object) object.Object {
+	operator := node.Operator
+
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(node, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(node, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(node, left.(*object.String).Value, right.(*object.String).Value)
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	case left.Type() != right.Type():
+		return newError(node, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+	return newError(node, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalIntegerInfixExpression(node *ast.InfixExpression, left, right int64) object.Object {
+	switch node.Operator {
+	case "+":
+		return &object.Integer{Value: left + right}
+	case "-":
+		return &object.Integer{Value: left - right}
+	case "*":
+		return &object.Integer{Value: left * right}
+	case "/":
+		if right == 0 {
+			return newError(node, "division by zero")
+		}
+		return &object.Integer{Value: left / right}
+	case "<":
+		return nativeBoolToBooleanObject(left < right)
+	case ">":
+		return nativeBoolToBooleanObject(left > right)
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+	return newError(node, "unknown operator: INTEGER %s INTEGER", node.Operator)
+}
+
+// evalFloatInfixExpression is for when either side is a float, the other
+// side being turned into one if it is an integer
+func evalFloatInfixExpression(node *ast.InfixExpression, left, right float64) object.Object {
+	switch node.Operator {
+	case "+":
+		return &object.Float{Value: left + right}
+	case "-":
+		return &object.Float{Value: left - right}
+	case "*":
+		return &object.Float{Value: left * right}
+	case "/":
+		if right == 0 {
+			return newError(node, "division by zero")
+		}
+		return &object.Float{Value: left / right}
+	case "<":
+		return nativeBoolToBooleanObject(left < right)
+	case ">":
+		return nativeBoolToBooleanObject(left > right)
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+	return newError(node, "unknown operator: FLOAT %s FLOAT", node.Operator)
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat gives the value of an integer or float as a float
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func evalStringInfixExpression(node *ast.InfixExpression, left, right string) object.Object {
+	switch node.Operator {
+	case "+":
+		return &object.String{Value: left + right}
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+	return newError(node, "unknown operator: STRING %s STRING", node.Operator)
+}
+
+func evalIndexExpression(node *ast.IndexExpression, left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		return evalArrayIndexExpression(node, left, index)
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(node, "unusable as hash key: %s", index.Type())
+		}
+		if val, ok := left.Get(key); ok {
+			return val
+		}
+		return NULL
+	}
+	return newError(node, "index operator not supported: %s", left.Type())
+}
+
+func evalArrayIndexExpression(node *ast.IndexExpression, array *object.Array, index object.Object) object.Object {
+	i, ok := index.(*object.Integer)
+	if !ok {
+		return newError(node, "array index must be INTEGER, got %s", index.Type())
+	}
+
+	if i.Value < 0 || i.Value >= int64(len(array.Elements)) {
+		return NULL
+	}
+	return array.Elements[i.Value]
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+// isTruthy is false for false and null, everything else counts as true
+func isTruthy(obj object.Object) bool {
+	return obj != NULL && obj != FALSE
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func newError(node ast.Node, format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Pos: position(node)}
+}
+
+// wrapError makes err, which came from outside the program, an error at
+// node that errors.Is and errors.As can find it in
+func wrapError(node ast.Node, err error) *object.Error {
+	e := newError(node, "%s", err)
+	e.Err = err
+	return e
+}
+
+func position(node ast.Node) token.Position {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return token.Position{}
+	}
+	pos, _, _ := ast.Span(node)
+	return pos
+}
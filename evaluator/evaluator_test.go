@@ -0,0 +1,353 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	return testEvalWith(t, New(), input)
+}
+
+func testEvalWith(t *testing.T, in *Interpreter, input string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+	return in.Eval(program, object.NewEnvironment())
+}
+
+// checkObject compares obj with expected, which is an int, float64, bool,
+// string, nil for null or []any for an array
+func checkObject(t *testing.T, input string, obj object.Object, expected any) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		i, ok := obj.(*object.Integer)
+		if !ok || i.Value != int64(expected) {
+			t.Errorf("%q: expected %d. got=%s (%T)", input, expected, inspect(obj), obj)
+		}
+	case float64:
+		f, ok := obj.(*object.Float)
+		if !ok || f.Value != expected {
+			t.Errorf("%q: expected %g. got=%s (%T)", input, expected, inspect(obj), obj)
+		}
+	case bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok || b.Value != expected {
+			t.Errorf("%q: expected %t. got=%s (%T)", input, expected, inspect(obj), obj)
+		}
+	case string:
+		s, ok := obj.(*object.String)
+		if !ok || s.Value != expected {
+			t.Errorf("%q: expected %q. got=%s (%T)", input, expected, inspect(obj), obj)
+		}
+	case nil:
+		if obj != NULL {
+			t.Errorf("%q: expected null. got=%s (%T)", input, inspect(obj), obj)
+		}
+	case []any:
+		array, ok := obj.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%q: expected an array of %d. got=%s (%T)", input, len(expected), inspect(obj), obj)
+			return
+		}
+		for i, e := range expected {
+			checkObject(t, input, array.Elements[i], e)
+		}
+	default:
+		t.Fatalf("can't check against %T", expected)
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"5", 5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * (5 + 10)", 30},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"true", true},
+		{"1 < 2", true},
+		{"1 == 2", false},
+		{"(1 < 2) == true", true},
+		{"true != false", true},
+		{"1 == true", false},
+		{"!true", false},
+		{"!5", false},
+		{"!!5", true},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"${1 + 2} and ${"x"}"`, "3 and x"},
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5;", nil},
+		{"let x: int = 5; x", 5},
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; 1 }; identity(5);", 5},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(2);", 4},
+		// functions can use lets that come after them
+		{"let f = fn() { g() }; let g = fn() { 3 }; f()", 3},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)", 120},
+		// a let in an if only lasts until the end of the branch
+		{"let x = 1; if (true) { let x = 2; x }", 2},
+		{"let x = 1; if (true) { let x = 2; }; x", 1},
+		{"[1, 2 * 2, 3 + 3]", []any{1, 4, 6}},
+		{"[1, 2, 3][0]", 1},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{`len("")`, 0},
+		{`len("héllo")`, 5},
+		{"len([1, 2])", 2},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "1:1: unknown operator: -BOOLEAN"},
+		{"true + false;", "1:1: unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "1:15: unknown operator: BOOLEAN + BOOLEAN"},
+		{`"Hello" - "World"`, "1:1: unknown operator: STRING - STRING"},
+		{"foobar", "1:1: identifier not found: foobar"},
+		{"1 / 0", "1:1: division by zero"},
+		{"let f = fn(x) { x }; f(1, 2)", "1:22: wrong number of arguments: want=1, got=2"},
+		{"5(1)", "1:1: not a function: INTEGER"},
+		{"1[0]", "1:1: index operator not supported: INTEGER"},
+		{`[1]["a"]`, "1:1: array index must be INTEGER, got STRING"},
+		{"len(1)", "1:1: argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "1:1: wrong number of arguments to `len`: want=1, got=2"},
+		// a let in an if isn't there after it
+		{"if (true) { let y = 1; }; y", "1:27: identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(t, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	in := New()
+	var out bytes.Buffer
+	in.Stdout = &out
+
+	checkObject(t, "puts", testEvalWith(t, in, `puts("a", 1, [true, "b"])`), nil)
+	if out.String() != "a\n1\n[true, \"b\"]\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestStatementHook(t *testing.T) {
+	input := `let f = fn(x) {
+	let y = x * 2;
+	y
+};
+f(1);
+`
+	in := New()
+	var got []string
+	in.Hooks.Statement = func(stmt ast.Statement, frames []*Frame) error {
+		names := []string{}
+		for _, f := range frames {
+			names = append(names, f.Name)
+		}
+		pos, _, _ := ast.Span(stmt)
+		vars := strings.Join(frames[len(frames)-1].Env.Names(), ",")
+		got = append(got, fmt.Sprintf("%d %s [%s]", pos.Line, strings.Join(names, "/"), vars))
+		return nil
+	}
+	checkObject(t, input, testEvalWith(t, in, input), 2)
+
+	expected := []string{
+		"1 main []",
+		"5 main [f]",
+		"2 main/f [x]",
+		"3 main/f [x,y]",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong statements.\nexpected=%q\ngot=%q", expected, got)
+	}
+	if len(in.Frames()) != 0 {
+		t.Errorf("expected no frames after Eval. got=%d", len(in.Frames()))
+	}
+}
+
+func TestStatementHookStops(t *testing.T) {
+	stop := errors.New("stop here")
+
+	in := New()
+	count := 0
+	in.Hooks.Statement = func(stmt ast.Statement, frames []*Frame) error {
+		count++
+		if count == 3 {
+			return stop
+		}
+		return nil
+	}
+
+	result := testEvalWith(t, in, "let a = 1;\nlet b = 2;\nlet c = 3;\nlet d = 4;")
+	err, ok := result.(*object.Error)
+	if !ok || !errors.Is(err, stop) || err.Error() != "3:1: stop here" {
+		t.Errorf("expected the hook's error. got=%v", result)
+	}
+	if count != 3 {
+		t.Errorf("expected the program to stop after 3 statements. got=%d", count)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	forever := "let f = fn(n) { f(n + 1) }; f(0)"
+	tests := []struct {
+		limits   Limits
+		input    string
+		expected error
+		message  string
+	}{
+		{Limits{MaxSteps: 100}, forever, &StepLimitError{Limit: 100}, "step limit of 100 exceeded"},
+		{Limits{MaxDepth: 10}, forever, &DepthLimitError{Limit: 10}, "1:17: call depth limit of 10 exceeded"},
+		{Limits{MaxAlloc: 64}, `let f = fn(s) { f(s + s) }; f("ab")`, &AllocLimitError{Limit: 64}, "1:19: allocation limit of 64 bytes exceeded"},
+		{Limits{MaxAlloc: 64}, "[1, 2, 3, 4, 5, 6, 7, 8, 9]", &AllocLimitError{Limit: 64}, "1:1: allocation limit of 64 bytes exceeded"},
+		{Limits{MaxAlloc: 4}, `"${1}${2}${3}${4}${5}"`, &AllocLimitError{Limit: 4}, "1:1: allocation limit of 4 bytes exceeded"},
+	}
+
+	for _, tt := range tests {
+		in := New()
+		in.Limits = tt.limits
+		err, ok := testEvalWith(t, in, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		target := reflect.New(reflect.TypeOf(tt.expected)).Interface()
+		if !errors.As(err, target) || !reflect.DeepEqual(reflect.ValueOf(target).Elem().Interface(), tt.expected) {
+			t.Errorf("%q: expected %T. got=%v", tt.input, tt.expected, err)
+		}
+		if !strings.HasSuffix(err.Error(), tt.message) {
+			t.Errorf("%q: wrong message. expected=%q, got=%q", tt.input, tt.message, err.Error())
+		}
+	}
+
+	// the limits are for each run
+	in := New()
+	in.Limits = Limits{MaxSteps: 100, MaxDepth: 5, MaxAlloc: 10}
+	for range 3 {
+		checkObject(t, "reuse", testEvalWith(t, in, `let f = fn(n) { if (n > 0) { f(n - 1) } else { "abc" } }; f(4)`), "abc")
+	}
+}
+
+func TestContext(t *testing.T) {
+	p := parser.New(lexer.New("let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) + f(n - 1) } }; f(64)"))
+	program := p.ParseProgram()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result := New().EvalContext(ctx, program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); !ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop the program. got=%v", result)
+	}
+
+	// a function called after the deadline doesn't get to run
+	fn := New().Eval(program.Statements[0].(*ast.LetStatement).Value, object.NewEnvironment())
+	result = New().CallContext(ctx, fn, &object.Integer{Value: 0})
+	if err, ok := result.(*object.Error); !ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop the call. got=%v", result)
+	}
+}
+
+func TestInspectLimits(t *testing.T) {
+	// each array holds the one before it ten times, so g is small but its
+	// text has a million numbers in it
+	shared := `let a = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10];
+let b = [a, a, a, a, a, a, a, a, a, a];
+let c = [b, b, b, b, b, b, b, b, b, b];
+let d = [c, c, c, c, c, c, c, c, c, c];
+let e = [d, d, d, d, d, d, d, d, d, d];
+let f = [e, e, e, e, e, e, e, e, e, e];
+let g = [f, f, f, f, f, f, f, f, f, f];
+`
+	tests := []string{
+		"puts(g)",
+		`len("${g}")`,
+		`format("%v", g)`,
+		`format("%s", [g])`,
+		"print(g)",
+		`println("g is", g)`,
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(shared + tt))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%q: parser errors: %v", tt, p.Errors())
+		}
+
+		in := New()
+		in.Stdout = io.Discard
+		in.Limits = Limits{MaxSteps: 1000, MaxAlloc: 64 << 10}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		start := time.Now()
+		result := in.EvalContext(ctx, program, object.NewEnvironment())
+		elapsed := time.Since(start)
+		cancel()
+
+		var limit *AllocLimitError
+		if err, ok := result.(*object.Error); !ok || !errors.As(err, &limit) {
+			t.Errorf("%q: expected the allocation limit to stop it. got=%v", tt, result)
+		}
+		if elapsed > 200*time.Millisecond {
+			t.Errorf("%q: took %v to stop", tt, elapsed)
+		}
+	}
+}
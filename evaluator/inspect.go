@@ -0,0 +1,90 @@
+package evaluator
+
+import (
+	"strconv"
+	"strings"
+
+	"monkey/object"
+)
+
+// inspector writes values the way Inspect gives them, for when a program
+// turns values into text. An array can hold the same big array many times
+// over, so the text can be far bigger than the values it was made from.
+// inspector counts what it writes against the allocation limit and stops
+// once the program's context is done, checking both between elements
+type inspector struct {
+	in  *Interpreter
+	out strings.Builder
+}
+
+// write writes obj, quoting strings when quote is set as they are inside
+// arrays and hashes
+func (p *inspector) write(obj object.Object, quote bool) *object.Error {
+	select {
+	case <-p.in.done:
+		err := p.in.ctx.Err()
+		return &object.Error{Message: err.Error(), Err: err}
+	default:
+	}
+	if err := p.in.checkAlloc(p.out.Len()); err != nil {
+		return err
+	}
+
+	switch obj := obj.(type) {
+	case *object.String:
+		if quote {
+			p.out.WriteString(strconv.Quote(obj.Value))
+		} else {
+			p.out.WriteString(obj.Value)
+		}
+	case *object.Array:
+		p.out.WriteString("[")
+		for i, e := range obj.Elements {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			if err := p.write(e, true); err != nil {
+				return err
+			}
+		}
+		p.out.WriteString("]")
+	case *object.Hash:
+		p.out.WriteString("{")
+		for i, pair := range obj.Sorted() {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			if err := p.write(pair.Key, true); err != nil {
+				return err
+			}
+			p.out.WriteString(": ")
+			if err := p.write(pair.Value, true); err != nil {
+				return err
+			}
+		}
+		p.out.WriteString("}")
+	default:
+		p.out.WriteString(obj.Inspect())
+	}
+	return p.in.checkAlloc(p.out.Len())
+}
+
+// inspect gives obj the way Inspect does, or an error once the text would
+// take the program over its allocation limit or its context is done
+func (in *Interpreter) inspect(obj object.Object, quote bool) (string, *object.Error) {
+	p := &inspector{in: in}
+	if err := p.write(obj, quote); err != nil {
+		return "", err
+	}
+	return p.out.String(), nil
+}
+
+// output counts s, which a builtin is about to write to the host, against
+// the allocation limit, so a program can't write without end
+func (in *Interpreter) output(s string) *object.Error {
+	if err := in.checkAlloc(len(s)); err != nil {
+		return err
+	}
+	in.allocated += len(s)
+	return nil
+}
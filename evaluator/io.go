@@ -0,0 +1,421 @@
+package evaluator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"monkey/object"
+)
+
+// IO is what programs can reach outside themselves through read_file,
+// write_file, list_dir and read_line. Interpreters have none unless they
+// are given one, so the host decides what each of them can touch. Names
+// use forward slashes whatever the OS
+type IO interface {
+	// Open opens a file for read_file, which reads no more of it than the
+	// program is allowed to allocate
+	Open(name string) (io.ReadCloser, error)
+	WriteFile(name, data string) error
+	// ListDir gives the names of what is in the directory, sorted
+	ListDir(name string) ([]string, error)
+	// ReadLine gives the next line of the program's input without its line
+	// ending, or io.EOF when there are no more
+	ReadLine() (string, error)
+}
+
+// ErrNoIO is the error for the io builtins when the interpreter has no IO
+var ErrNoIO = errors.New("the program isn't allowed to do IO")
+
+// ErrOutsideRoot is the error for a name that leads out of the directory
+// a DirIO is rooted at
+var ErrOutsideRoot = errors.New("name is outside the allowed directory")
+
+// ioBuiltins are the builtins that go through in.IO, along with print and
+// println, which write to Stdout like puts does
+func (in *Interpreter) ioBuiltins() map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"read_file": {Name: "read_file", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("read_file", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			if in.IO == nil {
+				return ioError("read_file", ErrNoIO)
+			}
+			return in.readFile(stringArg(args, 0))
+		}},
+
+		"write_file": {Name: "write_file", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("write_file", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			if in.IO == nil {
+				return ioError("write_file", ErrNoIO)
+			}
+			if err := in.IO.WriteFile(stringArg(args, 0), stringArg(args, 1)); err != nil {
+				return ioError("write_file", err)
+			}
+			return NULL
+		}},
+
+		"list_dir": {Name: "list_dir", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("list_dir", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			if in.IO == nil {
+				return ioError("list_dir", ErrNoIO)
+			}
+			names, err := in.IO.ListDir(stringArg(args, 0))
+			if err != nil {
+				return ioError("list_dir", err)
+			}
+			// the array is counted by the call, the names have to be
+			// counted here
+			size := 0
+			elements := make([]object.Object, len(names))
+			for i, name := range names {
+				elements[i] = &object.String{Value: name}
+				size += len(name)
+			}
+			if err := in.checkAlloc(size); err != nil {
+				return err
+			}
+			in.allocated += size
+			return &object.Array{Elements: elements}
+		}},
+
+		"read_line": {Name: "read_line", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("read_line", args); err != nil {
+				return err
+			}
+			if in.IO == nil {
+				return ioError("read_line", ErrNoIO)
+			}
+			line, err := in.IO.ReadLine()
+			if err == io.EOF {
+				return NULL
+			}
+			if err != nil {
+				return ioError("read_line", err)
+			}
+			return &object.String{Value: line}
+		}},
+
+		"print": {Name: "print", Fn: func(args ...object.Object) object.Object {
+			s, err := in.joinInspected(args)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(in.Stdout, s)
+			return NULL
+		}},
+
+		"println": {Name: "println", Fn: func(args ...object.Object) object.Object {
+			s, err := in.joinInspected(args)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(in.Stdout, s)
+			return NULL
+		}},
+	}
+}
+
+// readFile reads the file called name. With an allocation limit only as
+// much as the program has left is read, so a big file fails before it is
+// all in memory
+func (in *Interpreter) readFile(name string) object.Object {
+	f, err := in.IO.Open(name)
+	if err != nil {
+		return ioError("read_file", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if in.Limits.MaxAlloc > 0 {
+		r = io.LimitReader(f, int64(max(0, in.Limits.MaxAlloc-in.allocated)+1))
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ioError("read_file", err)
+	}
+	if err := in.checkAlloc(len(data)); err != nil {
+		return err
+	}
+	return &object.String{Value: string(data)}
+}
+
+// joinInspected gives args the way puts prints them, separated by spaces.
+// What it gives is about to be written to the host, so it is counted
+// against the allocation limit
+func (in *Interpreter) joinInspected(args []object.Object) (string, *object.Error) {
+	p := &inspector{in: in}
+	for i, arg := range args {
+		if i > 0 {
+			p.out.WriteString(" ")
+		}
+		if err := p.write(arg, false); err != nil {
+			return "", err
+		}
+	}
+	s := p.out.String()
+	if err := in.output(s); err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+func ioError(name string, err error) *object.Error {
+	return &object.Error{Message: fmt.Sprintf("`%s` failed: %s", name, err), Err: err}
+}
+
+// DirIO is an IO for the files in one directory and what is under it, with
+// input read from a reader. Names that lead out of the directory, through
+// .. or a symlink, fail with ErrOutsideRoot
+type DirIO struct {
+	root  string
+	input *bufio.Reader
+}
+
+// NewDirIO makes a DirIO for the directory root, reading lines from input,
+// which can be nil for no input
+func NewDirIO(root string, input io.Reader) (*DirIO, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	if input == nil {
+		input = strings.NewReader("")
+	}
+	return &DirIO{root: root, input: bufio.NewReader(input)}, nil
+}
+
+// path gives where name is on disk, once it has checked it is inside the
+// root. What name leads to doesn't have to exist yet, but the directories
+// it is in have to stay inside the root after following symlinks
+func (d *DirIO) path(op, name string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", &fs.PathError{Op: op, Path: name, Err: ErrOutsideRoot}
+	}
+	p := filepath.Join(d.root, filepath.FromSlash(name))
+
+	existing := p
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if real != d.root && !strings.HasPrefix(real, d.root+string(filepath.Separator)) {
+				return "", &fs.PathError{Op: op, Path: name, Err: ErrOutsideRoot}
+			}
+			return p, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) || existing == d.root {
+			return "", hideRoot(err, name)
+		}
+		// a symlink to something that doesn't exist could still lead out
+		// when the file is made
+		if _, err := os.Lstat(existing); err == nil {
+			return "", &fs.PathError{Op: op, Path: name, Err: ErrOutsideRoot}
+		}
+		existing = filepath.Dir(existing)
+	}
+}
+
+// hideRoot takes the root off the errors from the os package, giving them
+// the name the program used, so programs don't learn where on disk they are
+func hideRoot(err error, name string) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+func (d *DirIO) Open(name string) (io.ReadCloser, error) {
+	p, err := d.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, hideRoot(err, name)
+	}
+	return &dirFile{f: f, name: name}, nil
+}
+
+// dirFile is a file opened by a DirIO, with the root taken off its errors
+type dirFile struct {
+	f    *os.File
+	name string
+}
+
+func (f *dirFile) Read(p []byte) (int, error) {
+	n, err := f.f.Read(p)
+	return n, hideRoot(err, f.name)
+}
+
+func (f *dirFile) Close() error {
+	return f.f.Close()
+}
+
+func (d *DirIO) WriteFile(name, data string) error {
+	p, err := d.path("write", name)
+	if err != nil {
+		return err
+	}
+	return hideRoot(os.WriteFile(p, []byte(data), 0o644), name)
+}
+
+func (d *DirIO) ListDir(name string) ([]string, error) {
+	p, err := d.path("list", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, hideRoot(err, name)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names, nil
+}
+
+func (d *DirIO) ReadLine() (string, error) {
+	return readLine(d.input)
+}
+
+// readLine reads a line from r, taking off \n or \r\n. The last line
+// doesn't need to end in a newline
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// MemoryIO is an IO that keeps its files in memory, for tests and for
+// hosts that give programs files of their own. Directories are there
+// when there are files in them
+type MemoryIO struct {
+	mu    sync.Mutex
+	files map[string]string
+	input *bufio.Reader
+}
+
+// NewMemoryIO makes a MemoryIO with a copy of files, keyed by their names,
+// and input as the lines read_line reads
+func NewMemoryIO(files map[string]string, input string) *MemoryIO {
+	m := &MemoryIO{files: map[string]string{}, input: bufio.NewReader(strings.NewReader(input))}
+	for name, data := range files {
+		m.files[path.Clean(name)] = data
+	}
+	return m
+}
+
+// Files gives a copy of the files, with what programs have written to them
+func (m *MemoryIO) Files() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.files)
+}
+
+// clean gives name the way it is kept in files, failing for names that
+// would lead out of a directory on disk
+func (m *MemoryIO) clean(op, name string) (string, error) {
+	clean := path.Clean(name)
+	if !fs.ValidPath(clean) {
+		return "", &fs.PathError{Op: op, Path: name, Err: ErrOutsideRoot}
+	}
+	return clean, nil
+}
+
+func (m *MemoryIO) Open(name string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clean, err := m.clean("open", name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := m.files[clean]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func (m *MemoryIO) WriteFile(name, data string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clean, err := m.clean("write", name)
+	if err != nil {
+		return err
+	}
+	if clean == "." || m.isDir(clean) {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	m.files[clean] = data
+	return nil
+}
+
+func (m *MemoryIO) ListDir(name string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir, err := m.clean("list", name)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for file := range m.files {
+		rest, ok := file, dir == "."
+		if !ok {
+			rest, ok = strings.CutPrefix(file, dir+"/")
+		}
+		if !ok {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if !slices.Contains(names, child) {
+			names = append(names, child)
+		}
+	}
+	if len(names) == 0 && dir != "." {
+		return nil, &fs.PathError{Op: "list", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// isDir is whether there are files in the directory name
+func (m *MemoryIO) isDir(name string) bool {
+	for file := range m.files {
+		if strings.HasPrefix(file, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryIO) ReadLine() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return readLine(m.input)
+}
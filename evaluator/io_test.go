@@ -0,0 +1,236 @@
+package evaluator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monkey/object"
+)
+
+func TestIOBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`read_file("a.txt")`, "hello"},
+		{`read_file("./dir/../a.txt")`, "hello"},
+		{`read_file("dir/b.txt")`, "b"},
+		{`write_file("c.txt", "new"); read_file("c.txt")`, "new"},
+		{`write_file("a.txt", "changed"); read_file("a.txt")`, "changed"},
+		{`list_dir(".")`, []any{"a.txt", "dir"}},
+		{`list_dir("dir")`, []any{"b.txt", "sub"}},
+		{`write_file("dir/sub/x", ""); list_dir("dir/sub")`, []any{"c.txt", "x"}},
+		{"read_line()", "first"},
+		{"[read_line(), read_line(), read_line(), read_line()]", []any{"first", "second", "last", nil}},
+	}
+
+	for _, tt := range tests {
+		in := New()
+		in.IO = NewMemoryIO(map[string]string{
+			"a.txt":         "hello",
+			"dir/b.txt":     "b",
+			"dir/sub/c.txt": "c",
+		}, "first\nsecond\r\nlast")
+		checkObject(t, tt.input, testEvalWith(t, in, tt.input), tt.expected)
+	}
+}
+
+func TestIOBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{`read_file("missing")`, "1:1: `read_file` failed: open missing: file does not exist", fs.ErrNotExist},
+		{`read_file("../a.txt")`, "1:1: `read_file` failed: open ../a.txt: name is outside the allowed directory", ErrOutsideRoot},
+		{`read_file("/a.txt")`, "1:1: `read_file` failed: open /a.txt: name is outside the allowed directory", ErrOutsideRoot},
+		{`write_file("dir", "x")`, "1:1: `write_file` failed: write dir: is a directory", nil},
+		{`list_dir("a")`, "1:1: `list_dir` failed: list a: file does not exist", fs.ErrNotExist},
+		{"read_file(1)", "1:1: argument 1 to `read_file` must be STRING, got INTEGER", nil},
+		{`write_file("a.txt")`, "1:1: wrong number of arguments to `write_file`: want=2, got=1", nil},
+		{"read_line(1)", "1:1: wrong number of arguments to `read_line`: want=0, got=1", nil},
+	}
+
+	for _, tt := range tests {
+		in := New()
+		in.IO = NewMemoryIO(map[string]string{"a.txt": "a", "dir/b.txt": "b"}, "")
+		err, ok := testEvalWith(t, in, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+		if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%q: expected the error to wrap %v", tt.input, tt.err)
+		}
+	}
+}
+
+func TestReadFileLimit(t *testing.T) {
+	in := New()
+	in.Limits = Limits{MaxAlloc: 1000}
+	files := &countingIO{IO: NewMemoryIO(map[string]string{
+		"small": strings.Repeat("a", 600),
+		"big":   strings.Repeat("b", 1<<20),
+	}, "")}
+	in.IO = files
+
+	result := testEvalWith(t, in, `read_file("small"); read_file("big")`)
+	var alloc *AllocLimitError
+	if err, ok := result.(*object.Error); !ok || !errors.As(err, &alloc) {
+		t.Errorf("expected an allocation limit error. got=%s", inspect(result))
+	}
+	// the big file is only read as far as what is left of the limit, and a
+	// byte more to know it is over
+	if files.read != 1001 {
+		t.Errorf("expected 1001 bytes to be read. got=%d", files.read)
+	}
+}
+
+// countingIO counts the bytes read from the files it opens
+type countingIO struct {
+	IO
+	read int
+}
+
+func (c *countingIO) Open(name string) (io.ReadCloser, error) {
+	f, err := c.IO.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{readFunc(func(p []byte) (int, error) {
+		n, err := f.Read(p)
+		c.read += n
+		return n, err
+	}), f}, nil
+}
+
+type readFunc func(p []byte) (int, error)
+
+func (f readFunc) Read(p []byte) (int, error) { return f(p) }
+
+func readAll(d *DirIO, name string) (string, error) {
+	f, err := d.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	return string(data), err
+}
+
+func TestNoIO(t *testing.T) {
+	tests := []string{
+		`read_file("a.txt")`,
+		`write_file("a.txt", "")`,
+		`list_dir(".")`,
+		"read_line()",
+	}
+
+	for _, input := range tests {
+		result := testEval(t, input)
+		if err, ok := result.(*object.Error); !ok || !errors.Is(err, ErrNoIO) {
+			t.Errorf("%q: expected ErrNoIO. got=%s", input, inspect(result))
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	in := New()
+	var out bytes.Buffer
+	in.Stdout = &out
+
+	checkObject(t, "print", testEvalWith(t, in, `print("a", 1); print("b"); println(); println([true, "c"], "d")`), nil)
+	if out.String() != "a 1b\n[true, \"c\"] d\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	// what is printed counts against the allocation limit, so a loop
+	// printing forever stops
+	out.Reset()
+	in.Limits = Limits{MaxAlloc: 10}
+	result := testEvalWith(t, in, `let f = fn() { println("abc"); f() }; f()`)
+	var limit *AllocLimitError
+	if err, ok := result.(*object.Error); !ok || !errors.As(err, &limit) {
+		t.Errorf("expected the allocation limit to stop printing. got=%s", inspect(result))
+	}
+	if out.String() != "abc\nabc\nabc\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestDirIO(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for name, data := range map[string]string{"a.txt": "a", "dir/b.txt": "b"} {
+		os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755)
+		os.WriteFile(filepath.Join(root, name), []byte(data), 0o644)
+	}
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644)
+	os.Symlink(outside, filepath.Join(root, "out"))
+	os.Symlink(filepath.Join(outside, "missing"), filepath.Join(root, "dangling"))
+	os.Symlink("dir", filepath.Join(root, "in"))
+
+	d, err := NewDirIO(root, strings.NewReader("one\ntwo\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := readAll(d, "dir/b.txt"); err != nil || data != "b" {
+		t.Errorf("Open: got %q, %v", data, err)
+	}
+	// symlinks that stay inside are fine
+	if data, err := readAll(d, "in/b.txt"); err != nil || data != "b" {
+		t.Errorf("Open through a symlink: got %q, %v", data, err)
+	}
+	if err := d.WriteFile("dir/c.txt", "c"); err != nil {
+		t.Errorf("WriteFile: %v", err)
+	}
+	if names, err := d.ListDir("dir"); err != nil || !reflect.DeepEqual(names, []string{"b.txt", "c.txt"}) {
+		t.Errorf("ListDir: got %q, %v", names, err)
+	}
+	if line, err := d.ReadLine(); err != nil || line != "one" {
+		t.Errorf("ReadLine: got %q, %v", line, err)
+	}
+
+	for name, err := range map[string]error{
+		"../secret":       ErrOutsideRoot,
+		"out/secret":      ErrOutsideRoot,
+		"dangling":        ErrOutsideRoot,
+		"missing":         fs.ErrNotExist,
+		"missing/a.txt":   fs.ErrNotExist,
+		"dir/missing.txt": fs.ErrNotExist,
+	} {
+		if _, got := readAll(d, name); !errors.Is(got, err) {
+			t.Errorf("Open(%q): expected %v. got=%v", name, err, got)
+		}
+		// the error mustn't say where the root is
+		if _, got := readAll(d, name); got != nil && strings.Contains(got.Error(), root) {
+			t.Errorf("Open(%q): error gives the root away: %v", name, got)
+		}
+	}
+	// nor when reading fails after opening
+	if _, err := readAll(d, "dir"); err == nil || strings.Contains(err.Error(), root) {
+		t.Errorf("reading a directory: expected an error without the root. got=%v", err)
+	}
+	for _, name := range []string{"../x", "out/x", "dangling"} {
+		if err := d.WriteFile(name, "x"); !errors.Is(err, ErrOutsideRoot) {
+			t.Errorf("WriteFile(%q): expected ErrOutsideRoot. got=%v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "missing")); err == nil {
+		t.Errorf("a file was written outside the root")
+	}
+}
@@ -0,0 +1,257 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"monkey/object"
+)
+
+// jsonBuiltins are json_parse and json_stringify. JSON objects are hashes
+// with string keys, and numbers are integers unless they have a point or an
+// exponent or don't fit in one, when they are floats
+func (in *Interpreter) jsonBuiltins() map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"json_parse": {Name: "json_parse", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("json_parse", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return in.jsonParse(stringArg(args, 0))
+		}},
+
+		"json_stringify": {Name: "json_stringify", Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `json_stringify`: want 1 or 2, got=%d", len(args))}
+			}
+			indent := ""
+			if len(args) == 2 {
+				switch arg := args[1].(type) {
+				case *object.Integer:
+					if arg.Value < 0 || arg.Value > 10 {
+						return &object.Error{Message: fmt.Sprintf("indent given to `json_stringify` must be from 0 to 10, got %d", arg.Value)}
+					}
+					indent = strings.Repeat(" ", int(arg.Value))
+				case *object.String:
+					indent = arg.Value
+				default:
+					return &object.Error{Message: fmt.Sprintf("argument 2 to `json_stringify` must be INTEGER or STRING, got %s", arg.Type())}
+				}
+			}
+
+			e := &jsonEncoder{in: in, indent: indent, seen: map[object.Object]bool{}}
+			if err := e.encode(args[0], 0); err != nil {
+				return err
+			}
+			return &object.String{Value: e.out.String()}
+		}},
+	}
+}
+
+// jsonParse turns the JSON document s into a value
+func (in *Interpreter) jsonParse(s string) object.Object {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err == nil && strings.Trim(s[dec.InputOffset():], " \t\r\n") != "" {
+		err = errors.New("there is more after the end of the value")
+	}
+	if err == io.EOF {
+		err = errors.New("there is no value")
+	}
+	if err != nil {
+		return &object.Error{Message: fmt.Sprintf("invalid JSON given to `json_parse`: %s", err)}
+	}
+
+	// the value given back is counted by the call, but what is inside it
+	// has to be counted here
+	var size int
+	obj, err := jsonToObject(v, &size)
+	if err != nil {
+		return &object.Error{Message: fmt.Sprintf("invalid JSON given to `json_parse`: %s", err)}
+	}
+	size -= shallowSize(obj)
+	if err := in.checkAlloc(size); err != nil {
+		return err
+	}
+	in.allocated += size
+	return obj
+}
+
+// jsonToObject converts a value decoded with UseNumber, adding how many
+// bytes it takes to size
+func jsonToObject(v any, size *int) (object.Object, error) {
+	var obj object.Object
+	switch v := v.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBoolToBooleanObject(v), nil
+	case json.Number:
+		n, err := jsonNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		obj = n
+	case string:
+		obj = &object.String{Value: v}
+	case []any:
+		elements := make([]object.Object, len(v))
+		for i, e := range v {
+			el, err := jsonToObject(e, size)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		obj = &object.Array{Elements: elements}
+	case map[string]any:
+		hash := object.NewHash()
+		for k, e := range v {
+			key := &object.String{Value: k}
+			*size += shallowSize(key)
+			val, err := jsonToObject(e, size)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key, val)
+		}
+		obj = hash
+	}
+	*size += shallowSize(obj)
+	return obj, nil
+}
+
+func jsonNumber(n json.Number) (object.Object, error) {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return &object.Integer{Value: i}, nil
+		}
+	}
+	// the decoder has checked the syntax, so this can only fail for numbers
+	// too big for a float, which would come back as an infinity
+	f, err := strconv.ParseFloat(n.String(), 64)
+	if err != nil {
+		return nil, fmt.Errorf("number %s out of range", n)
+	}
+	return &object.Float{Value: f}, nil
+}
+
+// jsonEncoder writes values as JSON. Hash keys are written in sorted order
+// so the same value always gives the same JSON
+type jsonEncoder struct {
+	in     *Interpreter
+	out    bytes.Buffer
+	indent string
+	// seen is the arrays and hashes being written, to find ones that
+	// contain themselves
+	seen map[object.Object]bool
+}
+
+func (e *jsonEncoder) encode(obj object.Object, depth int) *object.Error {
+	// with an indent the JSON can be far bigger than the value
+	if err := e.in.checkAlloc(e.out.Len()); err != nil {
+		return err
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean, *object.Integer:
+		e.out.WriteString(obj.Inspect())
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return &object.Error{Message: fmt.Sprintf("`json_stringify` can't encode the float %s", obj.Inspect())}
+		}
+		e.out.WriteString(obj.Inspect())
+	case *object.String:
+		e.string(obj.Value)
+
+	case *object.Array:
+		if e.seen[obj] {
+			return &object.Error{Message: "`json_stringify` can't encode an array that contains itself"}
+		}
+		e.seen[obj] = true
+		defer delete(e.seen, obj)
+
+		if len(obj.Elements) == 0 {
+			e.out.WriteString("[]")
+			return nil
+		}
+		e.out.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(el, depth+1); err != nil {
+				return err
+			}
+		}
+		e.newline(depth)
+		e.out.WriteByte(']')
+
+	case *object.Hash:
+		if e.seen[obj] {
+			return &object.Error{Message: "`json_stringify` can't encode a hash that contains itself"}
+		}
+		e.seen[obj] = true
+		defer delete(e.seen, obj)
+
+		if len(obj.Pairs) == 0 {
+			e.out.WriteString("{}")
+			return nil
+		}
+		e.out.WriteByte('{')
+		for i, pair := range obj.Sorted() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return &object.Error{Message: fmt.Sprintf("`json_stringify` can only encode hashes with STRING keys, got %s", pair.Key.Type())}
+			}
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			e.string(key.Value)
+			e.out.WriteByte(':')
+			if e.indent != "" {
+				e.out.WriteByte(' ')
+			}
+			if err := e.encode(pair.Value, depth+1); err != nil {
+				return err
+			}
+		}
+		e.newline(depth)
+		e.out.WriteByte('}')
+
+	default:
+		return &object.Error{Message: fmt.Sprintf("`json_stringify` can't encode %s", obj.Type())}
+	}
+	return nil
+}
+
+// newline starts a line indented depth times, when there is an indent
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.out.WriteByte('\n')
+	for range depth {
+		e.out.WriteString(e.indent)
+	}
+}
+
+// string writes s quoted, without the HTML escaping json.Marshal does
+func (e *jsonEncoder) string(s string) {
+	enc := json.NewEncoder(&e.out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode ends the value with a newline
+	e.out.Truncate(e.out.Len() - 1)
+}
@@ -0,0 +1,151 @@
+package evaluator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"monkey/object"
+)
+
+func TestJSONBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`json_parse("1")`, 1},
+		{`json_parse(" -12 ")`, -12},
+		{`json_parse("1.5")`, 1.5},
+		{`json_parse("1e3")`, 1000.0},
+		{`json_parse("9223372036854775808")`, 9223372036854775808.0},
+		{`json_parse("\"h\\u00e9\"")`, "hé"},
+		{`json_parse("true")`, true},
+		{`json_parse("null")`, nil},
+		{`json_parse("[1, \"a\", [false, null]]")`, []any{1, "a", []any{false, nil}}},
+		{`json_parse("{\"a\": {\"b\": [1, 2]}}")["a"]["b"]`, []any{1, 2}},
+		{`len(json_parse("{\"a\": 1, \"b\": 2}"))`, 2},
+		{`json_stringify(if (false) { 1 })`, "null"},
+		{`json_stringify(-5)`, "-5"},
+		{`json_stringify(json_parse("2.0"))`, "2.0"},
+		{`json_stringify(json_parse("1e100"))`, "1e+100"},
+		{`json_stringify("a\"<b>\n")`, `"a\"<b>\n"`},
+		{`json_stringify([1, true, "x", []])`, `[1,true,"x",[]]`},
+		// keys come out in order
+		{`json_stringify(json_parse("{\"b\": 1, \"a\": [{}], \"c\": null}"))`, `{"a":[{}],"b":1,"c":null}`},
+		{`json_stringify(json_parse("{\"b\": [1, 2], \"a\": {}}"), 2)`, "{\n  \"a\": {},\n  \"b\": [\n    1,\n    2\n  ]\n}"},
+		{`json_stringify([1], "\t")`, "[\n\t1\n]"},
+		{`json_stringify([1], 0)`, "[1]"},
+		{`let s = "{\"x\":[1,2.5,{\"y\":\"z\"}]}"; json_stringify(json_parse(s)) == s`, true},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let f = json_parse("1.5"); f + f`, 3.0},
+		{`let f = json_parse("1.5"); f * 2`, 3.0},
+		{`let f = json_parse("1.5"); 1 - f`, -0.5},
+		{`let f = json_parse("1.5"); -f`, -1.5},
+		{`let f = json_parse("1.5"); 3 / f`, 2.0},
+		{`let f = json_parse("1.5"); f > 1`, true},
+		{`let f = json_parse("2.0"); f == 2`, true},
+		{`let f = json_parse("1.5"); "${f} ${f + f}"`, "1.5 3.0"},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestJSONBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"json_parse(1)", "1:1: argument 1 to `json_parse` must be STRING, got INTEGER"},
+		{`json_parse("")`, "1:1: invalid JSON given to `json_parse`: there is no value"},
+		{`json_parse("[1,")`, "1:1: invalid JSON given to `json_parse`: unexpected EOF"},
+		{`json_parse("{a: 1}")`, "1:1: invalid JSON given to `json_parse`: invalid character 'a' looking for beginning of object key string"},
+		{`json_parse("1 }")`, "1:1: invalid JSON given to `json_parse`: there is more after the end of the value"},
+		{`json_parse("[1e400]")`, "1:1: invalid JSON given to `json_parse`: number 1e400 out of range"},
+		{`json_parse("-1e400")`, "1:1: invalid JSON given to `json_parse`: number -1e400 out of range"},
+		{"json_stringify()", "1:1: wrong number of arguments to `json_stringify`: want 1 or 2, got=0"},
+		{"json_stringify(1, true)", "1:1: argument 2 to `json_stringify` must be INTEGER or STRING, got BOOLEAN"},
+		{"json_stringify(1, 11)", "1:1: indent given to `json_stringify` must be from 0 to 10, got 11"},
+		{"json_stringify([1, fn(x) { x }])", "1:1: `json_stringify` can't encode FUNCTION"},
+		{"json_stringify([len])", "1:1: `json_stringify` can't encode BUILTIN"},
+		{`let f = json_parse("1.5"); f / 0`, "1:28: division by zero"},
+		{`let f = json_parse("1.5"); f + "a"`, "1:28: type mismatch: FLOAT + STRING"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(t, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+// programs can't make hashes with other keys or values that contain
+// themselves, but Go can
+func TestJSONStringifyValuesFromGo(t *testing.T) {
+	array := &object.Array{}
+	array.Elements = []object.Object{&object.Integer{Value: 1}, array}
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "self"}, hash)
+	numbers := object.NewHash()
+	numbers.Set(&object.Integer{Value: 1}, &object.Integer{Value: 2})
+	// the same value twice is fine as long as it isn't inside itself
+	shared := &object.Array{Elements: []object.Object{NULL}}
+
+	tests := []struct {
+		value    object.Object
+		expected string
+	}{
+		{array, "`json_stringify` can't encode an array that contains itself"},
+		{hash, "`json_stringify` can't encode a hash that contains itself"},
+		{numbers, "`json_stringify` can only encode hashes with STRING keys, got INTEGER"},
+		{&object.Array{Elements: []object.Object{shared, shared}}, ""},
+	}
+
+	for _, tt := range tests {
+		in := New()
+		result := in.Call(in.builtins()["json_stringify"], tt.value)
+		err, ok := result.(*object.Error)
+		switch {
+		case tt.expected == "" && ok:
+			t.Errorf("unexpected error: %s", err.Message)
+		case tt.expected != "" && (!ok || err.Message != tt.expected):
+			t.Errorf("wrong result. expected=%q, got=%s", tt.expected, inspect(result))
+		}
+	}
+}
+
+func TestJSONBuiltinLimits(t *testing.T) {
+	ones := strings.Repeat("1,", 99) + "1"
+	tests := []string{
+		`json_parse("[[` + ones + `], [` + ones + `]]")`,
+		`json_parse("[[\"` + strings.Repeat("a", 2000) + `\"]]")`,
+		`json_stringify(json_parse("` + strings.Repeat("[", 30) + strings.Repeat("]", 30) + `"), 10)`,
+	}
+
+	for _, input := range tests {
+		in := New()
+		in.Limits = Limits{MaxAlloc: 1000}
+		result := testEvalWith(t, in, input)
+		var alloc *AllocLimitError
+		if err, ok := result.(*object.Error); !ok || !errors.As(err, &alloc) {
+			t.Errorf("%q: expected an allocation limit error. got=%v", input, result)
+		}
+	}
+}
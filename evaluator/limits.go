@@ -0,0 +1,117 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"monkey/ast"
+	"monkey/object"
+)
+
+// Limits bound how much a program can do in one call to Eval or Call, so
+// programs nobody has checked can be run safely. Zero means no limit
+type Limits struct {
+	// MaxSteps is how many nodes can be evaluated
+	MaxSteps int
+	// MaxDepth is how many function calls can be running at once
+	MaxDepth int
+	// MaxAlloc is how many bytes of strings, arrays and hashes can be made,
+	// with each element of an array taking 8 bytes and each pair of a hash
+	// 16
+	MaxAlloc int
+}
+
+// StepLimitError is the error for a program that ran for more steps than
+// Limits.MaxSteps
+type StepLimitError struct {
+	Limit int
+}
+
+func (e *StepLimitError) Error() string {
+	return fmt.Sprintf("step limit of %d exceeded", e.Limit)
+}
+
+// DepthLimitError is the error for a program that made more nested calls
+// than Limits.MaxDepth
+type DepthLimitError struct {
+	Limit int
+}
+
+func (e *DepthLimitError) Error() string {
+	return fmt.Sprintf("call depth limit of %d exceeded", e.Limit)
+}
+
+// AllocLimitError is the error for a program that made more than
+// Limits.MaxAlloc bytes of values
+type AllocLimitError struct {
+	Limit int
+}
+
+func (e *AllocLimitError) Error() string {
+	return fmt.Sprintf("allocation limit of %d bytes exceeded", e.Limit)
+}
+
+// step counts evaluating node, failing once the program has run out of
+// steps or its context is done
+func (in *Interpreter) step(node ast.Node) *object.Error {
+	select {
+	case <-in.done:
+		return wrapError(node, in.ctx.Err())
+	default:
+	}
+
+	in.steps++
+	if in.Limits.MaxSteps > 0 && in.steps > in.Limits.MaxSteps {
+		return wrapError(node, &StepLimitError{Limit: in.Limits.MaxSteps})
+	}
+	return nil
+}
+
+// alloc counts the memory obj takes, not counting what it refers to. It
+// gives obj back unless it takes the program over its allocation limit
+func (in *Interpreter) alloc(node ast.Node, obj object.Object) object.Object {
+	in.allocated += shallowSize(obj)
+	if in.Limits.MaxAlloc > 0 && in.allocated > in.Limits.MaxAlloc {
+		return wrapError(node, &AllocLimitError{Limit: in.Limits.MaxAlloc})
+	}
+	return obj
+}
+
+// shallowSize is how many bytes alloc counts for obj
+func shallowSize(obj object.Object) int {
+	switch obj := obj.(type) {
+	case *object.String:
+		return len(obj.Value)
+	case *object.Array:
+		return 8 * len(obj.Elements)
+	case *object.Hash:
+		return 16 * len(obj.Pairs)
+	}
+	return 0
+}
+
+// ShallowSize is how many bytes the allocation limit counts for obj, not
+// counting what it refers to
+func ShallowSize(obj object.Object) int {
+	return shallowSize(obj)
+}
+
+// Allocate is for builtins made outside this package to count n bytes of
+// values they made, failing without counting them if that would take the
+// program over its allocation limit
+func (in *Interpreter) Allocate(n int) *object.Error {
+	if err := in.checkAlloc(n); err != nil {
+		return err
+	}
+	in.allocated += n
+	return nil
+}
+
+// checkAlloc is for builtins to check they can make n bytes before making
+// them, when making them could take too long or too much memory
+func (in *Interpreter) checkAlloc(n int) *object.Error {
+	if in.Limits.MaxAlloc > 0 && in.allocated+n > in.Limits.MaxAlloc {
+		err := &AllocLimitError{Limit: in.Limits.MaxAlloc}
+		return &object.Error{Message: err.Error(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"monkey/object"
+)
+
+// stringBuiltins are the builtins for working with strings. Anything that
+// counts counts runes rather than bytes, so index_of("héllo", "l") is 2
+func (in *Interpreter) stringBuiltins() map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"split": {Name: "split", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("split", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			parts := strings.Split(stringArg(args, 0), stringArg(args, 1))
+			elements := make([]object.Object, len(parts))
+			for i, p := range parts {
+				elements[i] = &object.String{Value: p}
+			}
+			return &object.Array{Elements: elements}
+		}},
+
+		"join": {Name: "join", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("join", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			parts := []string{}
+			for i, e := range args[0].(*object.Array).Elements {
+				s, ok := e.(*object.String)
+				if !ok {
+					return &object.Error{Message: fmt.Sprintf("element %d of argument 1 to `join` must be STRING, got %s", i, e.Type())}
+				}
+				parts = append(parts, s.Value)
+			}
+			return &object.String{Value: strings.Join(parts, stringArg(args, 1))}
+		}},
+
+		"trim":  stringFunc("trim", strings.TrimSpace),
+		"upper": stringFunc("upper", strings.ToUpper),
+		"lower": stringFunc("lower", strings.ToLower),
+
+		"contains":    stringTest("contains", strings.Contains),
+		"starts_with": stringTest("starts_with", strings.HasPrefix),
+		"ends_with":   stringTest("ends_with", strings.HasSuffix),
+
+		"replace": {Name: "replace", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("replace", args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ReplaceAll(stringArg(args, 0), stringArg(args, 1), stringArg(args, 2))}
+		}},
+
+		"index_of": {Name: "index_of", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("index_of", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			s := stringArg(args, 0)
+			i := strings.Index(s, stringArg(args, 1))
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		}},
+
+		"repeat": {Name: "repeat", Fn: func(args ...object.Object) object.Object {
+			if err := checkArgs("repeat", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+			s, count := stringArg(args, 0), args[1].(*object.Integer).Value
+			if count < 0 {
+				return &object.Error{Message: fmt.Sprintf("count given to `repeat` can't be negative, got %d", count)}
+			}
+			// check before making the string, which could be far too big
+			if len(s) > 0 && count > math.MaxInt32/int64(len(s)) {
+				return &object.Error{Message: "result of `repeat` is too long"}
+			}
+			if err := in.checkAlloc(len(s) * int(count)); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Repeat(s, int(count))}
+		}},
+
+		"format": {Name: "format", Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return &object.Error{Message: "wrong number of arguments to `format`: want at least 1, got=0"}
+			}
+			if err := checkArgs("format", args[:1], object.STRING_OBJ); err != nil {
+				return err
+			}
+			return in.format(stringArg(args, 0), args[1:])
+		}},
+	}
+}
+
+// stringFunc makes a builtin that changes a string with f
+func stringFunc(name string, f func(string) string) *object.Builtin {
+	return &object.Builtin{Name: name, Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs(name, args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return &object.String{Value: f(stringArg(args, 0))}
+	}}
+}
+
+// stringTest makes a builtin that asks f something about two strings
+func stringTest(name string, f func(s, t string) bool) *object.Builtin {
+	return &object.Builtin{Name: name, Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs(name, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(f(stringArg(args, 0), stringArg(args, 1)))
+	}}
+}
+
+// checkArgs checks a builtin was given arguments of the types it takes.
+// Builtins can be given where a FUNCTION is taken
+func checkArgs(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return wrongArguments(name, len(types), len(args))
+	}
+	for i, t := range types {
+		if t == object.FUNCTION_OBJ && args[i].Type() == object.BUILTIN_OBJ {
+			continue
+		}
+		if args[i].Type() != t {
+			return &object.Error{Message: fmt.Sprintf("argument %d to `%s` must be %s, got %s", i+1, name, t, args[i].Type())}
+		}
+	}
+	return nil
+}
+
+// stringArg gives argument i, which checkArgs has made sure is a string
+func stringArg(args []object.Object, i int) string {
+	return args[i].(*object.String).Value
+}
+
+// format fills in the verbs in f with args: %s for any value the way puts
+// prints it, %v for any value the way it is written in arrays, %d for
+// integers, %t for booleans, %q for strings in quotes and %% for a percent
+// sign. A width can go between the % and the verb, with a - in front to
+// pad on the right, counting runes
+func (in *Interpreter) format(f string, args []object.Object) object.Object {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			out.WriteByte(f[i])
+			continue
+		}
+
+		start := i
+		i++
+		left := i < len(f) && f[i] == '-'
+		if left {
+			i++
+		}
+		digits := i
+		for i < len(f) && '0' <= f[i] && f[i] <= '9' {
+			i++
+		}
+		width := 0
+		if i > digits {
+			// check before padding, which could be far too big
+			w, err := strconv.Atoi(f[digits:i])
+			if err != nil || w > math.MaxInt32 {
+				return &object.Error{Message: fmt.Sprintf("width %s in format is too big", f[digits:i])}
+			}
+			if err := in.checkAlloc(out.Len() + w); err != nil {
+				return err
+			}
+			width = w
+		}
+		if i == len(f) {
+			return &object.Error{Message: fmt.Sprintf("format %q ends in the middle of a verb", f)}
+		}
+
+		verb := f[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return &object.Error{Message: fmt.Sprintf("format %q needs more than %d arguments", f, len(args))}
+		}
+		arg := args[next]
+		next++
+
+		want := map[byte]object.ObjectType{'d': object.INTEGER_OBJ, 't': object.BOOLEAN_OBJ, 'q': object.STRING_OBJ}[verb]
+		if want != "" && arg.Type() != want {
+			return &object.Error{Message: fmt.Sprintf("%s in format needs %s, got %s", f[start:i+1], want, arg.Type())}
+		}
+
+		var quote bool
+		switch verb {
+		case 's', 'd', 't':
+		case 'v', 'q':
+			quote = true
+		default:
+			return &object.Error{Message: fmt.Sprintf("unknown verb %s in format", f[start:i+1])}
+		}
+		s, err := in.inspect(arg, quote)
+		if err != nil {
+			return err
+		}
+		if err := in.checkAlloc(out.Len() + len(s)); err != nil {
+			return err
+		}
+
+		pad := strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s)))
+		if left {
+			out.WriteString(s + pad)
+		} else {
+			out.WriteString(pad + s)
+		}
+	}
+
+	if next < len(args) {
+		return &object.Error{Message: fmt.Sprintf("format %q only uses %d of its %d arguments", f, next, len(args))}
+	}
+	return &object.String{Value: out.String()}
+}
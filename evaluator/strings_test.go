@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"errors"
+	"testing"
+
+	"monkey/object"
+)
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`len("héllo")`, 5},
+		{`split("a,b,,c", ",")`, []any{"a", "b", "", "c"}},
+		{`split("héllo", "")`, []any{"h", "é", "l", "l", "o"}},
+		{`split("", ",")`, []any{""}},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join([], "-")`, ""},
+		{`join(split("a b c", " "), "+")`, "a+b+c"},
+		{`trim("  \t héllo \n")`, "héllo"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ÀBC")`, "àbc"},
+		{`contains("héllo", "él")`, true},
+		{`contains("héllo", "x")`, false},
+		{`contains("abc", "")`, true},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("aaa", "a", "")`, ""},
+		{`starts_with("héllo", "hé")`, true},
+		{`starts_with("héllo", "llo")`, false},
+		{`ends_with("héllo", "llo")`, true},
+		{`index_of("héllo", "l")`, 2},
+		{`index_of("héllo", "é")`, 1},
+		{`index_of("héllo", "x")`, -1},
+		{`index_of("abc", "")`, 0},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("é", 0)`, ""},
+		{`format("%s is %d", "x", 5)`, "x is 5"},
+		{`format("%t and %q", true, "a\"b")`, `true and "a\"b"`},
+		{`format("%v %v %s", "a", [1, "b"], [1, "b"])`, `"a" [1, "b"] [1, "b"]`},
+		{`format("[%5s|%-5s]", "hé", "hé")`, "[   hé|hé   ]"},
+		{`format("[%3d]", 1234)`, "[1234]"},
+		{`format("100%%")`, "100%"},
+		{`format("no verbs")`, "no verbs"},
+	}
+
+	for _, tt := range tests {
+		checkObject(t, tt.input, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestStringBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a")`, "1:1: wrong number of arguments to `split`: want=2, got=1"},
+		{`split("a", 1)`, "1:1: argument 2 to `split` must be STRING, got INTEGER"},
+		{`join("a", ",")`, "1:1: argument 1 to `join` must be ARRAY, got STRING"},
+		{`join(["a", 1], ",")`, "1:1: element 1 of argument 1 to `join` must be STRING, got INTEGER"},
+		{"trim(1)", "1:1: argument 1 to `trim` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, "1:1: wrong number of arguments to `upper`: want=1, got=2"},
+		{`lower(true)`, "1:1: argument 1 to `lower` must be STRING, got BOOLEAN"},
+		{`contains(["a"], "a")`, "1:1: argument 1 to `contains` must be STRING, got ARRAY"},
+		{`replace("a", "b")`, "1:1: wrong number of arguments to `replace`: want=3, got=2"},
+		{`starts_with("a", 1)`, "1:1: argument 2 to `starts_with` must be STRING, got INTEGER"},
+		{`ends_with(1, "a")`, "1:1: argument 1 to `ends_with` must be STRING, got INTEGER"},
+		{`index_of("a", 1)`, "1:1: argument 2 to `index_of` must be STRING, got INTEGER"},
+		{`repeat("a", "b")`, "1:1: argument 2 to `repeat` must be INTEGER, got STRING"},
+		{`repeat("a", -1)`, "1:1: count given to `repeat` can't be negative, got -1"},
+		{`repeat("ab", 9223372036854775807)`, "1:1: result of `repeat` is too long"},
+		{"format()", "1:1: wrong number of arguments to `format`: want at least 1, got=0"},
+		{"format(1)", "1:1: argument 1 to `format` must be STRING, got INTEGER"},
+		{`format("%d", "a")`, "1:1: %d in format needs INTEGER, got STRING"},
+		{`format("%5q", 1)`, "1:1: %5q in format needs STRING, got INTEGER"},
+		{`format("%s %s", 1)`, `1:1: format "%s %s" needs more than 1 arguments`},
+		{`format("%s", 1, 2)`, `1:1: format "%s" only uses 1 of its 2 arguments`},
+		{`format("%x", 1)`, "1:1: unknown verb %x in format"},
+		{`format("%999999999999999999d", 1)`, "1:1: width 999999999999999999 in format is too big"},
+		{`format("%99999999999999999999s", "a")`, "1:1: width 99999999999999999999 in format is too big"},
+		{`format("50%")`, `1:1: format "50%" ends in the middle of a verb`},
+		// the error is at the call, not the start of the statement
+		{`let x = 1 + len(upper(1));`, "1:17: argument 1 to `upper` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(t, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("%q: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestStringBuiltinLimits(t *testing.T) {
+	tests := []string{
+		`repeat("abc", 1000000)`,
+		`format("%1000000s", "a")`,
+	}
+
+	for _, input := range tests {
+		in := New()
+		in.Limits = Limits{MaxAlloc: 1000}
+		result := testEvalWith(t, in, input)
+		var alloc *AllocLimitError
+		if err, ok := result.(*object.Error); !ok || !errors.As(err, &alloc) {
+			t.Errorf("%q: expected an allocation limit error. got=%v", input, result)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"monkey/ast"
+	"monkey/cst"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+)
+
+// The format package prints programs in one canonical layout, like gofmt.
+// Every statement goes on its own line ending in a semicolon, operators have
+// a space either side, brackets are only kept where the precedence of the
+// operators needs them, and comments are kept. Formatting is idempotent, so
+// formatting formatted code changes nothing
+
+const indentation = "\t"
+
+// Source formats a whole file, keeping its comments and single blank lines
+// between statements. It fails if src doesn't parse
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	file := cst.Parse(string(src))
+	pr := &printer{}
+
+	for i, stmt := range file.Statements {
+		pr.setStatement(stmt)
+		first := stmt.Tokens[0]
+		last := stmt.Tokens[len(stmt.Tokens)-1]
+
+		pr.leadingComments(first.Leading, i == 0, true)
+		pr.movedComments(0)
+		pr.statement(stmt.AST)
+		pr.trailingComments(last.Trailing)
+		pr.write("\n")
+	}
+	pr.leadingComments(file.EOF.Leading, len(file.Statements) == 0, false)
+
+	return pr.out.Bytes(), nil
+}
+
+// Node formats a single node, without any comments
+func Node(node ast.Node) string {
+	pr := &printer{}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		for _, s := range n.Statements {
+			pr.statement(s)
+			pr.write("\n")
+		}
+	case ast.Statement:
+		pr.statement(n)
+	case ast.Expression:
+		pr.expression(n, parser.LOWEST)
+	}
+
+	return pr.out.String()
+}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+
+	// tokens are the tokens of the top level statement being printed, and
+	// nodes gives where each block in it is in them. Node has no tokens, so
+	// it prints blocks without comments
+	tokens []*cst.Token
+	nodes  map[ast.Node]*cst.Node
+	// leading and trailing are the tokens whose comments block prints where
+	// they are, and owners gives the index of the first token of the
+	// innermost statement each token is in
+	leading, trailing map[int]bool
+	owners            map[int]int
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+func (p *printer) setStatement(stmt *cst.Statement) {
+	p.tokens = stmt.Tokens
+	p.nodes = map[ast.Node]*cst.Node{}
+	var add func(n *cst.Node)
+	add = func(n *cst.Node) {
+		p.nodes[n.AST] = n
+		for _, child := range n.Children {
+			add(child)
+		}
+	}
+	if stmt.Node != nil {
+		add(stmt.Node)
+	}
+
+	p.leading, p.trailing, p.owners = map[int]bool{}, map[int]bool{}, map[int]int{}
+	// blocks are reached before the blocks inside them, so each token ends
+	// up owned by the innermost statement
+	ast.Inspect(stmt.AST, func(n ast.Node) bool {
+		b, ok := n.(*ast.BlockStatement)
+		if !ok || b == nil {
+			return true
+		}
+		bt, ok := p.blockTokens(b)
+		if !ok {
+			return true
+		}
+		p.trailing[bt.open] = true
+		p.leading[bt.close] = true
+		for i, first := range bt.first {
+			p.leading[first] = true
+			p.trailing[bt.last[i]] = true
+			for j := first; j <= bt.last[i]; j++ {
+				p.owners[j] = first
+			}
+		}
+		return true
+	})
+}
+
+// blockTokens is where a block is in the tokens: the indexes of its braces
+// and of the first and last token of each of its statements
+type blockTokens struct {
+	open, close int
+	first, last []int
+}
+
+func (p *printer) blockTokens(b *ast.BlockStatement) (blockTokens, bool) {
+	bt := blockTokens{}
+	node, ok := p.nodes[b]
+	// a block the parser reached the end of the input in has no closing
+	// brace, and its statements can't all be told apart
+	if !ok || b.Close.Type != token.RBRACE || len(node.Children) != len(b.Statements) {
+		return bt, false
+	}
+	bt.open, bt.close = node.Start, node.End-1
+
+	for i, child := range node.Children {
+		if child.AST != b.Statements[i] {
+			return bt, false
+		}
+		if len(bt.first) > 0 {
+			bt.last = append(bt.last, child.Start-1)
+		}
+		bt.first = append(bt.first, child.Start)
+	}
+	if len(bt.first) > 0 {
+		bt.last = append(bt.last, bt.close-1)
+	}
+	return bt, true
+}
+
+// leadingComments prints each comment in the trivia before a statement on
+// its own line. A blank line before a comment, or before the statement when
+// beforeCode is set, is kept unless it is at the start of the file
+func (p *printer) leadingComments(trivia []cst.Trivia, startOfFile bool, beforeCode bool) {
+	blank := false
+
+	for _, tr := range trivia {
+		if tr.Kind == cst.Whitespace {
+			blank = strings.Count(tr.Text, "\n") > 1
+			continue
+		}
+
+		if blank && !startOfFile {
+			p.write("\n")
+		}
+		p.comment(tr)
+		startOfFile = false
+		blank = false
+	}
+
+	if blank && !startOfFile && beforeCode {
+		p.write("\n")
+	}
+}
+
+// movedComments prints the comments from the middle of the statement whose
+// first token is at start, which can't stay where they are once it is on one
+// line, so they go above it
+func (p *printer) movedComments(start int) {
+	for j := start; j < len(p.tokens); j++ {
+		if p.owners[j] != start {
+			continue
+		}
+		if j != start && !p.leading[j] {
+			p.comments(p.tokens[j].Leading)
+		}
+		if j != len(p.tokens)-1 && !p.trailing[j] {
+			p.comments(p.tokens[j].Trailing)
+		}
+	}
+}
+
+func (p *printer) comments(trivia []cst.Trivia) {
+	for _, tr := range trivia {
+		if tr.Kind == cst.Comment {
+			p.comment(tr)
+		}
+	}
+}
+
+// trailingComments prints the comment at the end of a line after the code
+// on it
+func (p *printer) trailingComments(trivia []cst.Trivia) {
+	for _, tr := range trivia {
+		if tr.Kind == cst.Comment {
+			p.write(" " + strings.TrimRight(tr.Text, " \t\r"))
+		}
+	}
+}
+
+func hasComment(trivia []cst.Trivia) bool {
+	for _, tr := range trivia {
+		if tr.Kind == cst.Comment {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) comment(tr cst.Trivia) {
+	p.write(strings.Repeat(indentation, p.indent))
+	p.write(strings.TrimRight(tr.Text, " \t\r") + "\n")
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	p.write(strings.Repeat(indentation, p.indent))
+
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value)
+		if s.Type != nil {
+			p.write(": " + s.Type.String())
+		}
+		p.write(" = ")
+		p.expression(s.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(s.ReturnValue, parser.LOWEST)
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression, parser.LOWEST)
+	}
+
+	p.write(";")
+}
+
+// block prints the statements of b one per line, indented one more than the
+// code around them, with the braces around them. The comments between them
+// stay where they are, like the ones between top level statements
+func (p *printer) block(b *ast.BlockStatement) {
+	bt, ok := p.blockTokens(b)
+	if len(b.Statements) == 0 && (!ok || !hasComment(p.tokens[bt.open].Trailing) && !hasComment(p.tokens[bt.close].Leading)) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{")
+	if ok {
+		p.trailingComments(p.tokens[bt.open].Trailing)
+	}
+	p.write("\n")
+	p.indent++
+	for i, s := range b.Statements {
+		if ok {
+			p.leadingComments(p.tokens[bt.first[i]].Leading, i == 0, true)
+			p.movedComments(bt.first[i])
+		}
+		p.statement(s)
+		if ok {
+			p.trailingComments(p.tokens[bt.last[i]].Trailing)
+		}
+		p.write("\n")
+	}
+	if ok {
+		p.leadingComments(p.tokens[bt.close].Leading, len(b.Statements) == 0, false)
+	}
+	p.indent--
+	p.write(strings.Repeat(indentation, p.indent) + "}")
+}
+
+func (p *printer) expressionList(exps []ast.Expression) {
+	for i, e := range exps {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(e, parser.LOWEST)
+	}
+}
+
+// precedence is how tightly an expression holds together, anything that
+// isn't an operator can go anywhere without brackets
+func precedence(exp ast.Expression) int {
+	switch e := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(token.TokenType(e.Operator))
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression:
+		// calls and indexes both follow what they apply to, so they can be
+		// chained either way round like f(x)[0](y) without brackets
+		return parser.CALL
+	}
+	return parser.INDEX + 1
+}
+
+// expression prints exp, in brackets if it binds less tightly than outer
+func (p *printer) expression(exp ast.Expression, outer int) {
+	brackets := precedence(exp) < outer
+	if brackets {
+		p.write("(")
+	}
+
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+
+	case *ast.IntegerLiteral:
+		p.write(e.String())
+
+	case *ast.Boolean:
+		p.write(e.String())
+
+	case *ast.StringLiteral:
+		p.stringLiteral(e)
+
+	case *ast.InterpolatedString:
+		p.write("\"")
+		for i, lit := range e.Literals {
+			// the literal parts are escaped the same way as a whole string
+			quoted := (&ast.StringLiteral{Value: lit}).String()
+			p.write(quoted[1 : len(quoted)-1])
+			if i < len(e.Expressions) {
+				p.write("${")
+				p.expression(e.Expressions[i], parser.LOWEST)
+				p.write("}")
+			}
+		}
+		p.write("\"")
+
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expression(e.Right, parser.PREFIX)
+
+	case *ast.InfixExpression:
+		// operators group to the left, so a + (b + c) keeps its brackets
+		prec := precedence(e)
+		p.expression(e.Left, prec)
+		p.write(" " + e.Operator + " ")
+		p.expression(e.Right, prec+1)
+
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		p.write("fn(")
+		for i, param := range e.Parameters {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.write(param.Value)
+			if t := e.ParameterType(i); t != nil {
+				p.write(": " + t.String())
+			}
+		}
+		p.write(") ")
+		if e.ReturnType != nil {
+			p.write("-> " + e.ReturnType.String() + " ")
+		}
+		p.block(e.Body)
+
+	case *ast.CallExpression:
+		p.expression(e.Function, parser.CALL)
+		p.write("(")
+		p.expressionList(e.Arguments)
+		p.write(")")
+
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.expressionList(e.Elements)
+		p.write("]")
+
+	case *ast.IndexExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("]")
+	}
+
+	if brackets {
+		p.write(")")
+	}
+}
+
+// heredocs have their lines indented to match the code around them, which
+// the lexer takes off again
+func (p *printer) stringLiteral(s *ast.StringLiteral) {
+	str := s.String()
+	if s.Style != ast.HeredocString || p.indent == 0 {
+		p.write(str)
+		return
+	}
+
+	indent := strings.Repeat(indentation, p.indent)
+	lines := strings.Split(str, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	p.write(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,89 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func TestSource(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.input")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		src, err := os.ReadFile(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		golden, err := os.ReadFile(strings.TrimSuffix(input, ".input") + ".golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		formatted, err := Source(src)
+		if err != nil {
+			t.Fatalf("%s: %s", input, err)
+		}
+		if string(formatted) != string(golden) {
+			t.Errorf("%s: formatted wrong.\nexpected=\n%s\ngot=\n%s", input, golden, formatted)
+		}
+
+		// formatting must not change what the program means
+		before := parser.New(lexer.New(string(src))).ParseProgram()
+		after := parser.New(lexer.New(string(formatted))).ParseProgram()
+		if before.String() != after.String() {
+			t.Errorf("%s: formatting changed the program.\nbefore=%q\nafter=%q",
+				input, before.String(), after.String())
+		}
+	}
+}
+
+func TestSourceIsIdempotent(t *testing.T) {
+	files, err := filepath.Glob("testdata/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		once, err := Source(src)
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%s: formatting is not idempotent.\nonce=\n%s\ntwice=\n%s", file, once, twice)
+		}
+	}
+}
+
+func TestSourceParseErrors(t *testing.T) {
+	_, err := Source([]byte("let = 5;"))
+	if err == nil {
+		t.Fatalf("expected an error for a program that doesn't parse")
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parser.New(lexer.New("((a * b)) + -(c + d); return a;")).ParseProgram()
+
+	if Node(program) != "a * b + -(c + d);\nreturn a;\n" {
+		t.Errorf("program formatted wrong. got=%q", Node(program))
+	}
+	if Node(program.Statements[1]) != "return a;" {
+		t.Errorf("statement formatted wrong. got=%q", Node(program.Statements[1]))
+	}
+}
@@ -1,11 +1,17 @@
 package lexer
 
-import "monkey/token"
+import (
+	"strings"
+
+	"monkey/token"
+)
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	start := l.pos()
+	tok.Pos = start
 
 	// need to add a case where it reads a string and then checks
 	// if it is a keyword and then assign tok to that token
@@ -26,9 +32,24 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RBRACKET, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if n := len(l.interpolations); n > 0 {
+			// the brace closing a ${ takes us back to reading the string
+			if l.interpolations[n-1] == 0 {
+				l.interpolations = l.interpolations[:n-1]
+				return l.readString(start, false)
+			}
+			l.interpolations[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LSQUARE, l.ch)
+	case ']':
+		tok = newToken(token.RSQUARE, l.ch)
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case ',':
@@ -43,33 +64,61 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.EXCLAM, l.ch)
 		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
+	case ':':
+		tok = newToken(token.COLON, l.ch)
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '<':
+		if l.startsHeredoc() {
+			return l.readHeredoc(start)
+		}
 		tok = newToken(token.LT, l.ch)
 	case '>':
 		tok = newToken(token.GT, l.ch)
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
+	case '"':
+		return l.readString(start, true)
+	case '`':
+		return l.readRawString(start)
 	case 0:
-		tok.Literal = ""
-		tok.Type = token.EOF
+		if l.atEnd() {
+			tok.Literal = ""
+			tok.Type = token.EOF
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIndent(tok.Literal)
+			tok.Type = l.lookupIdent(tok.Literal)
+			tok.End = l.pos()
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.INT
+			tok.End = l.pos()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	// newToken builds a fresh token so the start has to be set again
+	tok.Pos = start
+	if l.config.Dialect != nil && !l.config.Dialect.OperatorEnabled(tok.Type) {
+		tok.Type = token.ILLEGAL
+	}
 	l.readChar()
+	tok.End = l.pos()
 	return tok
 }
 
@@ -77,16 +126,27 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// comments run from // to the end of the line and are skipped along with
+// the whitespace, the cst package is what keeps hold of them
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			for l.ch != '\n' && !l.atEnd() {
+				l.readChar()
+			}
+		default:
+			return
+		}
 	}
 }
 
 // this function is only concerned with returning the next character
 // and not do anything else
 func (l *Lexer) peekChar() byte {
-	if l.position >= len(l.input) {
+	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
 		return l.input[l.readPosition]
@@ -107,16 +167,205 @@ func isWhiteSpace(ch byte) bool {
 	return ch == ' ' || ch == '\n'
 }
 
+func (l *Lexer) lookupIdent(ident string) token.TokenType {
+	if l.config.Dialect != nil {
+		return l.config.Dialect.LookupIdent(ident)
+	}
+	return token.LookupIndent(ident)
+}
+
+// readIdentifier reads a name, which starts with a letter and can have
+// digits after that
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
+// the string reading functions below read the whole literal, including the
+// quotes, and return a finished token. If the input runs out before the
+// string is closed the token is ILLEGAL and holds the text that was read
+
+// readString reads a "double quoted" string. If it finds a ${ it stops there
+// and the lexer goes back to giving out normal tokens until the matching },
+// which calls readString again with first set to false to read the rest
+func (l *Lexer) readString(start token.Position, first bool) token.Token {
+	var out strings.Builder
+
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			l.readChar()
+			if first {
+				return l.stringToken(token.STRING, out.String(), start)
+			}
+			return l.stringToken(token.INTERP_END, out.String(), start)
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteByte(l.ch)
+				continue
+			}
+			l.readChar()
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			if first {
+				return l.stringToken(token.INTERP_START, out.String(), start)
+			}
+			return l.stringToken(token.INTERP_MID, out.String(), start)
+		case 0:
+			if l.atEnd() {
+				return l.illegalToken(start)
+			}
+			out.WriteByte(l.ch)
+		case '\\':
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case 0:
+				if l.atEnd() {
+					return l.illegalToken(start)
+				}
+				out.WriteByte(l.ch)
+			default:
+				// covers \" and \\ as well as anything we don't know about
+				out.WriteByte(l.ch)
+			}
+		default:
+			out.WriteByte(l.ch)
+		}
+	}
+}
+
+// raw strings can span lines and don't do anything with backslashes
+func (l *Lexer) readRawString(start token.Position) token.Token {
+	l.readChar()
+	position := l.position
+
+	for l.ch != '`' {
+		if l.atEnd() {
+			return l.illegalToken(start)
+		}
+		l.readChar()
+	}
+
+	value := l.input[position:l.position]
+	l.readChar()
+	return l.stringToken(token.RAW_STRING, value, start)
+}
+
+// startsHeredoc checks for <<~ followed by the start of a tag
+func (l *Lexer) startsHeredoc() bool {
+	rest := l.input[l.position:]
+	return strings.HasPrefix(rest, "<<~") && len(rest) > 3 && isLetter(rest[3])
+}
+
+// readHeredoc reads a string of the form
+//
+//	<<~SQL
+//	    select *
+//	    from users
+//	    SQL
+//
+// which starts on the line after the tag and ends at the first line starting
+// with the tag, after which the line carries on as normal, so the statement
+// can be finished with SQL; for example. Like Ruby's squiggly heredoc the
+// indentation the lines have in common is removed
+func (l *Lexer) readHeredoc(start token.Position) token.Token {
+	// skip over <<~
+	l.readChar()
+	l.readChar()
+	l.readChar()
+
+	tag := l.readIdentifier()
+	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+		l.readChar()
+	}
+	if l.ch != '\n' {
+		return l.illegalToken(start)
+	}
+
+	lines := []string{}
+	for {
+		// move past the newline ending the previous line
+		l.readChar()
+		position := l.position
+		for l.ch == ' ' || l.ch == '\t' {
+			l.readChar()
+		}
+
+		if l.atTag(tag) {
+			for range tag {
+				l.readChar()
+			}
+			return l.stringToken(token.HEREDOC, dedent(lines), start)
+		}
+
+		for l.ch != '\n' && !l.atEnd() {
+			l.readChar()
+		}
+		line := strings.TrimSuffix(l.input[position:l.position], "\r")
+		if l.atEnd() {
+			return l.illegalToken(start)
+		}
+		lines = append(lines, line)
+	}
+}
+
+// atTag checks whether the input carries on with tag as a whole word
+func (l *Lexer) atTag(tag string) bool {
+	rest := l.input[l.position:]
+	if !strings.HasPrefix(rest, tag) {
+		return false
+	}
+	return len(rest) == len(tag) || !isLetter(rest[len(tag)]) && !isDigit(rest[len(tag)])
+}
+
+// dedent removes the leading whitespace all of the non blank lines share and
+// joins the lines back up, each ending in a newline
+func dedent(lines []string) string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	var out strings.Builder
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			line = ""
+		} else {
+			line = line[indent:]
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+func (l *Lexer) stringToken(t token.TokenType, value string, start token.Position) token.Token {
+	return token.Token{Type: t, Literal: value, Pos: start, End: l.pos()}
+}
+
+func (l *Lexer) illegalToken(start token.Position) token.Token {
+	literal := l.input[start.Offset:min(l.position, len(l.input))]
+	return token.Token{Type: token.ILLEGAL, Literal: literal, Pos: start, End: l.pos()}
+}
+
 func (l *Lexer) readNumber() string {
 	position := l.position
 
@@ -127,27 +376,96 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
+// Lookahead is how many characters after the end of a token the lexer may
+// have looked at to decide where the token ends. A < needs the most, as it
+// has to check for a heredoc's <<~ followed by a letter
+const Lookahead = 3
+
 type Lexer struct {
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+	config       Config
+
+	// one entry for each ${ we are inside of, counting the braces opened
+	// since then so we know which } ends the interpolation
+	interpolations []int
+}
+
+// Config changes how the lexer reads its input, the zero value gives the
+// default language
+type Config struct {
+	// Dialect sets the keywords and which operators are turned off, nil
+	// means the default ones
+	Dialect *token.Dialect
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithConfig(input, Config{})
+}
+
+func NewWithConfig(input string, config Config) *Lexer {
+	l := &Lexer{input: input, line: 1, config: config}
+	l.readChar()
+	return l
+}
+
+// NewAt returns a lexer that starts part way through input, pos has to be
+// the position of a character in input as given by an earlier token. config
+// should be the one the earlier tokens were read with
+func NewAt(input string, pos token.Position, config Config) *Lexer {
+	l := &Lexer{
+		input:        input,
+		position:     pos.Offset,
+		readPosition: pos.Offset,
+		line:         pos.Line,
+		column:       pos.Column - 1,
+		config:       config,
+	}
 	l.readChar()
 	return l
 }
 
+// InInterpolation reports whether the lexer is part way through a ${ } in a
+// string, where starting a new lexer would read the input differently
+func (l *Lexer) InInterpolation() bool {
+	return len(l.interpolations) > 0
+}
+
+// pos returns the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
+}
+
+// atEnd is whether the input has run out, as opposed to l.ch being a NUL
+// byte in the middle of it
+func (l *Lexer) atEnd() bool {
+	return l.position >= len(l.input)
+}
+
 func (l *Lexer) readChar() {
+	// once we are past the end of the input there is nothing left to move,
+	// this keeps the position of repeated EOF tokens the same
+	if l.readPosition > len(l.input) {
+		return
+	}
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
 	if l.readPosition >= len(l.input) {
 		// setting this to 0 which is the ASCII code for "NUL"
-		// and signifies either EOF or not read anything yet
+		// and signifies either EOF or not read anything yet. A NUL in
+		// the input reads as 0 too, atEnd tells them apart
 		l.ch = 0
 	} else {
 		l.ch = l.input[l.readPosition]
 	}
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column += 1
 }
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"monkey/token"
@@ -28,6 +30,8 @@ func TestNextToken(t *testing.T) {
 
 		10 == 10;
 		10 != 9;
+		[1, 2];
+		fn(a: int) -> bool
 	`
 
 	tests := []struct {
@@ -118,6 +122,20 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.LSQUARE, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RSQUARE, "]"},
+		{token.SEMICOLON, ";"},
+		{token.FUNCTION, "fn"},
+		{token.LBRACKET, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RBRACKET, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "bool"},
 		{token.EOF, ""},
 	}
 
@@ -137,3 +155,388 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestPeekCharAtEndOfInput(t *testing.T) {
+	// a two character operator check on the last character of the input
+	// used to read past the end of the string
+	for _, input := range []string{"=", "!", "x ="} {
+		tokens, _ := Tokenize(input)
+		last := tokens[len(tokens)-1]
+		if last.Type != token.EOF {
+			t.Errorf("input %q - last token not EOF. got=%q", input, last.Type)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("let x = 5;")
+	if err != nil {
+		t.Fatalf("Tokenize returned an error: %s", err)
+	}
+
+	expected := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON, token.EOF,
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tt := range expected {
+		if tokens[i].Type != tt {
+			t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tokens[i].Type)
+		}
+	}
+
+	tokens, err = Tokenize("let x = 5 @ 3;")
+	if err == nil {
+		t.Fatalf("expected an error for an illegal character")
+	}
+	if tokens[len(tokens)-1].Type != token.EOF {
+		t.Errorf("tokens should still end in EOF when there is an error")
+	}
+}
+
+func TestTokensIterator(t *testing.T) {
+	l := New("a + b")
+
+	literals := []string{}
+	for tok := range l.Tokens() {
+		literals = append(literals, tok.Literal)
+	}
+
+	expected := []string{"a", "+", "b"}
+	if len(literals) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%v, got=%v", expected, literals)
+	}
+	for i := range expected {
+		if literals[i] != expected[i] {
+			t.Errorf("literals[%d] wrong. expected=%q, got=%q", i, expected[i], literals[i])
+		}
+	}
+
+	// stopping early should not consume the rest of the input
+	l = New("a + b")
+	for range l.Tokens() {
+		break
+	}
+	if tok := l.NextToken(); tok.Literal != "+" {
+		t.Errorf("expected lexer to resume at '+'. got=%q", tok.Literal)
+	}
+}
+
+func TestStream(t *testing.T) {
+	input := "let add = fn(x, y) { x + y; };"
+	expected, _ := Tokenize(input)
+
+	i := 0
+	for tok := range Stream(context.Background(), input) {
+		if i >= len(expected) {
+			t.Fatalf("stream sent more tokens than expected")
+		}
+		if tok != expected[i] {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Errorf("wrong number of tokens. expected=%d, got=%d", len(expected), i)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	input := strings.Repeat("let x = 5; ", 10000)
+
+	ch := Stream(ctx, input)
+	<-ch
+	cancel()
+
+	count := 1
+	for range ch {
+		count++
+	}
+	if count >= 50000 {
+		t.Errorf("stream was not cancelled, got all %d tokens", count)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := "let x = 10;\n  x == y // compare\n// done\n!z"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+		expectedEnd     token.Position
+	}{
+		{"let", token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{"x", token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"=", token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{"10", token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{";", token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{"x", token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 15, Line: 2, Column: 4}},
+		{"==", token.Position{Offset: 16, Line: 2, Column: 5}, token.Position{Offset: 18, Line: 2, Column: 7}},
+		{"y", token.Position{Offset: 19, Line: 2, Column: 8}, token.Position{Offset: 20, Line: 2, Column: 9}},
+		{"!", token.Position{Offset: 40, Line: 4, Column: 1}, token.Position{Offset: 41, Line: 4, Column: 2}},
+		{"z", token.Position{Offset: 41, Line: 4, Column: 2}, token.Position{Offset: 42, Line: 4, Column: 3}},
+		{"", token.Position{Offset: 42, Line: 4, Column: 3}, token.Position{Offset: 42, Line: 4, Column: 3}},
+		// asking again after EOF should not move anything along
+		{"", token.Position{Offset: 42, Line: 4, Column: 3}, token.Position{Offset: 42, Line: 4, Column: 3}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestDialect(t *testing.T) {
+	dialect := token.NewDialect()
+	dialect.AddKeyword("func", token.FUNCTION)
+	dialect.RemoveKeyword("fn")
+	dialect.DisableOperator(token.ASTERISK)
+	dialect.DisableOperator(token.NOT_EQ)
+	// only operators can be turned off, turning off the end of the input
+	// would leave the parser reading forever
+	for _, tt := range []token.TokenType{token.EOF, token.LBRACE, token.IDENT, token.LET} {
+		if err := dialect.DisableOperator(tt); err == nil {
+			t.Errorf("expected DisableOperator(%s) to fail", tt)
+		}
+	}
+
+	input := `func fn let 2 * 3 != 4 == 5`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.FUNCTION, "func"},
+		{token.IDENT, "fn"},
+		{token.LET, "let"},
+		{token.INT, "2"},
+		{token.ILLEGAL, "*"},
+		{token.INT, "3"},
+		{token.ILLEGAL, "!="},
+		{token.INT, "4"},
+		{token.EQ, "=="},
+		{token.INT, "5"},
+		{token.EOF, ""},
+	}
+
+	l := NewWithConfig(input, Config{Dialect: dialect})
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	// the default language should be left alone
+	l = New(input)
+	if tok := l.NextToken(); tok.Type != token.IDENT {
+		t.Errorf("default dialect changed. 'func' lexed as %q", tok.Type)
+	}
+	if tok := l.NextToken(); tok.Type != token.FUNCTION {
+		t.Errorf("default dialect changed. 'fn' lexed as %q", tok.Type)
+	}
+	if token.NewDialect().LookupIdent("func") != token.IDENT {
+		t.Errorf("NewDialect should not share keywords with other dialects")
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := "\"foo bar\" \"a\\\"b\\\\c\\nd\" `raw \\n\nline` <<~SQL\n    select *\n\n      from t\n    SQL\n\"open"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.STRING, "foo bar", 1},
+		{token.STRING, "a\"b\\c\nd", 1},
+		{token.RAW_STRING, "raw \\n\nline", 2},
+		{token.HEREDOC, "select *\n\n  from t\n", 6},
+		{token.ILLEGAL, "\"open", 7},
+		{token.EOF, "", 7},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.End.Line != tt.expectedLine {
+			t.Errorf("tests[%d] - end line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.End.Line)
+		}
+	}
+}
+
+func TestHeredocNotStarted(t *testing.T) {
+	// without a tag straight after it <<~ is just operators
+	tokens, _ := Tokenize("a <<~ b")
+	expected := []token.TokenType{token.IDENT, token.LT, token.LT, token.ILLEGAL, token.IDENT, token.EOF}
+
+	for i, tt := range expected {
+		if tokens[i].Type != tt {
+			t.Errorf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tokens[i].Type)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	input := `"Hello ${name}, ${count + {a: 1}} \${x} ${"in ${1}"}!"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INTERP_START, "Hello "},
+		{token.IDENT, "name"},
+		{token.INTERP_MID, ", "},
+		{token.IDENT, "count"},
+		{token.PLUS, "+"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.INTERP_MID, " ${x} "},
+		{token.INTERP_START, "in "},
+		{token.INT, "1"},
+		{token.INTERP_END, ""},
+		{token.INTERP_END, "!"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+	if l.InInterpolation() {
+		t.Errorf("lexer should not be in an interpolation at the end")
+	}
+}
+
+func TestHeredocEndsMidLine(t *testing.T) {
+	input := "f(<<~A\n  x\n  AB\n  A);"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "f"},
+		{token.LBRACKET, "("},
+		{token.HEREDOC, "x\nAB\n"},
+		{token.RBRACKET, ")"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIdentifierDigits(t *testing.T) {
+	tokens, _ := Tokenize("sha256(x2) 2x")
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "sha256"},
+		{Type: token.LBRACKET, Literal: "("},
+		{Type: token.IDENT, Literal: "x2"},
+		{Type: token.RBRACKET, Literal: ")"},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.EOF, Literal: ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range expected {
+		if tokens[i].Type != tok.Type || tokens[i].Literal != tok.Literal {
+			t.Errorf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tok.Type, tok.Literal, tokens[i].Type, tokens[i].Literal)
+		}
+	}
+}
+
+func TestHeredocTagDigits(t *testing.T) {
+	// tags can have digits like identifiers, and a line only ends the
+	// heredoc when the tag is a whole word
+	tokens, _ := Tokenize("<<~A1\n  hi\n  A12\n  A1;")
+	expected := []token.Token{
+		{Type: token.HEREDOC, Literal: "hi\nA12\n"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.EOF, Literal: ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range expected {
+		if tokens[i].Type != tok.Type || tokens[i].Literal != tok.Literal {
+			t.Errorf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tok.Type, tok.Literal, tokens[i].Type, tokens[i].Literal)
+		}
+	}
+}
+
+func TestNULBytes(t *testing.T) {
+	// only the end of the input is EOF, a NUL before it is an illegal token
+	// on its own and can be in strings and comments
+	tokens, _ := Tokenize("a\x00b \"c\x00d\" // e\x00f\nx")
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.ILLEGAL, Literal: "\x00"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.STRING, Literal: "c\x00d"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.EOF, Literal: ""},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tok := range expected {
+		if tokens[i].Type != tok.Type || tokens[i].Literal != tok.Literal {
+			t.Errorf("tokens[%d] wrong. expected=%q %q, got=%q %q", i, tok.Type, tok.Literal, tokens[i].Type, tokens[i].Literal)
+		}
+	}
+}
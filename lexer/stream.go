@@ -0,0 +1,76 @@
+package lexer
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"monkey/token"
+)
+
+// Tokenize lexes the whole of src and returns every token, ending with the
+// EOF token. If an ILLEGAL token is found all tokens are still returned along
+// with an error describing the first illegal character
+func Tokenize(src string) ([]token.Token, error) {
+	var err error
+	tokens := []token.Token{}
+
+	l := New(src)
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.ILLEGAL && err == nil {
+			err = fmt.Errorf("illegal character %q at line %d, column %d",
+				tok.Literal, tok.Pos.Line, tok.Pos.Column)
+		}
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens, err
+		}
+	}
+}
+
+// Tokens returns an iterator over the remaining tokens of the lexer, so the
+// usual loop can be written as
+//
+//	for tok := range l.Tokens() { ... }
+//
+// The EOF token is not yielded, the iterator simply stops
+func (l *Lexer) Tokens() iter.Seq[token.Token] {
+	return func(yield func(token.Token) bool) {
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			if !yield(tok) {
+				return
+			}
+		}
+	}
+}
+
+// how many tokens Stream is allowed to lex ahead of whoever is reading
+const streamBuffer = 64
+
+// Stream lexes src in its own goroutine and sends the tokens down the returned
+// channel, so the lexer can run ahead of the parser. The EOF token is sent last
+// and then the channel is closed. If ctx is cancelled the goroutine stops and
+// closes the channel early without sending EOF
+func Stream(ctx context.Context, src string) <-chan token.Token {
+	out := make(chan token.Token, streamBuffer)
+
+	go func() {
+		defer close(out)
+
+		l := New(src)
+		for {
+			tok := l.NextToken()
+			select {
+			case out <- tok:
+			case <-ctx.Done():
+				return
+			}
+			if tok.Type == token.EOF {
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,241 @@
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+)
+
+// The lint package looks for code that is valid but probably a mistake.
+// Each check is a Rule, and Config picks which rules run. A finding can be
+// silenced with a comment naming the rule, either at the end of the line it
+// is on or on its own on the line before:
+//
+//	let unused = 1; // lint:ignore unused
+//
+//	// lint:ignore self-compare division-by-zero
+//	let nan = x == x / 0;
+//
+// A lint:ignore comment without any rule names silences every rule
+
+// Rule is a single check. Rules report what they find through the Pass
+type Rule interface {
+	Name() string
+	Doc() string
+	Check(pass *Pass)
+}
+
+// Pass is what a rule gets to look at, the program along with what the
+// resolver worked out about its names
+type Pass struct {
+	Program *ast.Program
+	Info    *resolver.Info
+
+	rule        string
+	diagnostics *[]Diagnostic
+}
+
+// Report adds a finding covering node
+func (p *Pass) Report(node ast.Node, format string, args ...any) {
+	start, end, _ := ast.Span(node)
+	*p.diagnostics = append(*p.diagnostics, Diagnostic{
+		Rule:    p.rule,
+		Pos:     start,
+		End:     end,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+type Diagnostic struct {
+	Rule    string
+	Pos     token.Position
+	End     token.Position
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Pos.Line, d.Pos.Column, d.Message, d.Rule)
+}
+
+type Config struct {
+	// Rules is every rule that can be run, nil means DefaultRules
+	Rules []Rule
+	// Enabled turns rules on and off by name, rules that aren't in it run
+	Enabled map[string]bool
+	// Builtins are names the program can use without declaring them
+	Builtins []string
+}
+
+// ParseConfig reads a config from JSON of the form
+//
+//	{"rules": {"unused": false, "division-by-zero": true}}
+func ParseConfig(data []byte) (Config, error) {
+	var file struct {
+		Rules map[string]bool `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Config{}, err
+	}
+	return Config{Enabled: file.Rules}, nil
+}
+
+// Validate checks the config only names rules that exist
+func (c Config) Validate() error {
+	_, err := c.rules()
+	return err
+}
+
+// rules returns the rules to run, checking every name in Enabled is a rule
+func (c Config) rules() ([]Rule, error) {
+	all := c.Rules
+	if all == nil {
+		all = DefaultRules()
+	}
+
+	known := map[string]bool{}
+	for _, r := range all {
+		known[r.Name()] = true
+	}
+	for name := range c.Enabled {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+	}
+
+	run := []Rule{}
+	for _, r := range all {
+		if enabled, ok := c.Enabled[r.Name()]; !ok || enabled {
+			run = append(run, r)
+		}
+	}
+	return run, nil
+}
+
+// Check runs the enabled rules over program, returning what they found
+// sorted by position
+func Check(program *ast.Program, config Config) ([]Diagnostic, error) {
+	rules, err := config.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := []Diagnostic{}
+	info := resolver.Resolve(program, config.Builtins...)
+	for _, r := range rules {
+		r.Check(&Pass{Program: program, Info: info, rule: r.Name(), diagnostics: &diagnostics})
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Pos.Offset < diagnostics[j].Pos.Offset
+	})
+	return diagnostics, nil
+}
+
+// Source parses src and lints it, leaving out anything silenced by a
+// lint:ignore comment. It fails if src doesn't parse
+func Source(src string, config Config) ([]Diagnostic, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	diagnostics, err := Check(program, config)
+	if err != nil {
+		return nil, err
+	}
+
+	ignores := ignoreComments(src)
+	kept := []Diagnostic{}
+	for _, d := range diagnostics {
+		if !ignores.silences(d) {
+			kept = append(kept, d)
+		}
+	}
+	return kept, nil
+}
+
+const ignoreDirective = "lint:ignore"
+
+// ignores maps a line to the rules silenced on it, where an empty list means
+// all of them, so lines without an ignore comment aren't in it at all
+type ignores map[int][]string
+
+func (ig ignores) silences(d Diagnostic) bool {
+	rules, ok := ig[d.Pos.Line]
+	if !ok {
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	for _, r := range rules {
+		if r == d.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreComments finds the lint:ignore comments in src. The lexer skips
+// comments, so they are found in the gaps between the tokens it gives
+func ignoreComments(src string) ignores {
+	ig := ignores{}
+
+	end := 0
+	gap := func(upTo int) {
+		text := src[end:upTo]
+		offset := end
+		for {
+			i := strings.Index(text, "//")
+			if i < 0 {
+				return
+			}
+			comment := text[i:]
+			if nl := strings.IndexByte(comment, '\n'); nl >= 0 {
+				comment = comment[:nl]
+			}
+			addIgnore(ig, src, offset+i, comment)
+
+			text = text[i+len(comment):]
+			offset += i + len(comment)
+		}
+	}
+
+	for tok := range lexer.New(src).Tokens() {
+		gap(tok.Pos.Offset)
+		end = tok.End.Offset
+	}
+	gap(len(src))
+
+	return ig
+}
+
+func addIgnore(ig ignores, src string, offset int, comment string) {
+	fields := strings.Fields(strings.TrimPrefix(comment, "//"))
+	if len(fields) == 0 || fields[0] != ignoreDirective {
+		return
+	}
+
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	line := strings.Count(src[:offset], "\n") + 1
+	// a comment on a line of its own is about the line after it
+	if strings.TrimSpace(src[lineStart:offset]) == "" {
+		line++
+	}
+
+	rules := fields[1:]
+	if existing, ok := ig[line]; ok && (len(existing) == 0 || len(rules) == 0) {
+		ig[line] = nil
+		return
+	}
+	ig[line] = append(ig[line], rules...)
+}
@@ -0,0 +1,135 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"monkey/ast"
+)
+
+func lint(t *testing.T, src string, config Config) []string {
+	t.Helper()
+
+	diagnostics, err := Source(src, config)
+	if err != nil {
+		t.Fatalf("Source(%q) failed: %s", src, err)
+	}
+
+	got := []string{}
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func checkDiagnostics(t *testing.T, src string, got, expected []string) {
+	t.Helper()
+
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("input %q: wrong diagnostics.\nexpected=%q\ngot=%q", src, expected, got)
+	}
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x;", []string{}},
+		{"let x = 1;", []string{"1:5: x is declared but never used (unused)"}},
+		{"let _x = 1;", []string{}},
+		// parameters don't have to be used
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{}},
+		{"let f = fn() { return 1; 2; 3 }; f();", []string{
+			"1:26: unreachable code after return (unreachable)",
+		}},
+		{"return 1;\nlet x = 2; x;", []string{"2:1: unreachable code after return (unreachable)"}},
+		{"if (true) { 1 };", []string{"1:5: condition is always the same (constant-condition)"}},
+		{"if (1 < 2) { 1 } else { 2 };", []string{"1:5: condition is always the same (constant-condition)"}},
+		{"let x = 1; if (x < 2) { 1 };", []string{}},
+		{"let x = 1; x == x;", []string{"1:12: x is compared with itself, this is always true (self-compare)"}},
+		{"let a = 1; a + 1 > a + 1;", []string{"1:12: (a + 1) is compared with itself, this is always false (self-compare)"}},
+		{"let f = fn() { 1 }; f() == f();", []string{}},
+		{"let x = 1; x / 0;", []string{"1:12: division by zero (division-by-zero)"}},
+		{"let x = 1; x / 10;", []string{}},
+	}
+
+	for _, tt := range tests {
+		checkDiagnostics(t, tt.input, lint(t, tt.input, Config{}), tt.expected)
+	}
+}
+
+func TestIgnoreComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; // lint:ignore unused", []string{}},
+		{"let x = 1; // lint:ignore", []string{}},
+		{"// lint:ignore unused\nlet x = 1;", []string{}},
+		{"let x = 1; // lint:ignore self-compare", []string{"1:5: x is declared but never used (unused)"}},
+		// a comment at the end of a line only covers that line
+		{"let y = 2; y; // lint:ignore unused\nlet x = 1;", []string{"2:5: x is declared but never used (unused)"}},
+		{"let x = 1;\n// lint:ignore self-compare division-by-zero\nx == x / 0;", []string{}},
+		{"let x = 1;\n// lint:ignore self-compare\nx == x / 0;", []string{"3:6: division by zero (division-by-zero)"}},
+		// comments that only look like they are inside strings are strings
+		{"let s = \"// lint:ignore\"; s; let x = 1;", []string{
+			"1:34: x is declared but never used (unused)",
+		}},
+		// and a directive has to start the comment
+		{"let x = 1; // see lint:ignore", []string{"1:5: x is declared but never used (unused)"}},
+	}
+
+	for _, tt := range tests {
+		checkDiagnostics(t, tt.input, lint(t, tt.input, Config{}), tt.expected)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(`{"rules": {"unused": false}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := "let x = 1; let y = 2; y / 0;"
+	checkDiagnostics(t, src, lint(t, src, config), []string{"1:23: division by zero (division-by-zero)"})
+
+	_, err = Source(src, Config{Enabled: map[string]bool{"nonsense": true}})
+	if err == nil || err.Error() != `unknown lint rule "nonsense"` {
+		t.Errorf("expected an error for an unknown rule. got=%v", err)
+	}
+
+	if _, err := ParseConfig([]byte(`{"rules": [`)); err == nil {
+		t.Errorf("expected an error for broken JSON")
+	}
+}
+
+// noLetters is a rule from outside the package
+type noLetters struct{}
+
+func (noLetters) Name() string { return "no-letters" }
+func (noLetters) Doc() string  { return "identifiers called a" }
+func (noLetters) Check(pass *Pass) {
+	ast.Inspect(pass.Program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && ident.Value == "a" {
+			pass.Report(ident, "don't call things a")
+		}
+		return true
+	})
+}
+
+func TestCustomRules(t *testing.T) {
+	config := Config{Rules: append(DefaultRules(), noLetters{})}
+
+	src := "let a = 1;\na; // lint:ignore no-letters"
+	checkDiagnostics(t, src, lint(t, src, config), []string{"1:5: don't call things a (no-letters)"})
+
+	config.Enabled = map[string]bool{"no-letters": false}
+	checkDiagnostics(t, src, lint(t, src, config), []string{})
+}
+
+func TestSourceParseErrors(t *testing.T) {
+	if _, err := Source("let = 1;", Config{}); err == nil {
+		t.Errorf("expected an error for a program that doesn't parse")
+	}
+}
@@ -0,0 +1,135 @@
+package lint
+
+import (
+	"strings"
+
+	"monkey/ast"
+	"monkey/resolver"
+)
+
+// rule is how the rules in this package are put together, other packages
+// can implement Rule however they like
+type rule struct {
+	name  string
+	doc   string
+	check func(pass *Pass)
+}
+
+func (r *rule) Name() string     { return r.name }
+func (r *rule) Doc() string      { return r.doc }
+func (r *rule) Check(pass *Pass) { r.check(pass) }
+
+// DefaultRules returns the rules that come with the linter
+func DefaultRules() []Rule {
+	return []Rule{
+		&rule{"unused", "lets that are never used, names starting with _ are left alone", checkUnused},
+		&rule{"unreachable", "statements after a return that can never run", checkUnreachable},
+		&rule{"constant-condition", "if conditions that are always the same", checkConstantCondition},
+		&rule{"self-compare", "comparing something with itself, like x == x", checkSelfCompare},
+		&rule{"division-by-zero", "dividing by a literal 0", checkDivisionByZero},
+	}
+}
+
+func checkUnused(pass *Pass) {
+	for _, d := range pass.Info.Defs {
+		if d.Kind != resolver.Let || len(d.Uses) != 0 || strings.HasPrefix(d.Name, "_") {
+			continue
+		}
+		pass.Report(d.Ident, "%s is declared but never used", d.Name)
+	}
+}
+
+func checkUnreachable(pass *Pass) {
+	check := func(stmts []ast.Statement) {
+		for i, s := range stmts {
+			if _, ok := s.(*ast.ReturnStatement); ok && i+1 < len(stmts) {
+				// only the first one, the rest follow from it
+				pass.Report(stmts[i+1], "unreachable code after return")
+				return
+			}
+		}
+	}
+
+	ast.Inspect(pass.Program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Program:
+			check(n.Statements)
+		case *ast.BlockStatement:
+			check(n.Statements)
+		}
+		return true
+	})
+}
+
+func checkConstantCondition(pass *Pass) {
+	ast.Inspect(pass.Program, func(n ast.Node) bool {
+		if exp, ok := n.(*ast.IfExpression); ok && exp.Condition != nil && constant(exp.Condition) {
+			pass.Report(exp.Condition, "condition is always the same")
+		}
+		return true
+	})
+}
+
+// constant reports whether exp is made up of nothing but literals
+func constant(exp ast.Expression) bool {
+	switch e := exp.(type) {
+	case *ast.Boolean, *ast.IntegerLiteral, *ast.StringLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return e.Right != nil && constant(e.Right)
+	case *ast.InfixExpression:
+		return e.Left != nil && e.Right != nil && constant(e.Left) && constant(e.Right)
+	}
+	return false
+}
+
+// what comparing something with itself always gives
+var selfComparisons = map[string]string{
+	"==": "true",
+	"!=": "false",
+	"<":  "false",
+	">":  "false",
+}
+
+func checkSelfCompare(pass *Pass) {
+	ast.Inspect(pass.Program, func(n ast.Node) bool {
+		exp, ok := n.(*ast.InfixExpression)
+		if !ok || exp.Left == nil || exp.Right == nil {
+			return true
+		}
+		result, ok := selfComparisons[exp.Operator]
+		if !ok || !pure(exp.Left) {
+			return true
+		}
+		if ast.Equal(exp.Left, exp.Right, ast.EqualOptions{IgnorePositions: true}) {
+			pass.Report(exp, "%s is compared with itself, this is always %s", exp.Left.String(), result)
+		}
+		return true
+	})
+}
+
+// pure reports whether exp gives the same value every time, which isn't so
+// once it calls a function
+func pure(exp ast.Expression) bool {
+	calls := false
+	ast.Inspect(exp, func(n ast.Node) bool {
+		if _, ok := n.(*ast.CallExpression); ok {
+			calls = true
+		}
+		return !calls
+	})
+	return !calls
+}
+
+func checkDivisionByZero(pass *Pass) {
+	ast.Inspect(pass.Program, func(n ast.Node) bool {
+		exp, ok := n.(*ast.InfixExpression)
+		if !ok || exp.Operator != "/" {
+			return true
+		}
+		if zero, ok := exp.Right.(*ast.IntegerLiteral); ok && zero.Value == 0 {
+			pass.Report(exp, "division by zero")
+		}
+		return true
+	})
+}
@@ -0,0 +1,197 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+
+	"monkey/ast"
+	"monkey/format"
+	"monkey/resolver"
+	"monkey/types"
+)
+
+// identifierAt finds the identifier the cursor is on, which includes being
+// just after its last character
+func (doc *document) identifierAt(pos Position) *ast.Identifier {
+	offset := toOffset(doc.parsed.Source, pos)
+
+	var found *ast.Identifier
+	ast.Inspect(doc.parsed.Program, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		if ident, ok := n.(*ast.Identifier); ok &&
+			ident.Token.Pos.Offset <= offset && offset <= ident.Token.End.Offset {
+			found = ident
+		}
+		return found == nil
+	})
+	return found
+}
+
+// declarationAt gives the declaration of the identifier at pos, whether it
+// is the one declaring it or a use of it
+func (s *Server) declarationAt(params TextDocumentPositionParams) (*document, *ast.Identifier, *resolver.Declaration, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ident := doc.identifierAt(params.Position)
+	if ident == nil {
+		return doc, nil, nil, nil
+	}
+	d := doc.names.Defs[ident]
+	if d == nil {
+		d = doc.names.Uses[ident]
+	}
+	return doc, ident, d, nil
+}
+
+func (doc *document) identRange(ident *ast.Identifier) Range {
+	return toRange(doc.parsed.Source, ident.Token.Pos, ident.Token.End)
+}
+
+func (s *Server) hover(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ident, d, err := s.declarationAt(p)
+	if err != nil || d == nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	text.WriteString("```monkey\n")
+	if d.Kind == resolver.Let {
+		text.WriteString("let ")
+	}
+	text.WriteString(d.Name)
+	if d.Ident != nil {
+		if t, ok := doc.types.Defs[d.Ident]; ok {
+			text.WriteString(": " + types.TypeString(t))
+		}
+	}
+	text.WriteString("\n```")
+	if d.Kind != resolver.Let {
+		text.WriteString("\n\n" + d.Kind.String())
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: text.String()},
+		Range:    doc.identRange(ident),
+	}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, _, d, err := s.declarationAt(p)
+	if err != nil || d == nil || d.Ident == nil {
+		return nil, err
+	}
+
+	return &Location{URI: p.TextDocument.URI, Range: doc.identRange(d.Ident)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (any, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, _, d, err := s.declarationAt(p.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	if d == nil {
+		return locations, nil
+	}
+	if p.Context.IncludeDeclaration && d.Ident != nil {
+		locations = append(locations, Location{URI: p.TextDocument.URI, Range: doc.identRange(d.Ident)})
+	}
+	for _, use := range d.Uses {
+		locations = append(locations, Location{URI: p.TextDocument.URI, Range: doc.identRange(use)})
+	}
+	return locations, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (any, error) {
+	var p DocumentSymbolParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.symbols(doc.parsed.Program), nil
+}
+
+// symbols gives a symbol for each let in node, with the lets inside its
+// value as its children
+func (doc *document) symbols(node ast.Node) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		let, ok := n.(*ast.LetStatement)
+		if !ok || let.Name == nil {
+			return true
+		}
+
+		start, end, _ := ast.Span(let)
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           SymbolVariable,
+			Range:          toRange(doc.parsed.Source, start, end),
+			SelectionRange: doc.identRange(let.Name),
+		}
+		if t, ok := doc.types.Defs[let.Name]; ok {
+			symbol.Detail = types.TypeString(t)
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = SymbolFunction
+		}
+		if let.Value != nil {
+			symbol.Children = doc.symbols(let.Value)
+		}
+
+		symbols = append(symbols, symbol)
+		return false
+	})
+
+	return symbols
+}
+
+// formatting replaces the whole document with it formatted. Documents that
+// don't parse are left as they are
+func (s *Server) formatting(params json.RawMessage) (any, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	src := doc.parsed.Source
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return nil, nil
+	}
+
+	edits := []TextEdit{}
+	if string(formatted) != src {
+		edits = append(edits, TextEdit{
+			Range:   Range{End: toPosition(src, len(src))},
+			NewText: string(formatted),
+		})
+	}
+	return edits, nil
+}
@@ -0,0 +1,65 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"monkey/wire"
+)
+
+// message is any JSON-RPC message. Requests have an ID and a Method,
+// notifications only a Method, and responses an ID along with a Result or
+// an Error
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// ResponseError is the error in a response to a request that failed
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// error codes from JSON-RPC and LSP
+const (
+	codeParseError           = -32700
+	codeInvalidRequest       = -32600
+	codeMethodNotFound       = -32601
+	codeInvalidParams        = -32602
+	codeInternalError        = -32603
+	codeServerNotInitialized = -32002
+)
+
+// readMessage reads one message, giving a *ResponseError if it isn't JSON
+func readMessage(r *bufio.Reader) (*message, error) {
+	body, err := wire.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var m message
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, &ResponseError{Code: codeParseError, Message: err.Error()}
+	}
+	return &m, nil
+}
+
+func writeMessage(w io.Writer, m *message) error {
+	m.JSONRPC = "2.0"
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return wire.WriteMessage(w, body)
+}
@@ -0,0 +1,316 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"monkey/evaluator"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"monkey/types"
+)
+
+// The lsp package is a Language Server Protocol server for Monkey, which
+// gives editors diagnostics, hover, go to definition, find references,
+// document symbols and formatting. It talks JSON-RPC over a reader and a
+// writer, which are stdin and stdout for monkey lsp.
+//
+// Documents are kept as a parser.Document, so each change the editor sends
+// only parses the statements it touches again
+
+// Server handles the messages from one client
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	log io.Writer
+
+	initialized bool
+	shutdown    bool
+	documents   map[string]*document
+}
+
+// NewServer makes a server reading messages from in and writing them to out.
+// Problems that can't be sent back to the client are written to log
+func NewServer(in io.Reader, out, log io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		log:       log,
+		documents: map[string]*document{},
+	}
+}
+
+// document is an open file along with what is known about it
+type document struct {
+	version int
+	parsed  *parser.Document
+	names   *resolver.Info
+	types   *types.Info
+}
+
+func newDocument(version int, parsed *parser.Document) *document {
+	return &document{
+		version: version,
+		parsed:  parsed,
+		names:   resolver.Resolve(parsed.Program, evaluator.BuiltinNames()...),
+		types:   types.Check(parsed.Program, types.Builtins()),
+	}
+}
+
+var requests = map[string]func(s *Server, params json.RawMessage) (any, error){
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+var notifications = map[string]func(s *Server, params json.RawMessage) error{
+	"initialized":            func(*Server, json.RawMessage) error { return nil },
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Run handles messages until the client sends exit. It returns nil if the
+// client asked the server to shut down first, as the protocol says it should
+func (s *Server) Run() error {
+	for {
+		m, err := readMessage(s.in)
+		var bad *ResponseError
+		if errors.As(err, &bad) {
+			if err := s.send(&message{ID: json.RawMessage("null"), Error: bad}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return errors.New("client closed the connection without sending exit")
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case m.Method == "exit":
+			if !s.shutdown {
+				return errors.New("client sent exit without shutdown")
+			}
+			return nil
+
+		case m.ID == nil:
+			s.notification(m)
+
+		default:
+			if err := s.request(m); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) request(m *message) error {
+	result, err := s.handle(m)
+
+	response := &message{ID: m.ID}
+	if err != nil {
+		var rerr *ResponseError
+		if !errors.As(err, &rerr) {
+			rerr = &ResponseError{Code: codeInternalError, Message: err.Error()}
+		}
+		response.Error = rerr
+	} else if response.Result, err = json.Marshal(result); err != nil {
+		response.Error = &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+
+	return s.send(response)
+}
+
+func (s *Server) handle(m *message) (any, error) {
+	handler, ok := requests[m.Method]
+	switch {
+	case !s.initialized && m.Method != "initialize":
+		return nil, &ResponseError{Code: codeServerNotInitialized, Message: "the server hasn't been initialized"}
+	case s.shutdown:
+		return nil, &ResponseError{Code: codeInvalidRequest, Message: "the server is shutting down"}
+	case !ok:
+		return nil, &ResponseError{Code: codeMethodNotFound, Message: "unknown method " + m.Method}
+	}
+	return handler(s, m.Params)
+}
+
+// notification handles a notification. Nothing can be sent back about one,
+// so problems go to the log
+func (s *Server) notification(m *message) {
+	handler, ok := notifications[m.Method]
+	if !ok || !s.initialized {
+		// the client can send notifications we don't know about, the
+		// protocol says to ignore them
+		return
+	}
+	if err := handler(s, m.Params); err != nil {
+		fmt.Fprintf(s.log, "%s: %s\n", m.Method, err)
+	}
+}
+
+func (s *Server) send(m *message) error {
+	return writeMessage(s.out, m)
+}
+
+func (s *Server) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.send(&message{Method: method, Params: data})
+}
+
+// decode reads the params of a message into v
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	s.initialized = true
+
+	var result InitializeResult
+	result.ServerInfo.Name = "monkey"
+	result.Capabilities.TextDocumentSync.OpenClose = true
+	result.Capabilities.TextDocumentSync.Change = 2
+	result.Capabilities.HoverProvider = true
+	result.Capabilities.DefinitionProvider = true
+	result.Capabilities.ReferencesProvider = true
+	result.Capabilities.DocumentSymbolProvider = true
+	result.Capabilities.DocumentFormattingProvider = true
+	return result, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (any, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+
+	doc := newDocument(p.TextDocument.Version, parser.ParseDocument(p.TextDocument.Text))
+	s.documents[p.TextDocument.URI] = doc
+	return s.publishDiagnostics(p.TextDocument.URI, doc)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return err
+	}
+
+	parsed := doc.parsed
+	for _, change := range p.ContentChanges {
+		if change.Range == nil {
+			parsed = parser.ParseDocument(change.Text)
+			continue
+		}
+		parsed = parsed.Apply(parser.Edit{
+			Start: toOffset(parsed.Source, change.Range.Start),
+			End:   toOffset(parsed.Source, change.Range.End),
+			Text:  change.Text,
+		})
+	}
+
+	doc = newDocument(p.TextDocument.Version, parsed)
+	s.documents[p.TextDocument.URI] = doc
+	return s.publishDiagnostics(p.TextDocument.URI, doc)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return err
+	}
+
+	delete(s.documents, p.TextDocument.URI)
+	// the editor keeps showing diagnostics until they are cleared
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: "no open document " + uri}
+	}
+	return doc, nil
+}
+
+func (s *Server) publishDiagnostics(uri string, doc *document) error {
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Version:     doc.version,
+		Diagnostics: doc.diagnostics(),
+	})
+}
+
+// diagnostics gives the parser errors, or when there aren't any the
+// resolver's diagnostics and the type errors. While there are parser errors
+// the statements with them are missing, so there would be a lot of
+// confusing errors about names they declare
+func (doc *document) diagnostics() []Diagnostic {
+	src := doc.parsed.Source
+
+	type found struct {
+		offset int
+		Diagnostic
+	}
+	all := []found{}
+	add := func(start, end token.Position, severity DiagnosticSeverity, message string) {
+		all = append(all, found{start.Offset, Diagnostic{
+			Range:    toRange(src, start, end),
+			Severity: severity,
+			Source:   "monkey",
+			Message:  message,
+		}})
+	}
+
+	if errs := doc.parsed.ErrorList(); len(errs) != 0 {
+		for _, e := range errs {
+			add(e.Pos, e.End, SeverityError, e.Message)
+		}
+	} else {
+		for _, d := range doc.names.Diagnostics {
+			severity := SeverityError
+			if d.Severity == resolver.Warning {
+				severity = SeverityWarning
+			}
+			add(d.Pos, d.End, severity, d.Message)
+		}
+		for _, e := range doc.types.Errors {
+			add(e.Pos, e.End, SeverityError, e.Message)
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].offset < all[j].offset })
+	diagnostics := []Diagnostic{}
+	for _, f := range all {
+		diagnostics = append(diagnostics, f.Diagnostic)
+	}
+	return diagnostics
+}
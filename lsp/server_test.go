@@ -0,0 +1,467 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is a fake editor talking to a server running in the same process
+type client struct {
+	t        *testing.T
+	toServer *io.PipeWriter
+	messages chan *message
+	done     chan error
+	nextID   int
+
+	// notifications that came while waiting for a response
+	pending []*message
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	serverIn, toServer := io.Pipe()
+	fromServer, serverOut := io.Pipe()
+	c := &client{
+		t:        t,
+		toServer: toServer,
+		messages: make(chan *message),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(serverIn, serverOut, io.Discard).Run()
+		serverOut.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(fromServer)
+		for {
+			m, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- m
+		}
+	}()
+	t.Cleanup(func() { toServer.Close() })
+
+	return c
+}
+
+// initialized makes a client that has been through initialize
+func initialized(t *testing.T) *client {
+	c := newClient(t)
+	if err := c.request("initialize", map[string]any{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) send(m *message) {
+	c.t.Helper()
+	if err := writeMessage(c.toServer, m); err != nil {
+		c.t.Fatalf("sending %s: %v", m.Method, err)
+	}
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server closed the connection")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(&message{Method: method, Params: data})
+}
+
+// request sends a request and waits for the response, decoding its result
+// into result
+func (c *client) request(method string, params any, result any) *ResponseError {
+	c.t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	c.send(&message{ID: id, Method: method, Params: data})
+
+	for {
+		m := c.receive()
+		if m.ID == nil {
+			c.pending = append(c.pending, m)
+			continue
+		}
+		if string(m.ID) != string(id) {
+			c.t.Fatalf("response for %s has id %s, expected %s", method, m.ID, id)
+		}
+		if m.Error != nil {
+			return m.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(m.Result, result); err != nil {
+				c.t.Fatalf("decoding result of %s: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+// diagnostics waits for the next diagnostics the server publishes
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	for {
+		var m *message
+		if len(c.pending) > 0 {
+			m, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			m = c.receive()
+		}
+		if m.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p PublishDiagnosticsParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		return p
+	}
+}
+
+const uri = "file:///test.mk"
+
+func (c *client) open(text string) PublishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func rng(startLine, startChar, endLine, endChar int) Range {
+	return Range{Start: Position{startLine, startChar}, End: Position{endLine, endChar}}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("expected an error before initialize. got=%v", err)
+	}
+
+	var result InitializeResult
+	if err := c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &result); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if result.ServerInfo.Name != "monkey" || !result.Capabilities.HoverProvider ||
+		result.Capabilities.TextDocumentSync.Change != 2 {
+		t.Errorf("wrong initialize result. got=%+v", result)
+	}
+
+	if err := c.request("textDocument/rename", at(0, 0), nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found. got=%v", err)
+	}
+
+	if err := c.request("shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if err := c.request("textDocument/hover", at(0, 0), nil); err == nil || err.Code != codeInvalidRequest {
+		t.Errorf("expected an error after shutdown. got=%v", err)
+	}
+	c.notify("exit", nil)
+
+	if err := <-c.done; err != nil {
+		t.Errorf("expected a clean exit. got=%v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := initialized(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err == nil {
+		t.Error("expected an error for exit without shutdown")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := initialized(t)
+
+	p := c.open("let x = 5;\nlet y = ;\n")
+	expected := []Diagnostic{{
+		Range:    rng(1, 8, 1, 9),
+		Severity: SeverityError,
+		Source:   "monkey",
+		Message:  "no prefix parse function for ; found",
+	}}
+	if p.URI != uri || p.Version != 1 || !reflect.DeepEqual(p.Diagnostics, expected) {
+		t.Errorf("wrong diagnostics.\nexpected=%+v\ngot=%+v", expected, p)
+	}
+
+	// fix it with an edit, which leaves an undefined name and a type error
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument: VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{
+			{Range: &Range{Start: Position{1, 8}, End: Position{1, 8}}, Text: "z + true"},
+		},
+	})
+	p = c.diagnostics()
+	got := []string{}
+	for _, d := range p.Diagnostics {
+		got = append(got, d.Message)
+	}
+	want := []string{"undefined variable z", "true has type bool, expected int"}
+	if p.Version != 2 || strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wrong diagnostics after edit.\nexpected=%q\ngot=%q (version %d)", want, got, p.Version)
+	}
+	if len(p.Diagnostics) == 2 && p.Diagnostics[1].Range != rng(1, 12, 1, 16) {
+		t.Errorf("wrong range for the type error. got=%+v", p.Diagnostics[1].Range)
+	}
+
+	// replacing the whole text
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let a = 1; a"}},
+	})
+	if p := c.diagnostics(); len(p.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics. got=%+v", p.Diagnostics)
+	}
+
+	// builtins are defined, and have types
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 4},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "puts(len(split(\"a b\", \" \")));\nupper(1);"}},
+	})
+	p = c.diagnostics()
+	if len(p.Diagnostics) != 1 || p.Diagnostics[0].Message != "1 has type int, expected string" {
+		t.Errorf("expected only the type error for upper. got=%+v", p.Diagnostics)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if p := c.diagnostics(); len(p.Diagnostics) != 0 {
+		t.Errorf("expected closing to clear diagnostics. got=%+v", p.Diagnostics)
+	}
+}
+
+const program = `let add = fn(a: int, b) { a + b };
+let twice = fn(f, x) {
+	let once = f(x);
+	f(once)
+};
+twice(fn(n) { add(n, 1) }, 2)
+`
+
+func TestHover(t *testing.T) {
+	c := initialized(t)
+	c.open(program)
+
+	tests := []struct {
+		pos      TextDocumentPositionParams
+		expected string
+		rng      Range
+	}{
+		{at(0, 5), "```monkey\nlet add: fn(int, int) -> int\n```", rng(0, 4, 0, 7)},
+		{at(0, 26), "```monkey\na: int\n```\n\nparameter", rng(0, 26, 0, 27)},
+		{at(5, 15), "```monkey\nlet add: fn(int, int) -> int\n```", rng(5, 14, 5, 17)},
+		{at(3, 1), "```monkey\nf: fn('a) -> 'a\n```\n\nparameter", rng(3, 1, 3, 2)},
+	}
+
+	for _, tt := range tests {
+		var hover *Hover
+		if err := c.request("textDocument/hover", tt.pos, &hover); err != nil {
+			t.Fatalf("hover failed: %v", err)
+		}
+		if hover == nil {
+			t.Errorf("%v: expected a hover", tt.pos.Position)
+			continue
+		}
+		if hover.Contents.Value != tt.expected || hover.Range != tt.rng {
+			t.Errorf("%v: wrong hover.\nexpected=%q %v\ngot=%q %v",
+				tt.pos.Position, tt.expected, tt.rng, hover.Contents.Value, hover.Range)
+		}
+	}
+
+	var hover *Hover
+	if err := c.request("textDocument/hover", at(0, 0), &hover); err != nil || hover != nil {
+		t.Errorf("expected no hover on a keyword. got=%v, %v", hover, err)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := initialized(t)
+	c.open(program)
+
+	var loc *Location
+	if err := c.request("textDocument/definition", at(5, 16), &loc); err != nil {
+		t.Fatalf("definition failed: %v", err)
+	}
+	if loc == nil || loc.URI != uri || loc.Range != rng(0, 4, 0, 7) {
+		t.Errorf("wrong definition of add. got=%+v", loc)
+	}
+
+	if err := c.request("textDocument/definition", at(3, 3), &loc); err != nil {
+		t.Fatalf("definition failed: %v", err)
+	}
+	if loc == nil || loc.Range != rng(2, 5, 2, 9) {
+		t.Errorf("wrong definition of once. got=%+v", loc)
+	}
+
+	refs := func(pos TextDocumentPositionParams, includeDecl bool) []Range {
+		t.Helper()
+		params := ReferenceParams{TextDocumentPositionParams: pos}
+		params.Context.IncludeDeclaration = includeDecl
+		var locs []Location
+		if err := c.request("textDocument/references", params, &locs); err != nil {
+			t.Fatalf("references failed: %v", err)
+		}
+		ranges := []Range{}
+		for _, l := range locs {
+			ranges = append(ranges, l.Range)
+		}
+		return ranges
+	}
+
+	// f is used twice
+	expected := []Range{rng(1, 15, 1, 16), rng(2, 12, 2, 13), rng(3, 1, 3, 2)}
+	if got := refs(at(1, 15), true); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong references to f.\nexpected=%v\ngot=%v", expected, got)
+	}
+	if got := refs(at(2, 12), false); !reflect.DeepEqual(got, expected[1:]) {
+		t.Errorf("wrong references to f without the declaration.\nexpected=%v\ngot=%v", expected[1:], got)
+	}
+	if got := refs(at(4, 0), true); len(got) != 0 {
+		t.Errorf("expected no references away from a name. got=%v", got)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := initialized(t)
+	c.open(program)
+
+	var symbols []DocumentSymbol
+	if err := c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols); err != nil {
+		t.Fatalf("documentSymbol failed: %v", err)
+	}
+
+	expected := []DocumentSymbol{
+		{
+			Name:           "add",
+			Detail:         "fn(int, int) -> int",
+			Kind:           SymbolFunction,
+			Range:          rng(0, 0, 0, 33),
+			SelectionRange: rng(0, 4, 0, 7),
+		},
+		{
+			Name:           "twice",
+			Detail:         "fn(fn('a) -> 'a, 'a) -> 'a",
+			Kind:           SymbolFunction,
+			Range:          rng(1, 0, 4, 1),
+			SelectionRange: rng(1, 4, 1, 9),
+			Children: []DocumentSymbol{{
+				Name:           "once",
+				Detail:         "'a",
+				Kind:           SymbolVariable,
+				Range:          rng(2, 1, 2, 16),
+				SelectionRange: rng(2, 5, 2, 9),
+			}},
+		},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("wrong symbols.\nexpected=%+v\ngot=%+v", expected, symbols)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c := initialized(t)
+	c.open("let x=1;\nx+1\n")
+
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	var edits []TextEdit
+	if err := c.request("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting failed: %v", err)
+	}
+	expected := []TextEdit{{Range: rng(0, 0, 2, 0), NewText: "let x = 1;\nx + 1;\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits.\nexpected=%+v\ngot=%+v", expected, edits)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = ;"}},
+	})
+	c.diagnostics()
+	edits = nil
+	if err := c.request("textDocument/formatting", params, &edits); err != nil || edits != nil {
+		t.Errorf("expected no edits for a file that doesn't parse. got=%+v, %v", edits, err)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	src := "ab\n\"é😀\" x\n"
+
+	tests := []struct {
+		offset int
+		pos    Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{0, 2}},
+		{3, Position{1, 0}},
+		// é is two bytes and one UTF-16 unit, 😀 four bytes and two units
+		{6, Position{1, 2}},
+		{10, Position{1, 4}},
+		{13, Position{1, 7}},
+		{len(src), Position{2, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := toPosition(src, tt.offset); got != tt.pos {
+			t.Errorf("toPosition(%d): expected=%v, got=%v", tt.offset, tt.pos, got)
+		}
+		if got := toOffset(src, tt.pos); got != tt.offset {
+			t.Errorf("toOffset(%v): expected=%d, got=%d", tt.pos, tt.offset, got)
+		}
+	}
+
+	// past the end of a line is the end of the line
+	if got := toOffset(src, Position{0, 10}); got != 2 {
+		t.Errorf("expected offset 2 for a character past the end. got=%d", got)
+	}
+}
+
+func TestBadMessage(t *testing.T) {
+	c := initialized(t)
+	if _, err := io.WriteString(c.toServer, "Content-Length: 5\r\n\r\n{oops"); err != nil {
+		t.Fatal(err)
+	}
+	m := c.receive()
+	if m.Error == nil || m.Error.Code != codeParseError || string(m.ID) != "null" {
+		t.Errorf("expected a parse error. got=%+v", m)
+	}
+}
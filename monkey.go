@@ -0,0 +1,249 @@
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+)
+
+// The monkey package runs Monkey programs from Go. Each VM has its own
+// variables and builtins and nothing is shared between them, so any number
+// of them can run at the same time:
+//
+//	vm := monkey.New(monkey.Options{})
+//	vm.Set("name", "world")
+//	v, err := vm.Eval(ctx, `"hello ${name}"`)
+//
+// Go values are converted to Monkey values and back as they go in and out,
+// see Set and Value.Interface for how.
+//
+// Go functions are given to programs with VM.RegisterFunc. It is a method
+// rather than a function of the package because the package keeps nothing
+// of its own, each VM has its own builtins like it has its own variables.
+//
+// Programs nobody has checked can be given limits in Options, along with
+// the context given to Eval and Call. Each limit fails with its own type of
+// error, so the host can tell which one a program ran into.
+//
+// Programs can only use files and read input through the IO in Options,
+// which is NewDirIO for a directory on disk and NewMemoryIO for files kept
+// in memory. Without one read_file, write_file, list_dir and read_line fail
+// with ErrNoIO
+
+// Options changes how a VM runs programs
+type Options struct {
+	// Stdout is where puts, print and println write to, nil throws the
+	// output away
+	Stdout io.Writer
+	// IO is the files and input programs can use, nil for none
+	IO IO
+
+	// The limits for each call to Eval or Call, zero for no limit.
+	// MaxSteps is how many nodes can be evaluated. MaxDepth is how many
+	// function calls can be running at once, and how deeply expressions can
+	// be nested. MaxAlloc is how many bytes of strings, arrays and hashes
+	// can be made, counting 8 bytes for each array element and 16 for each
+	// hash pair
+	MaxSteps int
+	MaxDepth int
+	MaxAlloc int
+}
+
+// the errors for going over the limits in Options
+type (
+	StepLimitError    = evaluator.StepLimitError
+	DepthLimitError   = evaluator.DepthLimitError
+	AllocLimitError   = evaluator.AllocLimitError
+	NestingLimitError = parser.NestingLimitError
+)
+
+// IO and what implements it, see the evaluator package
+type (
+	IO       = evaluator.IO
+	DirIO    = evaluator.DirIO
+	MemoryIO = evaluator.MemoryIO
+)
+
+var (
+	ErrNoIO        = evaluator.ErrNoIO
+	ErrOutsideRoot = evaluator.ErrOutsideRoot
+)
+
+// NewDirIO gives programs the files under root, and input to read lines
+// from, which can be nil
+func NewDirIO(root string, input io.Reader) (*DirIO, error) {
+	return evaluator.NewDirIO(root, input)
+}
+
+// NewMemoryIO gives programs a copy of files, keyed by their names, and
+// input to read lines from
+func NewMemoryIO(files map[string]string, input string) *MemoryIO {
+	return evaluator.NewMemoryIO(files, input)
+}
+
+// VM runs programs in one environment, so lets made by one call to Eval are
+// there for the next. A VM runs one thing at a time, calls made from other
+// goroutines wait their turn. Functions given to RegisterFunc can use the
+// VM while a program is calling them, see RegisterFunc
+type VM struct {
+	// busy is held while a program runs, so only one runs at a time
+	busy sync.Mutex
+	// mu is held while the VM's variables and builtins are used. A running
+	// program lets go of it while it calls a registered function
+	mu       sync.Mutex
+	in       *evaluator.Interpreter
+	env      *object.Environment
+	maxDepth int
+	// ctx is the context of the Eval or Call that is running
+	ctx context.Context
+}
+
+func New(opts Options) *VM {
+	in := evaluator.New()
+	in.Stdout = opts.Stdout
+	if in.Stdout == nil {
+		in.Stdout = io.Discard
+	}
+	in.IO = opts.IO
+	in.Limits = evaluator.Limits{MaxSteps: opts.MaxSteps, MaxDepth: opts.MaxDepth, MaxAlloc: opts.MaxAlloc}
+	return &VM{in: in, env: object.NewEnvironment(), maxDepth: opts.MaxDepth}
+}
+
+// SyntaxError is the error for a program that doesn't parse
+type SyntaxError struct {
+	Errors []parser.Error
+}
+
+func (e *SyntaxError) Error() string {
+	msgs := []string{}
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.String())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap gives the errors errors.Is and errors.As can look for, like
+// *NestingLimitError
+func (e *SyntaxError) Unwrap() []error {
+	errs := []error{}
+	for _, err := range e.Errors {
+		if err.Err != nil {
+			errs = append(errs, err.Err)
+		}
+	}
+	return errs
+}
+
+// Eval runs src, giving the value of its last statement. A program that
+// fails while running gives an *object.Error, which has the position of
+// the expression that failed and wraps the error for a limit or ctx
+func (vm *VM) Eval(ctx context.Context, src string) (Value, error) {
+	p := parser.New(lexer.New(src))
+	p.SetMaxDepth(vm.maxDepth)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return Value{}, &SyntaxError{Errors: p.ErrorList()}
+	}
+
+	defer vm.lock(ctx)()
+	return vm.run(ctx, func() object.Object {
+		return vm.in.EvalContext(ctx, program, vm.env)
+	})
+}
+
+// Call calls the function called name with args, which are converted the
+// same way as the values given to Set
+func (vm *VM) Call(ctx context.Context, name string, args ...any) (Value, error) {
+	defer vm.lock(ctx)()
+
+	fn, ok := vm.lookup(name)
+	if !ok {
+		return Value{}, fmt.Errorf("%s is not defined", name)
+	}
+	objs := []object.Object{}
+	for i, arg := range args {
+		obj, err := toObject(arg)
+		if err != nil {
+			return Value{}, fmt.Errorf("argument %d to %s: %w", i+1, name, err)
+		}
+		objs = append(objs, obj)
+	}
+
+	return vm.run(ctx, func() object.Object {
+		return vm.in.CallContext(ctx, fn, objs...)
+	})
+}
+
+// lock waits for the VM to be free to run a program, giving what lets it
+// go again. A registered function calling back into the VM with the
+// context it was given doesn't wait, as the program it would wait for is
+// the one waiting for it
+func (vm *VM) lock(ctx context.Context) (unlock func()) {
+	if hc, ok := ctx.Value(hostCallKey{}).(*hostCall); ok && hc.vm == vm && !hc.returned.Load() {
+		vm.mu.Lock()
+		return vm.mu.Unlock
+	}
+	vm.busy.Lock()
+	vm.mu.Lock()
+	return func() {
+		vm.mu.Unlock()
+		vm.busy.Unlock()
+	}
+}
+
+// run calls eval with ctx as the context for the functions it calls
+func (vm *VM) run(ctx context.Context, eval func() object.Object) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return Value{}, err
+	}
+	outer := vm.ctx
+	vm.ctx = ctx
+	defer func() { vm.ctx = outer }()
+
+	result := eval()
+	if err, ok := result.(*object.Error); ok {
+		return Value{}, err
+	}
+	return Value{obj: result}, nil
+}
+
+// Set makes a variable called name for the programs the VM runs. value can
+// be nil, a bool, any kind of integer or float, a string, a slice or array
+// of these, a map with bool, integer or string keys, or a Value
+func (vm *VM) Set(name string, value any) error {
+	obj, err := toObject(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.env.Set(name, obj)
+	return nil
+}
+
+// Get gives the variable called name, false if there isn't one
+func (vm *VM) Get(name string) (Value, bool) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	obj, ok := vm.lookup(name)
+	return Value{obj: obj}, ok
+}
+
+func (vm *VM) lookup(name string) (object.Object, bool) {
+	if obj, ok := vm.env.Get(name); ok {
+		return obj, true
+	}
+	if builtin, ok := vm.in.Builtins[name]; ok {
+		return builtin, true
+	}
+	return nil, false
+}
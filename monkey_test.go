@@ -0,0 +1,359 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"monkey/object"
+)
+
+func eval(t *testing.T, vm *VM, src string) Value {
+	t.Helper()
+	v, err := vm.Eval(context.Background(), src)
+	if err != nil {
+		t.Fatalf("%q: %v", src, err)
+	}
+	return v
+}
+
+func TestEval(t *testing.T) {
+	vm := New(Options{})
+
+	if v := eval(t, vm, "let x = 2; x * 3"); v.Interface() != int64(6) || v.Type() != "INTEGER" {
+		t.Errorf("expected 6. got=%s (%s)", v, v.Type())
+	}
+	// lets last from one Eval to the next
+	if v := eval(t, vm, "x"); v.Interface() != int64(2) {
+		t.Errorf("expected 2. got=%s", v)
+	}
+	if v := eval(t, vm, "let y = 1;"); v.Interface() != nil || v.Type() != "NULL" {
+		t.Errorf("expected null. got=%s", v)
+	}
+
+	var out strings.Builder
+	vm = New(Options{Stdout: &out})
+	eval(t, vm, `puts("hi")`)
+	if out.String() != "hi\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	tests := []struct {
+		value   any
+		inspect string
+		back    any
+	}{
+		{nil, "null", nil},
+		{true, "true", true},
+		{7, "7", int64(7)},
+		{uint8(255), "255", int64(255)},
+		{1.5, "1.5", 1.5},
+		{float32(-2), "-2.0", -2.0},
+		{"héllo", "héllo", "héllo"},
+		{[]int{1, 2}, "[1, 2]", []any{int64(1), int64(2)}},
+		{[2]string{"a", "b"}, `["a", "b"]`, []any{"a", "b"}},
+		{[]any{1, "a", nil, []bool{true}}, `[1, "a", null, [true]]`, []any{int64(1), "a", nil, []any{true}}},
+		{map[string]int{"b": 2, "a": 1}, `{"a": 1, "b": 2}`, map[string]any{"a": int64(1), "b": int64(2)}},
+		{map[int]string{2: "b", 1: "a"}, `{1: "a", 2: "b"}`, map[any]any{int64(1): "a", int64(2): "b"}},
+		{map[string]any{}, "{}", map[string]any{}},
+		{new(int), "0", int64(0)},
+		{(*int)(nil), "null", nil},
+	}
+
+	for _, tt := range tests {
+		vm := New(Options{})
+		if err := vm.Set("v", tt.value); err != nil {
+			t.Errorf("%#v: %v", tt.value, err)
+			continue
+		}
+		v, ok := vm.Get("v")
+		if !ok {
+			t.Fatalf("%#v: v isn't there", tt.value)
+		}
+		if v.String() != tt.inspect {
+			t.Errorf("%#v: expected %s. got=%s", tt.value, tt.inspect, v)
+		}
+		if !reflect.DeepEqual(v.Interface(), tt.back) {
+			t.Errorf("%#v: expected %#v back. got=%#v", tt.value, tt.back, v.Interface())
+		}
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{1i, "v: can't convert complex128 to a Monkey value"},
+		{uint64(1 << 63), "v: 9223372036854775808 is too big for an integer"},
+		{[]any{1, struct{}{}}, "v: element 1: can't convert struct {} to a Monkey value"},
+		{map[string]any{"f": func() {}}, `v: value for f: can't convert func() to a Monkey value`},
+		{map[[2]int]int{{1, 2}: 3}, "v: can't use [2]int as a hash key"},
+	}
+
+	for _, tt := range tests {
+		err := New(Options{}).Set("v", tt.value)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%#v: expected error %q. got=%v", tt.value, tt.expected, err)
+		}
+	}
+}
+
+func TestSetCycles(t *testing.T) {
+	m := map[string]any{}
+	m["self"] = m
+	s := []any{1, nil}
+	s[1] = s
+	var p any
+	p = &p
+
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{"map", m, "v: value for self: can't convert a map[string]interface {} that contains itself"},
+		{"slice", s, "v: element 1: can't convert a []interface {} that contains itself"},
+		{"pointer", p, "v: can't convert a *interface {} that contains itself"},
+	}
+
+	for _, tt := range tests {
+		err := New(Options{}).Set("v", tt.value)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q. got=%v", tt.name, tt.expected, err)
+		}
+	}
+
+	// the same value twice is fine as long as it isn't inside itself
+	shared := []int{1}
+	vm := New(Options{})
+	if err := vm.Set("v", [][]int{shared, shared}); err != nil {
+		t.Errorf("shared slice: %v", err)
+	}
+}
+
+func TestHashes(t *testing.T) {
+	vm := New(Options{})
+	vm.Set("h", map[any]any{"a": 1, 2: "b", true: []int{3}})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`h["a"]`, "1"},
+		{"h[2]", "b"},
+		{"h[true][0]", "3"},
+		{`h["missing"]`, "null"},
+		{"len(h)", "3"},
+	}
+	for _, tt := range tests {
+		if v := eval(t, vm, tt.input); v.String() != tt.expected {
+			t.Errorf("%q: expected %s. got=%s", tt.input, tt.expected, v)
+		}
+	}
+
+	_, err := vm.Eval(context.Background(), "h[[1]]")
+	if err == nil || err.Error() != "1:1: unusable as hash key: ARRAY" {
+		t.Errorf("wrong error for an array key. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	vm := New(Options{})
+	eval(t, vm, "let add = fn(a, b) { a + b }; let greet = fn(name) { \"hi ${name}\" }")
+	ctx := context.Background()
+
+	if v, err := vm.Call(ctx, "add", 1, 2); err != nil || v.Interface() != int64(3) {
+		t.Errorf("expected 3. got=%v, %v", v, err)
+	}
+	if v, err := vm.Call(ctx, "greet", "bob"); err != nil || v.Interface() != "hi bob" {
+		t.Errorf(`expected "hi bob". got=%v, %v`, v, err)
+	}
+	if v, err := vm.Call(ctx, "len", []int{1, 2, 3}); err != nil || v.Interface() != int64(3) {
+		t.Errorf("expected 3 from a builtin. got=%v, %v", v, err)
+	}
+
+	// functions can be given back as values
+	add, _ := vm.Get("add")
+	if _, ok := add.Interface().(Value); !ok || add.Type() != "FUNCTION" {
+		t.Errorf("expected a function to stay a Value. got=%#v", add.Interface())
+	}
+	vm.Set("plus", add)
+	if v, err := vm.Eval(ctx, "plus(2, 2)"); err != nil || v.Interface() != int64(4) {
+		t.Errorf("expected 4. got=%v, %v", v, err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []any
+		expected string
+	}{
+		{"missing", nil, "missing is not defined"},
+		{"add", []any{1}, "wrong number of arguments: want=2, got=1"},
+		{"add", []any{1, true}, "1:22: type mismatch: INTEGER + BOOLEAN"},
+		{"add", []any{1, 2i}, "argument 2 to add: can't convert complex128 to a Monkey value"},
+	}
+
+	for _, tt := range tests {
+		_, err := vm.Call(ctx, tt.name, tt.args...)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s%v: expected error %q. got=%v", tt.name, tt.args, tt.expected, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	vm := New(Options{})
+	ctx := context.Background()
+
+	_, err := vm.Eval(ctx, "let x = ;\nlet = 1;")
+	var syntax *SyntaxError
+	if !errors.As(err, &syntax) || len(syntax.Errors) == 0 ||
+		!strings.HasPrefix(err.Error(), "1:9: no prefix parse function for ; found") {
+		t.Errorf("expected a syntax error. got=%v", err)
+	}
+
+	_, err = vm.Eval(ctx, "let a = 1;\na + true")
+	var runtime *object.Error
+	if !errors.As(err, &runtime) || runtime.Pos.Line != 2 || runtime.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected a runtime error on line 2. got=%v", err)
+	}
+}
+
+// cancelWriter cancels the context once something is written
+type cancelWriter struct {
+	cancel context.CancelFunc
+	writes int
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.writes++
+	w.cancel()
+	return len(p), nil
+}
+
+func TestCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancelWriter{cancel: cancel}
+	vm := New(Options{Stdout: w})
+
+	src := "let count = fn(n) { puts(n); if (n > 0) { count(n - 1) } }; count(10)"
+	_, err := vm.Eval(ctx, src)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the program to be cancelled. got=%v", err)
+	}
+	if w.writes != 1 {
+		t.Errorf("expected the program to stop after the first puts. got=%d", w.writes)
+	}
+
+	if _, err := vm.Eval(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected nothing to run once cancelled. got=%v", err)
+	}
+	// the VM can still be used with another context
+	if v := eval(t, vm, "count"); v.Type() != "FUNCTION" {
+		t.Errorf("expected count to be kept. got=%s", v)
+	}
+}
+
+func TestConcurrentVMs(t *testing.T) {
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vm := New(Options{})
+			vm.Set("n", i)
+			v, err := vm.Eval(context.Background(), "let sum = fn(n) { if (n < 1) { 0 } else { n + sum(n - 1) } }; sum(n * 10)")
+			if err != nil {
+				results[i] = err.Error()
+				return
+			}
+			results[i] = v.String()
+		}()
+	}
+	wg.Wait()
+
+	for i, got := range results {
+		n := i * 10
+		if expected := fmt.Sprint(n * (n + 1) / 2); got != expected {
+			t.Errorf("vm %d: expected %s. got=%s", i, expected, got)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	forever := "let f = fn(n) { f(n + 1) }; f(0)"
+
+	vm := New(Options{MaxSteps: 1000})
+	_, err := vm.Eval(ctx, forever)
+	var steps *StepLimitError
+	if !errors.As(err, &steps) || steps.Limit != 1000 {
+		t.Errorf("expected a step limit error. got=%v", err)
+	}
+
+	vm = New(Options{MaxDepth: 20})
+	_, err = vm.Eval(ctx, forever)
+	var depth *DepthLimitError
+	if !errors.As(err, &depth) || depth.Limit != 20 {
+		t.Errorf("expected a depth limit error. got=%v", err)
+	}
+	_, err = vm.Eval(ctx, strings.Repeat("(", 30)+"1"+strings.Repeat(")", 30))
+	var nesting *NestingLimitError
+	if !errors.As(err, &nesting) || nesting.Limit != 20 || err.Error() != "1:21: expressions nested more than 20 deep" {
+		t.Errorf("expected a nesting limit error. got=%v", err)
+	}
+	// and so do types
+	_, err = vm.Eval(ctx, "let x: "+strings.Repeat("[", 100000)+"int"+strings.Repeat("]", 100000)+" = 1;")
+	if !errors.As(err, &nesting) {
+		t.Errorf("expected a nesting limit error for a type. got=%v", err)
+	}
+	// calls from Go count too
+	if _, err := vm.Call(ctx, "f", 0); !errors.As(err, &depth) {
+		t.Errorf("expected a depth limit error from Call. got=%v", err)
+	}
+
+	vm = New(Options{MaxAlloc: 1 << 10})
+	_, err = vm.Eval(ctx, `let f = fn(s) { f(s + s) }; f("ab")`)
+	var alloc *AllocLimitError
+	if !errors.As(err, &alloc) || alloc.Limit != 1<<10 {
+		t.Errorf("expected an allocation limit error. got=%v", err)
+	}
+	// a builtin's result counts
+	vm.RegisterFunc("big", func() []int { return make([]int, 1<<10) })
+	if _, err = vm.Eval(ctx, "big()"); !errors.As(err, &alloc) || err.Error() != "1:1: allocation limit of 1024 bytes exceeded" {
+		t.Errorf("expected an allocation limit error from a builtin. got=%v", err)
+	}
+	// and so does what is inside it
+	vm.RegisterFunc("wrapped", func() []string { return []string{strings.Repeat("a", 1<<10)} })
+	if _, err = vm.Eval(ctx, "wrapped()"); !errors.As(err, &alloc) || err.Error() != "1:1: allocation limit of 1024 bytes exceeded" {
+		t.Errorf("expected an allocation limit error from inside a builtin's result. got=%v", err)
+	}
+	vm.RegisterFunc("same", func(v Value) Value { return v })
+	if v, err := vm.Eval(ctx, `let s = "abcdefgh"; same([s, s, s])`); err != nil || v.String() != `["abcdefgh", "abcdefgh", "abcdefgh"]` {
+		t.Errorf("expected values from the program not to be counted again. got=%v, %v", v, err)
+	}
+}
+
+func TestIO(t *testing.T) {
+	ctx := context.Background()
+
+	// each VM only sees the IO it was given
+	files := NewMemoryIO(map[string]string{"in.json": `{"n": 2}`}, "")
+	vm := New(Options{IO: files})
+	eval(t, vm, `let n = json_parse(read_file("in.json"))["n"]; write_file("out.txt", "${n * 2}")`)
+	if got := files.Files()["out.txt"]; got != "4" {
+		t.Errorf("expected out.txt to be 4. got=%q", got)
+	}
+
+	if _, err := New(Options{}).Eval(ctx, `read_file("in.json")`); !errors.Is(err, ErrNoIO) {
+		t.Errorf("expected ErrNoIO. got=%v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package object
+
+import "sort"
+
+// Environment holds the values of the names in one scope, and points to
+// the scope around it
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: map[string]Object{}}
+}
+
+// NewEnclosedEnvironment makes a scope inside outer, such as the body of a
+// function or a branch of an if
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get looks name up in this scope and then the ones around it
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set gives name a value in this scope
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Outer is the scope around this one, nil for the outermost
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names gives the names set in this scope, not the ones around it, sorted
+func (e *Environment) Names() []string {
+	names := []string{}
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,237 @@
+package object
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"monkey/ast"
+	"monkey/token"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	STRING_OBJ       = "STRING"
+	NULL_OBJ         = "NULL"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+)
+
+// Object is a value made while running a program
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) HashKey() HashKey { return HashKey{Type: i.Type(), Int: i.Value} }
+
+// Float is a floating point number. Programs can't write them yet, they
+// come from builtins like json_parse and from Go
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect always gives a point or an exponent, so 2.0 doesn't look like the
+// integer 2
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+func (b *Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: b.Type(), Int: 1}
+	}
+	return HashKey{Type: b.Type()}
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+func (s *String) HashKey() HashKey { return HashKey{Type: s.Type(), Str: s.Value} }
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// ReturnValue wraps the value of a return statement while it makes its way
+// out of the blocks it is in
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error stops the program, it makes its way out the same way a return does
+type Error struct {
+	Message string
+	// Pos is where in the source the error happened, if it is known
+	Pos token.Position
+	// Err is set when the error came from outside the program, like a hook
+	// that stopped it
+	Err error
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+// Unwrap lets errors.Is and errors.As look at Err
+func (e *Error) Unwrap() error { return e.Err }
+
+// Error makes an *Error usable as a Go error, with its position in front
+// of the message when it has one
+func (e *Error) Error() string {
+	if e.Pos.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Message)
+}
+
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+	// Literal is the function literal the function was made from
+	Literal *ast.FunctionLiteral
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") {\n" + f.Body.String() + "\n}"
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, InspectElement(e))
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// InspectElement gives obj the way it is shown inside an array or hash,
+// which quotes strings so ["a, b"] can be told apart from ["a", "b"]
+func InspectElement(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return obj.Inspect()
+}
+
+// HashKey is what a hash looks keys up by, two keys that are equal have the
+// same HashKey
+type HashKey struct {
+	Type ObjectType
+	Int  int64
+	Str  string
+}
+
+// Hashable is an object that can be used as a key in a hash
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Hashable
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]HashPair{}}
+}
+
+// Set adds a pair, replacing the pair with the same key if there is one
+func (h *Hash) Set(key Hashable, value Object) {
+	h.Pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Sorted gives the pairs ordered by their keys, so hashes always come out
+// the same way
+func (h *Hash) Sorted() []HashPair {
+	pairs := []HashPair{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+	slices.SortFunc(pairs, func(a, b HashPair) int {
+		ka, kb := a.Key.HashKey(), b.Key.HashKey()
+		return cmp.Or(cmp.Compare(ka.Type, kb.Type), cmp.Compare(ka.Int, kb.Int), cmp.Compare(ka.Str, kb.Str))
+	})
+	return pairs
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	pairs := []string{}
+	for _, pair := range h.Sorted() {
+		pairs = append(pairs, InspectElement(pair.Key)+": "+InspectElement(pair.Value))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,272 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+// Document is a parsed source file that can be kept up to date as an editor
+// sends small edits, without lexing and parsing the whole file every time
+type Document struct {
+	Source  string
+	Program *ast.Program
+
+	stmts  []parsedStatement
+	config DocumentConfig
+}
+
+// DocumentConfig is how a document is lexed and parsed, every time part of
+// it is parsed again
+type DocumentConfig struct {
+	Lexer lexer.Config
+	// MaxDepth is given to SetMaxDepth
+	MaxDepth int
+}
+
+// parsedStatement remembers which part of the source a top level statement
+// was parsed from, including statements that failed to parse
+type parsedStatement struct {
+	stmt   ast.Statement // nil if the statement had errors
+	errors []Error
+
+	start token.Position // start of the first token of the statement
+	// false if the statement starts inside a ${ } in a string, which can
+	// happen when the parser gives up half way through a string
+	clean bool
+	end   token.Position // end of the last token of the statement
+	// the parser always reads one token further than it uses, and the lexer
+	// looks a few characters past that to find where the token ends, so the
+	// statement depends on everything before peekEnd + lexer.Lookahead
+	peekEnd int
+}
+
+// Edit replaces the source between the byte offsets Start and End with Text
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+func ParseDocument(src string) *Document {
+	return ParseDocumentWithConfig(src, DocumentConfig{})
+}
+
+// ParseDocumentWithConfig parses a document with config, which is used
+// again for the parts Apply parses
+func ParseDocumentWithConfig(src string, config DocumentConfig) *Document {
+	d := &Document{Source: src, config: config}
+	d.stmts = parseStatements(d.parser(src, token.Position{Offset: 0, Line: 1, Column: 1}), nil)
+	d.buildProgram()
+	return d
+}
+
+// Errors returns the parser errors for the whole document, in the same order
+// ParseProgram would give them
+func (d *Document) Errors() []string {
+	return errorMessages(d.ErrorList())
+}
+
+// ErrorList returns the same errors as Errors, with their positions
+func (d *Document) ErrorList() []Error {
+	errors := []Error{}
+	for _, ps := range d.stmts {
+		errors = append(errors, ps.errors...)
+	}
+	return errors
+}
+
+// Apply returns the document with the edit made to it. Only the statements
+// touched by the edit are lexed and parsed again, the statements before it
+// are shared with d and the statements after it are copied and moved, so d
+// is left as it was
+func (d *Document) Apply(e Edit) *Document {
+	src := d.Source[:e.Start] + e.Text + d.Source[e.End:]
+	delta := len(e.Text) - (e.End - e.Start)
+
+	// statements which finished reading before the edit starts are unchanged
+	keep := 0
+	for keep < len(d.stmts) && d.stmts[keep].peekEnd+lexer.Lookahead <= e.Start {
+		keep++
+	}
+	// the lexer has to start again somewhere outside of a string
+	for keep > 0 && keep < len(d.stmts) && !d.stmts[keep].clean {
+		keep--
+	}
+	// once a statement is nested too deeply the parser gives up on the rest
+	// of the source, so what comes after it has to be parsed with it
+	for i := range keep {
+		if tooDeep(d.stmts[i].errors) {
+			keep = i
+			break
+		}
+	}
+
+	start := token.Position{Offset: 0, Line: 1, Column: 1}
+	if keep > 0 {
+		start = d.stmts[keep-1].end
+	}
+
+	// any statement that started after the edit in the old source can be
+	// reused once the new parse reaches the same place, unless it was
+	// parsed after the parser gave up
+	old := map[int]int{}
+	for i := keep; i < len(d.stmts); i++ {
+		if d.stmts[i].start.Offset >= e.End && d.stmts[i].clean {
+			old[d.stmts[i].start.Offset+delta] = i
+		}
+		if tooDeep(d.stmts[i].errors) {
+			break
+		}
+	}
+
+	n := &Document{Source: src, stmts: []parsedStatement{}, config: d.config}
+	n.stmts = append(n.stmts, d.stmts[:keep]...)
+
+	var tail []parsedStatement
+	p := d.parser(src, start)
+	resync := func(offset int, clean bool) bool {
+		i, ok := old[offset]
+		if !ok || !clean || p.tooDeep {
+			return false
+		}
+		lines := newLineTable(src)
+		for _, ps := range d.stmts[i:] {
+			ps.start = lines.position(ps.start.Offset + delta)
+			ps.end = lines.position(ps.end.Offset + delta)
+			ps.peekEnd += delta
+			ps.errors = moveErrors(ps.errors, delta, lines)
+			// d still has the statement, so it is moved in a copy
+			if ps.stmt != nil {
+				ps.stmt = ast.Clone(ps.stmt)
+				moveTokens(reflect.ValueOf(ps.stmt), delta, lines)
+			}
+			tail = append(tail, ps)
+		}
+		return true
+	}
+	n.stmts = append(n.stmts, parseStatements(p, resync)...)
+	n.stmts = append(n.stmts, tail...)
+
+	n.buildProgram()
+	return n
+}
+
+// parser gives a parser for src made with the document's config, which
+// starts at start
+func (d *Document) parser(src string, start token.Position) *Parser {
+	p := New(lexer.NewAt(src, start, d.config.Lexer))
+	p.SetMaxDepth(d.config.MaxDepth)
+	return p
+}
+
+func tooDeep(errs []Error) bool {
+	for _, e := range errs {
+		var nesting *NestingLimitError
+		if errors.As(e.Err, &nesting) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatements parses top level statements the same way ParseProgram
+// does, stopping early if resync says the rest of the input is already known
+func parseStatements(p *Parser, resync func(offset int, clean bool) bool) []parsedStatement {
+	stmts := []parsedStatement{}
+
+	for p.curToken.Type != token.EOF {
+		clean := !p.curInInterpolation
+		if resync != nil && resync(p.curToken.Pos.Offset, clean) {
+			return stmts
+		}
+
+		errorCount := len(p.errors)
+		ps := parsedStatement{start: p.curToken.Pos, clean: clean}
+		if stmt := p.parseStatement(); stmt != nil {
+			ps.stmt = stmt
+		}
+		ps.end = p.curToken.End
+		ps.peekEnd = p.peekToken.End.Offset
+		ps.errors = p.errors[errorCount:]
+
+		stmts = append(stmts, ps)
+		p.NextToken()
+	}
+
+	return stmts
+}
+
+func (d *Document) buildProgram() {
+	d.Program = &ast.Program{Statements: []ast.Statement{}}
+	for _, ps := range d.stmts {
+		if ps.stmt != nil {
+			d.Program.Statements = append(d.Program.Statements, ps.stmt)
+		}
+	}
+}
+
+func moveErrors(errors []Error, delta int, lines lineTable) []Error {
+	moved := make([]Error, len(errors))
+	for i, e := range errors {
+		e.Pos = lines.position(e.Pos.Offset + delta)
+		e.End = lines.position(e.End.Offset + delta)
+		moved[i] = e
+	}
+	return moved
+}
+
+// moveTokens shifts the position of every token in a node by delta. It uses
+// reflection so that it doesn't need updating whenever a node type is added
+func moveTokens(v reflect.Value, delta int, lines lineTable) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if !v.IsNil() {
+			moveTokens(v.Elem(), delta, lines)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			moveTokens(v.Index(i), delta, lines)
+		}
+	case reflect.Struct:
+		if !v.CanAddr() {
+			return
+		}
+		if tok, ok := v.Addr().Interface().(*token.Token); ok {
+			// the closing brace of a block that wasn't closed is never
+			// read, so it has no position to move
+			if tok.Type == "" {
+				return
+			}
+			tok.Pos = lines.position(tok.Pos.Offset + delta)
+			tok.End = lines.position(tok.End.Offset + delta)
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			moveTokens(v.Field(i), delta, lines)
+		}
+	}
+}
+
+// lineTable holds the offset each line of a source starts at, so positions
+// can be worked out from byte offsets
+type lineTable []int
+
+func newLineTable(src string) lineTable {
+	lines := lineTable{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+func (lt lineTable) position(offset int) token.Position {
+	line := sort.Search(len(lt), func(i int) bool { return lt[i] > offset }) - 1
+	return token.Position{Offset: offset, Line: line + 1, Column: offset - lt[line] + 1}
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+)
+
+func TestDocumentMatchesParseProgram(t *testing.T) {
+	input := "let x = 5;\nreturn x;\na + b * c\n-5 == !y; let = 1"
+
+	d := ParseDocument(input)
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if !reflect.DeepEqual(d.Program, program) {
+		t.Errorf("document program wrong. expected=%q, got=%q", program.String(), d.Program.String())
+	}
+	if !reflect.DeepEqual(d.ErrorList(), p.ErrorList()) {
+		t.Errorf("document errors wrong. expected=%v, got=%v", p.ErrorList(), d.ErrorList())
+	}
+}
+
+func TestDocumentReusesStatements(t *testing.T) {
+	input := "let x = 5;\nlet y = 10;\nlet z = 15;\n"
+	d := ParseDocument(input)
+	first := d.Program.Statements[0]
+	last := d.Program.Statements[2]
+
+	// change 10 to 100
+	n := d.Apply(Edit{Start: 19, End: 21, Text: "100"})
+
+	if n.Program.Statements[0] != first {
+		t.Errorf("statement before the edit was not reused")
+	}
+	// the statement after the edit is moved in a copy, leaving d as it was
+	moved := n.Program.Statements[2].(*ast.LetStatement)
+	if moved == last || !ast.Equal(moved, last, ast.EqualOptions{IgnorePositions: true}) {
+		t.Errorf("statement after the edit was not copied")
+	}
+	if last.(*ast.LetStatement).Token.Pos.Offset != 23 || moved.Token.Pos.Offset != 24 {
+		t.Errorf("expected the statement to move from 23 to 24. got=%d and %d",
+			last.(*ast.LetStatement).Token.Pos.Offset, moved.Token.Pos.Offset)
+	}
+
+	full := ParseDocument(n.Source)
+	if !reflect.DeepEqual(n.Program, full.Program) {
+		t.Errorf("edited program differs from a full parse")
+	}
+}
+
+func TestDocumentWithDialect(t *testing.T) {
+	dialect := token.NewDialect()
+	dialect.AddKeyword("var", token.LET)
+	config := DocumentConfig{Lexer: lexer.Config{Dialect: dialect}}
+
+	d := ParseDocumentWithConfig("var x = 5;\nvar y = 10;\n", config)
+	// change 10 to 100, which lexes the second statement again
+	n := d.Apply(Edit{Start: 19, End: 21, Text: "100"})
+
+	full := ParseDocumentWithConfig(n.Source, config)
+	if !reflect.DeepEqual(n.Program, full.Program) {
+		t.Errorf("edited program differs from a full parse. expected=%q, got=%q", full.Program.String(), n.Program.String())
+	}
+	if len(n.Errors()) != 0 {
+		t.Errorf("expected no errors. got=%q", n.Errors())
+	}
+	if _, ok := n.Program.Statements[1].(*ast.LetStatement); !ok {
+		t.Errorf("expected var to still be a let. got=%T", n.Program.Statements[1])
+	}
+}
+
+func TestDocumentMaxDepth(t *testing.T) {
+	config := DocumentConfig{MaxDepth: 3}
+	input := "let a = 1;\nlet b = ((((1))));\nlet c = 2;\n"
+	edits := []Edit{
+		{Start: 8, End: 9, Text: "10"},    // before the statement nested too deeply
+		{Start: 19, End: 28, Text: "(1)"}, // making it shallow enough
+		{Start: 38, End: 39, Text: "3"},   // after it
+	}
+
+	var nesting *NestingLimitError
+	if errs := ParseDocumentWithConfig(input, config).ErrorList(); len(errs) == 0 || !errors.As(errs[0].Err, &nesting) {
+		t.Fatalf("expected a nesting limit error. got=%v", errs)
+	}
+
+	for _, e := range edits {
+		n := ParseDocumentWithConfig(input, config).Apply(e)
+
+		p := New(lexer.New(n.Source))
+		p.SetMaxDepth(3)
+		program := p.ParseProgram()
+		if !reflect.DeepEqual(n.Program, program) {
+			t.Errorf("edit %+v: program differs from a full parse. expected=%q, got=%q", e, program.String(), n.Program.String())
+		}
+		if !reflect.DeepEqual(n.ErrorList(), p.ErrorList()) {
+			t.Errorf("edit %+v: errors differ from a full parse. expected=%v, got=%v", e, p.ErrorList(), n.ErrorList())
+		}
+	}
+}
+
+func TestMoveErrorsKeepsErr(t *testing.T) {
+	src := "\nlet x = 1;"
+	err := &NestingLimitError{Limit: 3}
+	errs := []Error{{Pos: token.Position{Offset: 0, Line: 1, Column: 1}, Message: err.Error(), Err: err}}
+
+	moved := moveErrors(errs, 1, newLineTable(src))
+	var nesting *NestingLimitError
+	if !errors.As(moved[0].Err, &nesting) || nesting.Limit != 3 {
+		t.Errorf("expected the moved error to keep Err. got=%v", moved[0].Err)
+	}
+	if moved[0].Pos.Line != 2 || moved[0].Pos.Column != 1 {
+		t.Errorf("expected the error to move to 2:1. got=%d:%d", moved[0].Pos.Line, moved[0].Pos.Column)
+	}
+}
+
+// the fragments are chosen to join up with each other in awkward ways, like
+// identifiers running together or = turning into ==
+var fragments = []string{
+	"let", "return", "x", "y", "foo", "5", "10", "=", "==", "!", "!=", "+", "-",
+	"*", "/", "<", ">", ";", "(", ")", "{", "}", ",", " ", " ", "\n", "\t",
+	"// note\n", "@", "\"", "`", "<<~A\n", "A",
+	"${", "\"a ${", "} b\"",
+	"fn", "if", "else", "true", "false", "fn(a, b) {", "if (x) {", "} else {",
+	"[", "]", "[1, 2]",
+}
+
+func randomSource(r *rand.Rand, n int) string {
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		out.WriteString(fragments[r.Intn(len(fragments))])
+	}
+	return out.String()
+}
+
+func TestDocumentApplyProperty(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		config := DocumentConfig{MaxDepth: 4 * r.Intn(2)}
+		d := ParseDocumentWithConfig(randomSource(r, r.Intn(40)), config)
+
+		// a few edits in a row to check documents built by Apply can be edited
+		for j := 0; j < 3; j++ {
+			start := r.Intn(len(d.Source) + 1)
+			end := start + r.Intn(len(d.Source)-start+1)
+			e := Edit{Start: start, End: end, Text: randomSource(r, r.Intn(4))}
+
+			before := d.Source
+			old := d
+			d = d.Apply(e)
+			full := ParseDocumentWithConfig(d.Source, config)
+
+			if !reflect.DeepEqual(old.Program, ParseDocumentWithConfig(before, config).Program) {
+				t.Fatalf("edit %+v of %q changed the old document", e, before)
+			}
+
+			if !reflect.DeepEqual(d.Program, full.Program) {
+				// String can't be used, as a program given up on for being
+				// nested too deeply has nodes missing
+				t.Fatalf("program differs after edit %+v of %q", e, before)
+			}
+			if !reflect.DeepEqual(d.Errors(), full.Errors()) {
+				t.Fatalf("errors differ after edit %+v of %q.\nexpected=%q\ngot=%q",
+					e, before, full.Errors(), d.Errors())
+			}
+			if !reflect.DeepEqual(d.stmts, full.stmts) {
+				t.Fatalf("statement spans differ after edit %+v of %q", e, before)
+			}
+		}
+	}
+}
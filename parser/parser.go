@@ -20,20 +20,40 @@ const (
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []Error
 
 	curToken  token.Token
 	peekToken token.Token
 
+	// whether the lexer was inside a ${ } when it read each token, the
+	// Document needs this to know where it is safe to start lexing again
+	curInInterpolation  bool
+	peekInInterpolation bool
+
 	// these will store a map of tokenTypes to parsing functions
 	// means that we might have to parse the same token differently depending on
 	// whether it appears prefix or infix in our statements
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// maxDepth bounds how deeply parseExpression and parseType can
+	// recurse, 0 for no limit. Once it is hit the parser gives up on the
+	// rest of the input
+	maxDepth int
+	depth    int
+	tooDeep  bool
+}
+
+// SetMaxDepth limits how deeply expressions and types can be nested, so
+// input can't make the parser recurse until the stack runs out. 0 means
+// no limit
+func (p *Parser) SetMaxDepth(n int) {
+	p.maxDepth = n
 }
 
 // we can create types in Go, as shown below
@@ -60,7 +80,7 @@ func New(l *lexer.Lexer) *Parser {
 	// want to check what the below is doing
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []Error{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -68,6 +88,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
 	p.registerPrefix(token.EXCLAM, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.RAW_STRING, p.parseStringLiteral)
+	p.registerPrefix(token.HEREDOC, p.parseStringLiteral)
+	p.registerPrefix(token.INTERP_START, p.parseInterpolatedString)
+	p.registerPrefix(token.LBRACKET, p.parseGroupedExpression)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LSQUARE, p.parseArrayLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -78,6 +108,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LBRACKET, p.parseCallExpression)
+	p.registerInfix(token.LSQUARE, p.parseIndexExpression)
 
 	// Read two tokens, so curToken and peekToken are set
 	p.NextToken()
@@ -106,15 +138,60 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// Error is a parser error along with the token the parser was looking at
+// when it found it
+type Error struct {
+	Pos     token.Position
+	End     token.Position
+	Message string
+	// Err is set for errors callers might want to tell apart, like
+	// *NestingLimitError
+	Err error
+}
+
+// NestingLimitError is the error for expressions or types nested more
+// deeply than the limit given to SetMaxDepth
+type NestingLimitError struct {
+	Limit int
+}
+
+func (e *NestingLimitError) Error() string {
+	return fmt.Sprintf("expressions nested more than %d deep", e.Limit)
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Message)
+}
+
 func (p *Parser) Errors() []string {
+	return errorMessages(p.errors)
+}
+
+// ErrorList returns the same errors as Errors, with their positions
+func (p *Parser) ErrorList() []Error {
 	return p.errors
 }
 
+func errorMessages(errors []Error) []string {
+	messages := []string{}
+	for _, e := range errors {
+		messages = append(messages, e.Message)
+	}
+	return messages
+}
+
+// errorAt adds an error about tok. After nesting too deeply every error is
+// down to the parser giving up, so they are left out
+func (p *Parser) errorAt(tok token.Token, format string, args ...any) {
+	if p.tooDeep {
+		return
+	}
+	p.errors = append(p.errors, Error{Pos: tok.Pos, End: tok.End, Message: fmt.Sprintf(format, args...)})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
 	// do we now want to add it to the errors array?
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 // on first call, curToken is empty and peek token is set to the first
@@ -123,6 +200,8 @@ func (p *Parser) peekError(t token.TokenType) {
 // and peekToken is set to the next token in the list of tokens
 func (p *Parser) NextToken() {
 	p.curToken = p.peekToken
+	p.curInInterpolation = p.peekInInterpolation
+	p.peekInInterpolation = p.l.InInterpolation()
 	p.peekToken = p.l.NextToken()
 }
 
@@ -165,7 +244,9 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 
 	p.NextToken()
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.NextToken()
 	}
 	return stmt
@@ -187,6 +268,14 @@ func (p *Parser) parseLetStatement() ast.Statement {
 	// might want to add another unit test
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.NextToken()
+		p.NextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
+	}
+
 	// could alter this because no point doing the assignment above when the below fails
 	// but can't move this above as expectPeek increments to the next tokem
 	// which doesn't seem clear from the name of the method
@@ -194,9 +283,11 @@ func (p *Parser) parseLetStatement() ast.Statement {
 		return nil
 	}
 
-	// TODO: We're skipping the expressions until we
-	// encounter a semicolon
-	for !p.curTokenIs(token.SEMICOLON) {
+	p.NextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.NextToken()
 	}
 
@@ -238,9 +329,34 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
+// enter goes one level deeper into an expression or type, failing with a
+// *NestingLimitError when that is deeper than the limit given to
+// SetMaxDepth. Each enter that succeeds needs a leave
+func (p *Parser) enter() bool {
+	if p.tooDeep {
+		return false
+	}
+	if p.maxDepth > 0 && p.depth >= p.maxDepth {
+		err := &NestingLimitError{Limit: p.maxDepth}
+		p.errors = append(p.errors, Error{Pos: p.curToken.Pos, End: p.curToken.End, Message: err.Error(), Err: err})
+		p.tooDeep = true
+		return false
+	}
+	p.depth++
+	return true
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
 // function to check whether there is a prefix function associated with
 // the token type and calls and returns the result of that call if found
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	if !p.enter() {
+		return nil
+	}
+	defer p.leave()
 
 	prefix := p.prefixParseFns[p.curToken.Type]
 
@@ -277,8 +393,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -287,9 +402,328 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+// brackets only change the order things are parsed in, so they don't get a
+// node of their own, the expression inside is returned as it is
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.NextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	lit := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	switch p.curToken.Type {
+	case token.RAW_STRING:
+		lit.Style = ast.RawString
+	case token.HEREDOC:
+		lit.Style = ast.HeredocString
+	}
+
+	return lit
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for {
+		str.Literals = append(str.Literals, p.curToken.Literal)
+		if p.curTokenIs(token.INTERP_END) {
+			return str
+		}
+
+		// the expressions inside ${ } are parsed like any other
+		p.NextToken()
+		str.Expressions = append(str.Expressions, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.INTERP_MID) && !p.peekTokenIs(token.INTERP_END) {
+			p.peekError(token.INTERP_END)
+			return nil
+		}
+		p.NextToken()
+	}
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	// the condition has to be in brackets, if (x < y) { ... }
+	if !p.expectPeek(token.LBRACKET) {
+		return nil
+	}
+	p.NextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Consequence = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.ELSE) {
+		p.NextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Alternative = p.parseBlockStatement()
+	}
+
+	return expression
+}
+
+// parseBlockStatement starts on the { and finishes on the matching }
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.NextToken()
+
+	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.errorAt(p.curToken, "expected } to close block, got EOF instead")
+			return block
+		}
+		stmt := p.parseStatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.NextToken()
+	}
+	block.Close = p.curToken
+
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACKET) {
+		return nil
+	}
+
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.ARROW) {
+		p.NextToken()
+		p.NextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
+// parseFunctionParameters reads (a, b, c) starting on the ( and returns nil
+// if the list is broken
+// parseFunctionParameters gives the parameter types as nil unless at least
+// one of the parameters has one
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.TypeExpr) {
+	identifiers := []*ast.Identifier{}
+	var types []ast.TypeExpr
+	annotated := false
+
+	if p.peekTokenIs(token.RBRACKET) {
+		p.NextToken()
+		return identifiers, nil
+	}
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil, nil
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		var typ ast.TypeExpr
+		if p.peekTokenIs(token.COLON) {
+			p.NextToken()
+			p.NextToken()
+			if typ = p.parseType(); typ == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.NextToken()
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil, nil
+	}
+
+	if !annotated {
+		types = nil
+	}
+	return identifiers, types
+}
+
+// parseType parses a type annotation starting at the current token, one of
+//
+//	int  [int]  {string: int}  fn(int, int) -> bool
+//
+// leaving the current token on the last token of the type
+func (p *Parser) parseType() ast.TypeExpr {
+	if !p.enter() {
+		return nil
+	}
+	defer p.leave()
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+
+	case token.LSQUARE:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.NextToken()
+		if t.Element = p.parseType(); t.Element == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RSQUARE) {
+			return nil
+		}
+		t.Close = p.curToken
+		return t
+
+	case token.LBRACE:
+		t := &ast.HashType{Token: p.curToken}
+		p.NextToken()
+		if t.Key = p.parseType(); t.Key == nil {
+			return nil
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.NextToken()
+		if t.Value = p.parseType(); t.Value == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		t.Close = p.curToken
+		return t
+
+	case token.FUNCTION:
+		t := &ast.FunctionType{Token: p.curToken, Parameters: []ast.TypeExpr{}}
+		if !p.expectPeek(token.LBRACKET) {
+			return nil
+		}
+		for !p.peekTokenIs(token.RBRACKET) {
+			if len(t.Parameters) > 0 && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			p.NextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			t.Parameters = append(t.Parameters, param)
+		}
+		p.NextToken()
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.NextToken()
+		if t.Result = p.parseType(); t.Result == nil {
+			return nil
+		}
+		return t
+	}
+
+	p.errorAt(p.curToken, "expected a type, got %s instead", p.curToken.Type)
+	return nil
+}
+
+// a ( after an expression is a call, so it is parsed as an infix operator
+// binding tighter than anything else
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RBRACKET)
+	if exp.Arguments == nil {
+		return nil
+	}
+	exp.Close = p.curToken
+	return exp
+}
+
+// parseExpressionList reads expressions separated by commas up to end, for
+// the arguments of a call and the elements of an array. It returns nil if
+// the list isn't closed by end
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.NextToken()
+		return list
+	}
+
+	p.NextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.NextToken()
+		p.NextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RSQUARE)
+	if array.Elements == nil {
+		return nil
+	}
+	array.Close = p.curToken
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.NextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RSQUARE) {
+		return nil
+	}
+	exp.Close = p.curToken
+
+	return exp
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -313,6 +747,18 @@ var precedences = map[token.TokenType]int{
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.LBRACKET: CALL,
+	token.LSQUARE:  INDEX,
+}
+
+// Precedence returns how tightly an infix operator binds, or LOWEST for
+// tokens that aren't infix operators. Tools printing programs use it to work
+// out where brackets are needed
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -1,10 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/helper_functions"
 	"monkey/lexer"
+	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -298,8 +301,7 @@ func TestParsingInfixExpression(t *testing.T) {
 		program := p.ParseProgram()
 		checkParserErrors(t, p)
 
-		// want to change this to len(infixTests)
-		expectedLength := 8
+		expectedLength := 1
 		helper_functions.CheckProgramLength(t, len(program.Statements), expectedLength)
 
 		// Expression and ExpressionStatement are not the same
@@ -390,6 +392,54 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"1 + (2 + 3) + 4",
+			"((1 + (2 + 3)) + 4)",
+		},
+		{
+			"(5 + 5) * 2",
+			"((5 + 5) * 2)",
+		},
+		{
+			"-(5 + 5)",
+			"(-(5 + 5))",
+		},
+		{
+			"!(a == b)",
+			"(!(a == b))",
+		},
+		{
+			"3 > 5 == false",
+			"((3 > 5) == false)",
+		},
+		{
+			"!(true == true)",
+			"(!(true == true))",
+		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"-add(a)(b)",
+			"(-add(a)(b))",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"f(x)[0](y)",
+			"(f(x)[0])(y)",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -403,3 +453,488 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestParsingWithDialect(t *testing.T) {
+	dialect := token.NewDialect()
+	dialect.AddKeyword("var", token.LET)
+	dialect.DisableOperator(token.SLASH)
+
+	l := lexer.NewWithConfig("var x = 5; a / b", lexer.Config{Dialect: dialect})
+	p := New(l)
+	program := p.ParseProgram()
+
+	if _, ok := program.Statements[0].(*ast.LetStatement); !ok {
+		t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T", program.Statements[0])
+	}
+	if len(p.Errors()) != 1 || p.Errors()[0] != "no prefix parse function for ILLEGAL found" {
+		t.Errorf("expected an error for the disabled operator. got=%q", p.Errors())
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue string
+		expectedStyle ast.StringStyle
+	}{
+		{`"hello world";`, "hello world", ast.QuotedString},
+		{"`raw\n\\n`;", "raw\n\\n", ast.RawString},
+		{"<<~EOF\n  {\"a\": 1}\nEOF\n", "{\"a\": 1}\n", ast.HeredocString},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expectedValue {
+			t.Errorf("literal.Value not %q. got=%q", tt.expectedValue, literal.Value)
+		}
+		if literal.Style != tt.expectedStyle {
+			t.Errorf("literal.Style not %d. got=%d", tt.expectedStyle, literal.Style)
+		}
+	}
+}
+
+func TestLetAndReturnValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;", "let x = 5;"},
+		{"let y = a + b * c", "let y = (a + (b * c));"},
+		{"let s = `select 1`;", "let s = `select 1`;"},
+		{"return -x;", "return (-x);"},
+		{"return \"a\\tb\"", "return \"a\\tb\";"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	input := `"Hello ${user}, you have ${count + 1} messages${"!"}"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	literals := []string{"Hello ", ", you have ", " messages", ""}
+	if len(str.Literals) != len(literals) {
+		t.Fatalf("wrong number of literals. expected=%q, got=%q", literals, str.Literals)
+	}
+	for i, lit := range literals {
+		if str.Literals[i] != lit {
+			t.Errorf("str.Literals[%d] not %q. got=%q", i, lit, str.Literals[i])
+		}
+	}
+
+	expressions := []string{"user", "(count + 1)", `"!"`}
+	if len(str.Expressions) != len(expressions) {
+		t.Fatalf("wrong number of expressions. got=%d", len(str.Expressions))
+	}
+	for i, exp := range expressions {
+		if str.Expressions[i].String() != exp {
+			t.Errorf("str.Expressions[%d] not %q. got=%q", i, exp, str.Expressions[i].String())
+		}
+	}
+
+	if str.String() != `"Hello ${user}, you have ${(count + 1)} messages${"!"}"` {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	l := lexer.New(`"a ${x y} b"`)
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	if p.Errors()[0] != "expected next token to be INTERP_END, got IDENT instead" {
+		t.Errorf("wrong error. got=%q", p.Errors()[0])
+	}
+}
+
+func TestBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true;", true},
+		{"false;", false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		b, ok := stmt.Expression.(*ast.Boolean)
+		if !ok {
+			t.Fatalf("exp is not *ast.Boolean. got=%T", stmt.Expression)
+		}
+		if b.Value != tt.expected {
+			t.Errorf("b.Value is not %t. got=%t", tt.expected, b.Value)
+		}
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		consequence string
+		alternative string // empty when there is no else
+	}{
+		{"if (x < y) { x }", "x", ""},
+		{"if (x < y) { x } else { y }", "x", "y"},
+		{"if (x < y) { let z = x; z } else { return y; }", "let z = x;z", "return y;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.IfExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.IfExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Condition.String() != "(x < y)" {
+			t.Errorf("condition wrong. got=%q", exp.Condition.String())
+		}
+		if exp.Consequence.String() != tt.consequence {
+			t.Errorf("consequence wrong. want=%q, got=%q", tt.consequence, exp.Consequence.String())
+		}
+
+		if tt.alternative == "" {
+			if exp.Alternative != nil {
+				t.Errorf("exp.Alternative was not nil. got=%q", exp.Alternative.String())
+			}
+		} else if exp.Alternative == nil || exp.Alternative.String() != tt.alternative {
+			t.Errorf("alternative wrong. want=%q, got=%v", tt.alternative, exp.Alternative)
+		}
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedBody   string
+	}{
+		{"fn() {};", []string{}, ""},
+		{"fn(x) { x };", []string{"x"}, "x"},
+		{"fn(x, y, z) { x + y + z; };", []string{"x", "y", "z"}, "((x + y) + z)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not *ast.FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("wrong number of parameters. want=%d, got=%d",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			if function.Parameters[i].Value != ident {
+				t.Errorf("parameter %d is not %s. got=%s", i, ident, function.Parameters[i].Value)
+			}
+		}
+
+		if function.Body.String() != tt.expectedBody {
+			t.Errorf("body wrong. want=%q, got=%q", tt.expectedBody, function.Body.String())
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	p := New(lexer.New("add(1, 2 * 3, 4 + 5);"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+
+	if exp.Function.String() != "add" {
+		t.Errorf("function wrong. got=%q", exp.Function.String())
+	}
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong number of arguments. got=%d", len(exp.Arguments))
+	}
+	testIntegerLiteral(t, exp.Arguments[0], 1)
+	if exp.Arguments[1].String() != "(2 * 3)" || exp.Arguments[2].String() != "(4 + 5)" {
+		t.Errorf("arguments wrong. got=%q, %q", exp.Arguments[1].String(), exp.Arguments[2].String())
+	}
+}
+
+func TestFunctionAndCallErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x, 1) { x }", "expected next token to be IDENT, got INT instead"},
+		{"fn(x { x }", "expected next token to be ), got { instead"},
+		{"if x { x }", "expected next token to be (, got IDENT instead"},
+		{"if (x) { x", "expected } to close block, got EOF instead"},
+		{"add(1, 2", "expected next token to be ), got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q. got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestArrayAndIndexParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[]", "[]"},
+		{"[1, 2 * 2, 3 + 3]", "[1, (2 * 2), (3 + 3)]"},
+		{"myArray[1 + 1]", "(myArray[(1 + 1)])"},
+		{"[[1], [2, 3]][0][1]", "(([[1], [2, 3]][0])[1])"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("[1, 2"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "expected next token to be ], got EOF instead" {
+		t.Errorf("expected an error for an unclosed array. got=%q", p.Errors())
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	p := New(lexer.New("let x 5;\nlet y = );\nfn(a: 1) {}"))
+	p.ParseProgram()
+
+	expected := []string{
+		"1:7: expected next token to be =, got INT instead",
+		"2:9: no prefix parse function for ) found",
+		"3:7: expected a type, got INT instead",
+	}
+	got := []string{}
+	for _, e := range p.ErrorList() {
+		got = append(got, e.String())
+	}
+	// the parser carries on after the last one and finds more
+	if len(got) < len(expected) || strings.Join(got[:len(expected)], "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong errors.\nexpected=%q\ngot=%q", expected, got)
+	}
+	if len(p.Errors()) != len(p.ErrorList()) {
+		t.Errorf("Errors and ErrorList differ. got=%q and %v", p.Errors(), p.ErrorList())
+	}
+}
+
+func TestSpans(t *testing.T) {
+	// spans run to the closing bracket or brace of what they end with. For
+	// lets the span of the type is checked
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2]", "[1, 2]"},
+		{"f(x)", "f(x)"},
+		{"a[f(0)]", "a[f(0)]"},
+		{"fn(x) { x }", "fn(x) { x }"},
+		{"if (x) { 1 } else { [2] }", "if (x) { 1 } else { [2] }"},
+		{"f(fn() { 1 })(2)", "f(fn() { 1 })(2)"},
+		{"let a: {string: [int]} = [1]", "{string: [int]}"},
+		{"let g: fn([int]) -> [int] = h", "fn([int]) -> [int]"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		var node ast.Node = program.Statements[0]
+		if let, ok := node.(*ast.LetStatement); ok {
+			node = let.Type
+		}
+		start, end, _ := ast.Span(node)
+		if got := tt.input[start.Offset:end.Offset]; got != tt.expected {
+			t.Errorf("wrong span for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let ages: {string: int} = x;", "let ages: {string: int} = x;"},
+		{"let f: fn(int, [int]) -> bool = g;", "let f: fn(int, [int]) -> bool = g;"},
+		{"let f: fn() -> fn(int) -> int = g;", "let f: fn() -> fn(int) -> int = g;"},
+		{"fn(a: string, b: int) -> bool { a }", "fn(a: string, b: int) -> bool a"},
+		{"fn(a, b: int) { a }", "fn(a, b: int) a"},
+		{"fn() -> {string: [int]} { x }", "fn() -> {string: [int]} x"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		helper_functions.CheckProgramLength(t, len(program.Statements), 1)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTypeAnnotationFields(t *testing.T) {
+	p := New(lexer.New("fn(a, b: [int]) -> int { a }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if fn.ParameterType(0) != nil {
+		t.Errorf("expected a to have no type. got=%s", fn.ParameterType(0))
+	}
+	array, ok := fn.ParameterType(1).(*ast.ArrayType)
+	if !ok {
+		t.Fatalf("expected b to have an *ast.ArrayType. got=%T", fn.ParameterType(1))
+	}
+	if array.Element.String() != "int" {
+		t.Errorf("expected element type int. got=%s", array.Element)
+	}
+	if fn.ReturnType == nil || fn.ReturnType.String() != "int" {
+		t.Errorf("expected return type int. got=%v", fn.ReturnType)
+	}
+
+	p = New(lexer.New("fn(a, b) { a }"))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	fn = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if fn.ParameterTypes != nil || fn.ReturnType != nil {
+		t.Errorf("expected no types. got=%v, %v", fn.ParameterTypes, fn.ReturnType)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 5;", "expected a type, got = instead"},
+		{"let x: int 5;", "expected next token to be =, got INT instead"},
+		{"let xs: [int = 5;", "expected next token to be ], got = instead"},
+		{"let h: {string int} = 5;", "expected next token to be :, got IDENT instead"},
+		{"let f: fn(int) = 5;", "expected next token to be ->, got = instead"},
+		{"fn(a: 1) { a }", "expected a type, got INT instead"},
+		{"fn(a) -> 1 { a }", "expected a type, got INT instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("input %q: expected first error %q. got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		input   string
+		tooDeep bool
+	}{
+		{"((1))", false},
+		{"1 + 2 + 3 + 4 + 5", false},
+		{"[[1]]", false},
+		{"(((1)))", true},
+		{"let f = fn() { fn() { fn() { 1 } } };", true},
+		{"-(-(-1))", true},
+		{"1 + (2 + (3 + 4))", true},
+		// types count too
+		{"let x: [[int]] = 1;", false},
+		{"let x: fn(fn(int) -> int) -> int = 1;", false},
+		{"let x: [[[int]]] = 1;", true},
+		{"let x: {string: [[int]]} = 1;", true},
+		{"let x: fn(fn(fn() -> int) -> int) -> int = 1;", true},
+		{"let x: fn() -> fn() -> [int] = 1;", true},
+		{"fn(x: [[int]]) { x }", true},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.SetMaxDepth(3)
+		p.ParseProgram()
+
+		if !tt.tooDeep {
+			if len(p.Errors()) != 0 {
+				t.Errorf("%q: expected no errors. got=%q", tt.input, p.Errors())
+			}
+			continue
+		}
+		errs := p.ErrorList()
+		var nesting *NestingLimitError
+		if len(errs) != 1 || !errors.As(errs[0].Err, &nesting) || nesting.Limit != 3 ||
+			errs[0].Message != "expressions nested more than 3 deep" {
+			t.Errorf("%q: expected just a nesting error. got=%v", tt.input, errs)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+var (
+	errorType   = reflect.TypeFor[error]()
+	contextType = reflect.TypeFor[context.Context]()
+	valueType   = reflect.TypeFor[Value]()
+)
+
+// RegisterFunc makes fn a builtin called name for the programs the VM runs.
+// fn can take and give any of the values Set takes, and its arguments are
+// converted from the Monkey values it is called with, failing if they
+// don't fit. It can be variadic, can take a context.Context first to get the
+// context of the Eval or Call that is running, and can give an error last,
+// which stops the program with an *object.Error that wraps it. What fn
+// gives counts against MaxAlloc, except for Values, which the VM has
+// already counted.
+//
+// fn can use the VM while a program is calling it. Get, Set and
+// RegisterFunc can be called as they are, but Eval and Call must be given
+// the context fn was given, or they wait for the program calling fn to
+// finish, which it never does. The context only lets them in until fn
+// returns, so fn can't hand it to a goroutine that outlives it
+func (vm *VM) RegisterFunc(name string, fn any) error {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		return fmt.Errorf("%s: expected a function, got %T", name, fn)
+	}
+	t := f.Type()
+
+	params := []reflect.Type{}
+	for i := range t.NumIn() {
+		params = append(params, t.In(i))
+	}
+	takesContext := len(params) > 0 && params[0] == contextType
+	if takesContext {
+		params = params[1:]
+	}
+	for i, p := range params {
+		if t.IsVariadic() && i == len(params)-1 {
+			p = p.Elem()
+		}
+		if !convertible(p) {
+			return fmt.Errorf("%s: can't convert to parameter type %s", name, p)
+		}
+	}
+
+	results := []reflect.Type{}
+	for i := range t.NumOut() {
+		results = append(results, t.Out(i))
+	}
+	givesError := len(results) > 0 && results[len(results)-1] == errorType
+	if givesError {
+		results = results[:len(results)-1]
+	}
+	if len(results) > 1 {
+		return fmt.Errorf("%s: can't give more than one value and an error", name)
+	}
+	if len(results) == 1 && !convertible(results[0]) {
+		return fmt.Errorf("%s: can't convert from result type %s", name, results[0])
+	}
+
+	builtin := &object.Builtin{Name: name}
+	builtin.Fn = func(args ...object.Object) object.Object {
+		if t.IsVariadic() && len(args) < len(params)-1 || !t.IsVariadic() && len(args) != len(params) {
+			return wrongArguments(name, len(params), t.IsVariadic(), len(args))
+		}
+
+		hc := &hostCall{vm: vm}
+		in := []reflect.Value{}
+		if takesContext {
+			in = append(in, reflect.ValueOf(context.WithValue(vm.ctx, hostCallKey{}, hc)))
+		}
+		for i, arg := range args {
+			p := params[min(i, len(params)-1)]
+			if t.IsVariadic() && i >= len(params)-1 {
+				p = p.Elem()
+			}
+			v, err := fromObject(arg, p)
+			if err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d to `%s`: %s", i+1, name, err)}
+			}
+			in = append(in, v)
+		}
+
+		vm.mu.Unlock()
+		out, err := call(f, in)
+		vm.mu.Lock()
+		hc.returned.Store(true)
+		if err == nil && givesError && !out[len(out)-1].IsNil() {
+			err = out[len(out)-1].Interface().(error)
+		}
+		if err != nil {
+			return &object.Error{Message: err.Error(), Err: err}
+		}
+		if len(results) == 0 {
+			return evaluator.NULL
+		}
+
+		obj, size, err := reflectToObject(out[0])
+		if err != nil {
+			return &object.Error{Message: fmt.Sprintf("result of `%s`: %s", name, err)}
+		}
+		// the value given back is counted by the call, but what is inside
+		// it has to be counted here
+		if err := vm.in.Allocate(max(0, size-evaluator.ShallowSize(obj))); err != nil {
+			return err
+		}
+		return obj
+	}
+
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+	vm.in.Builtins[name] = builtin
+	return nil
+}
+
+// hostCall is put in the context given to a registered function, so the
+// VM knows the calls it makes back into the VM come from the program
+// running
+type hostCall struct {
+	vm       *VM
+	returned atomic.Bool
+}
+
+type hostCallKey struct{}
+
+// call calls f, turning a panic into an error so a broken function can't
+// take the host down with it
+func call(f reflect.Value, in []reflect.Value) (out []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return f.Call(in), nil
+}
+
+func wrongArguments(name string, want int, variadic bool, got int) *object.Error {
+	if variadic {
+		return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `%s`: want at least %d, got=%d", name, want-1, got)}
+	}
+	return &object.Error{Message: fmt.Sprintf("wrong number of arguments to `%s`: want=%d, got=%d", name, want, got)}
+}
+
+// convertible is whether values of type t can go between Go and Monkey
+func convertible(t reflect.Type) bool {
+	if t == valueType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return convertible(t.Elem())
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.Bool, reflect.String, reflect.Interface,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return convertible(t.Key()) && convertible(t.Elem())
+		}
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+	return false
+}
+
+// fromObject converts obj to a Go value of type t, which is convertible
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("can't convert %s to %s", obj.Type(), t)
+	}
+
+	if t == valueType {
+		return reflect.ValueOf(Value{obj: obj}), nil
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Interface:
+		if obj == evaluator.NULL {
+			return v, nil
+		}
+		v.Set(reflect.ValueOf(toGo(obj)))
+
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b.Value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(i.Value) {
+			return reflect.Value{}, fmt.Errorf("%d doesn't fit in %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d doesn't fit in %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+
+	case reflect.Float32, reflect.Float64:
+		// integers are taken too, as programs can't write floats
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return mismatch()
+		}
+
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s.Value)
+
+	case reflect.Slice, reflect.Array:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		if t.Kind() == reflect.Array && len(array.Elements) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("expected %d elements, got %d", t.Len(), len(array.Elements))
+		}
+		if t.Kind() == reflect.Slice {
+			v = reflect.MakeSlice(t, len(array.Elements), len(array.Elements))
+		}
+		for i, e := range array.Elements {
+			ev, err := fromObject(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		v = reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Sorted() {
+			k, err := fromObject(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			ev, err := fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("value for %s: %w", pair.Key.Inspect(), err)
+			}
+			v.SetMapIndex(k, ev)
+		}
+
+	case reflect.Pointer:
+		if obj == evaluator.NULL {
+			return v, nil
+		}
+		ev, err := fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v = reflect.New(t.Elem())
+		v.Elem().Set(ev)
+
+	default:
+		return mismatch()
+	}
+	return v, nil
+}
@@ -0,0 +1,234 @@
+package monkey
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func register(t *testing.T, vm *VM, name string, fn any) {
+	t.Helper()
+	if err := vm.RegisterFunc(name, fn); err != nil {
+		t.Fatalf("registering %s: %v", name, err)
+	}
+}
+
+var errNegative = errors.New("negative")
+
+func TestRegisterFunc(t *testing.T) {
+	vm := New(Options{})
+	register(t, vm, "sha256", func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	})
+	register(t, vm, "sum", func(first int, rest ...int) int {
+		for _, n := range rest {
+			first += n
+		}
+		return first
+	})
+	register(t, vm, "not", func(b bool) bool { return !b })
+	register(t, vm, "lengths", func(words []string) []int {
+		lengths := []int{}
+		for _, w := range words {
+			lengths = append(lengths, len(w))
+		}
+		return lengths
+	})
+	register(t, vm, "keys", func(h map[string]int) []string {
+		keys := []string{}
+		for k := range h {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	})
+	register(t, vm, "pair", func(a [2]uint8) map[string]uint8 {
+		return map[string]uint8{"first": a[0], "second": a[1]}
+	})
+	register(t, vm, "describe", func(v any) string { return fmt.Sprintf("%T", v) })
+	register(t, vm, "orDefault", func(p *int) int {
+		if p == nil {
+			return -1
+		}
+		return *p
+	})
+	register(t, vm, "sqrt", func(n int) (int, error) {
+		if n < 0 {
+			return 0, errNegative
+		}
+		i := 0
+		for (i+1)*(i+1) <= n {
+			i++
+		}
+		return i, nil
+	})
+	register(t, vm, "half", func(f float64) float32 { return float32(f / 2) })
+	register(t, vm, "nothing", func() {})
+	register(t, vm, "apply", func(ctx context.Context, f Value, x int) (Value, error) {
+		if ctx == nil {
+			return Value{}, errors.New("no context")
+		}
+		return f, nil
+	})
+	vm.Set("h", map[string]int{"b": 1, "a": 2})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sha256("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sum(1)", "1"},
+		{"sum(1, 2, 3)", "6"},
+		{"not(true)", "false"},
+		{`lengths(["a", "héllo", ""])`, "[1, 6, 0]"},
+		{"lengths([])", "[]"},
+		{"keys(h)", `["a", "b"]`},
+		{"pair([1, 255])", `{"first": 1, "second": 255}`},
+		{"describe(1)", "int64"},
+		{`describe([1, "a"])`, "[]interface {}"},
+		{"describe(h)", "map[string]interface {}"},
+		{"describe(fn(x) { x })", "monkey.Value"},
+		{"orDefault(3)", "3"},
+		{`orDefault(h["missing"])`, "-1"},
+		{"sqrt(17)", "4"},
+		{"half(3)", "1.5"},
+		{`half(json_parse("5.0"))`, "2.5"},
+		{"half(2)", "1.0"},
+		{`describe(json_parse("0.5"))`, "float64"},
+		{"nothing()", "null"},
+		{"apply(fn(x) { x * 2 }, 1)(5)", "10"},
+	}
+
+	for _, tt := range tests {
+		if v := eval(t, vm, tt.input); v.String() != tt.expected {
+			t.Errorf("%q: expected %s. got=%s", tt.input, tt.expected, v)
+		}
+	}
+}
+
+func TestRegisterFuncReentrant(t *testing.T) {
+	vm := New(Options{})
+	register(t, vm, "lookup", func(name string) Value {
+		v, _ := vm.Get(name)
+		return v
+	})
+	register(t, vm, "store", func(name string, v Value) error {
+		return vm.Set(name, v)
+	})
+	register(t, vm, "callBack", func(ctx context.Context, name string, x int) (Value, error) {
+		return vm.Call(ctx, name, x)
+	})
+	register(t, vm, "evalBack", func(ctx context.Context, src string) (Value, error) {
+		return vm.Eval(ctx, src)
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 5; lookup("x")`, "5"},
+		{`store("y", [1, 2]); y`, "[1, 2]"},
+		{`let double = fn(n) { n * 2 }; callBack("double", 21)`, "42"},
+		{`let f = fn(n) { if (n == 0) { 0 } else { callBack("f", n - 1) + 1 } }; f(5)`, "5"},
+		{`evalBack("let z = 3; z * z") + z`, "12"},
+	}
+
+	for _, tt := range tests {
+		done := make(chan string)
+		go func() {
+			v, err := vm.Eval(context.Background(), tt.input)
+			if err != nil {
+				done <- err.Error()
+				return
+			}
+			done <- v.String()
+		}()
+		select {
+		case got := <-done:
+			if got != tt.expected {
+				t.Errorf("%q: expected %s. got=%s", tt.input, tt.expected, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%q: deadlocked", tt.input)
+		}
+	}
+
+}
+
+func TestRegisterFuncErrors(t *testing.T) {
+	vm := New(Options{})
+	register(t, vm, "sqrt", func(n int) (int, error) {
+		if n < 0 {
+			return 0, errNegative
+		}
+		return 0, nil
+	})
+	register(t, vm, "small", func(n int8) int8 { return n })
+	register(t, vm, "count", func(n uint) uint { return n })
+	register(t, vm, "words", func(ws []string) int { return len(ws) })
+	register(t, vm, "pair", func(a [2]int) int { return a[0] })
+	register(t, vm, "lookup", func(h map[string]bool) int { return len(h) })
+	register(t, vm, "join", func(sep string, parts ...string) string { return strings.Join(parts, sep) })
+	register(t, vm, "explode", func() int { panic("boom") })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sqrt("a")`, "1:1: argument 1 to `sqrt`: can't convert STRING to int"},
+		{"sqrt(1, 2)", "1:1: wrong number of arguments to `sqrt`: want=1, got=2"},
+		{"sqrt(-1)", "1:1: negative"},
+		{"small(200)", "1:1: argument 1 to `small`: 200 doesn't fit in int8"},
+		{"count(-1)", "1:1: argument 1 to `count`: -1 doesn't fit in uint"},
+		{`words(["a", 1])`, "1:1: argument 1 to `words`: element 1: can't convert INTEGER to string"},
+		{"words(1)", "1:1: argument 1 to `words`: can't convert INTEGER to []string"},
+		{"pair([1])", "1:1: argument 1 to `pair`: expected 2 elements, got 1"},
+		{"lookup(h)", "1:1: argument 1 to `lookup`: value for a: can't convert INTEGER to bool"},
+		{"join()", "1:1: wrong number of arguments to `join`: want at least 1, got=0"},
+		{`join(",", "a", 1)`, "1:1: argument 3 to `join`: can't convert INTEGER to string"},
+		{"explode()", "1:1: panic: boom"},
+	}
+	vm.Set("h", map[string]int{"a": 1})
+
+	for _, tt := range tests {
+		_, err := vm.Eval(context.Background(), tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%q: expected error %q. got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	// the function's error can be told apart by the host
+	_, err := vm.Eval(context.Background(), "let x = 1;\nsqrt(-x)")
+	if !errors.Is(err, errNegative) || err.Error() != "2:1: negative" {
+		t.Errorf("expected the function's error. got=%v", err)
+	}
+}
+
+func TestRegisterFuncBadSignatures(t *testing.T) {
+	tests := []struct {
+		fn       any
+		expected string
+	}{
+		{42, "f: expected a function, got int"},
+		{func(complex128) {}, "f: can't convert to parameter type complex128"},
+		{func(...chan int) {}, "f: can't convert to parameter type chan int"},
+		{func(map[[2]int]int) {}, "f: can't convert to parameter type map[[2]int]int"},
+		{func(fmt.Stringer) {}, "f: can't convert to parameter type fmt.Stringer"},
+		{func() (int, int) { return 0, 0 }, "f: can't give more than one value and an error"},
+		{func() struct{} { return struct{}{} }, "f: can't convert from result type struct {}"},
+	}
+
+	for _, tt := range tests {
+		err := New(Options{}).RegisterFunc("f", tt.fn)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%T: expected error %q. got=%v", tt.fn, tt.expected, err)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"monkey/lexer"
-	"monkey/token"
 )
 
 const PROMPT = ">> "
@@ -24,7 +23,7 @@ func Start(in io.Reader, out io.Writer) {
 		line := scanner.Text()
 		l := lexer.New(line)
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		for tok := range l.Tokens() {
 			fmt.Fprintf(out, "%+v\n", tok)
 		}
 	}
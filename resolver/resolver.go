@@ -0,0 +1,324 @@
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"monkey/ast"
+	"monkey/token"
+)
+
+// The resolver package works out which declaration every identifier in a
+// program refers to, without running it, and reports the mistakes it finds
+// along the way.
+//
+// Scopes follow the blocks of the program: the program itself, each function
+// literal, which holds its parameters and the statements of its body, and
+// each branch of an if. A let is visible from the end of its statement to the
+// end of its scope, so in let x = x + 1 the x on the right is an earlier x.
+// The exception is code inside a function, which can use lets from outer
+// scopes that come later, as the function only runs once they are defined.
+// This is what lets a function call itself:
+//
+//	let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+
+type Kind int
+
+const (
+	Let Kind = iota
+	Parameter
+	Builtin
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Let:
+		return "let"
+	case Parameter:
+		return "parameter"
+	case Builtin:
+		return "builtin"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Declaration is a name brought into a scope by a let, a function parameter
+// or as a builtin
+type Declaration struct {
+	Name  string
+	Ident *ast.Identifier // where it is declared, nil for builtins
+	Kind  Kind
+	Scope *Scope
+	Uses  []*ast.Identifier // in the order they appear in the source
+}
+
+// where gives the position of a declaration for messages
+func (d *Declaration) where() string {
+	if d.Ident == nil {
+		return "the builtin " + d.Name
+	}
+	pos := d.Ident.Token.Pos
+	return fmt.Sprintf("the declaration at %d:%d", pos.Line, pos.Column)
+}
+
+type Scope struct {
+	Parent   *Scope
+	Children []*Scope
+	// Node is the *ast.Program, *ast.FunctionLiteral or *ast.BlockStatement
+	// the scope belongs to, or nil for the scope holding the builtins
+	Node ast.Node
+	// Declarations holds every declaration in the scope in source order,
+	// the same name can appear more than once
+	Declarations []*Declaration
+
+	// the latest declaration of each name that has been reached so far, and
+	// the ones further on in the scope that haven't been reached yet
+	declared map[string]*Declaration
+	pending  map[string][]*Declaration
+}
+
+func newScope(parent *Scope, node ast.Node) *Scope {
+	s := &Scope{
+		Parent:   parent,
+		Node:     node,
+		declared: map[string]*Declaration{},
+		pending:  map[string][]*Declaration{},
+	}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+// Lookup finds the last declaration of name in this scope, or if there isn't
+// one the closest enclosing scope that has one
+func (s *Scope) Lookup(name string) *Declaration {
+	for ; s != nil; s = s.Parent {
+		if d, ok := s.declared[name]; ok {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Scope) isFunction() bool {
+	_, ok := s.Node.(*ast.FunctionLiteral)
+	return ok
+}
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem found in the program, covering the identifier it
+// is about
+type Diagnostic struct {
+	Pos      token.Position
+	End      token.Position
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Pos.Line, d.Pos.Column, d.Severity, d.Message)
+}
+
+// Info is everything the resolver found out about a program
+type Info struct {
+	// Defs maps the identifiers that declare a name to their declaration
+	Defs map[*ast.Identifier]*Declaration
+	// Uses maps every other identifier to the declaration it refers to,
+	// undefined identifiers are left out
+	Uses map[*ast.Identifier]*Declaration
+	// Scopes maps the nodes that have a scope to it
+	Scopes map[ast.Node]*Scope
+	// Universe holds the builtins and is the parent of the program's scope
+	Universe *Scope
+
+	// Diagnostics is sorted by position. Undefined names, names used before
+	// they are defined and names declared twice in one scope are errors,
+	// shadowing a name from an outer scope is a warning
+	Diagnostics []Diagnostic
+}
+
+// Errors returns the diagnostics with Error severity
+func (info *Info) Errors() []Diagnostic {
+	errors := []Diagnostic{}
+	for _, d := range info.Diagnostics {
+		if d.Severity == Error {
+			errors = append(errors, d)
+		}
+	}
+	return errors
+}
+
+// Resolve resolves every identifier in program. builtins are names that are
+// defined without being declared anywhere in the program
+func Resolve(program *ast.Program, builtins ...string) *Info {
+	r := &resolver{info: &Info{
+		Defs:        map[*ast.Identifier]*Declaration{},
+		Uses:        map[*ast.Identifier]*Declaration{},
+		Scopes:      map[ast.Node]*Scope{},
+		Diagnostics: []Diagnostic{},
+	}}
+
+	r.info.Universe = newScope(nil, nil)
+	for _, name := range builtins {
+		d := &Declaration{Name: name, Kind: Builtin, Scope: r.info.Universe}
+		r.info.Universe.Declarations = append(r.info.Universe.Declarations, d)
+		r.info.Universe.declared[name] = d
+	}
+
+	r.scope = r.info.Universe
+	r.openScope(program)
+	r.statements(program.Statements)
+	r.scope = r.scope.Parent
+
+	sort.SliceStable(r.info.Diagnostics, func(i, j int) bool {
+		return r.info.Diagnostics[i].Pos.Offset < r.info.Diagnostics[j].Pos.Offset
+	})
+	return r.info
+}
+
+type resolver struct {
+	info  *Info
+	scope *Scope
+}
+
+func (r *resolver) openScope(node ast.Node) {
+	r.scope = newScope(r.scope, node)
+	r.info.Scopes[node] = r.scope
+}
+
+// statements resolves the statements of the current scope, after noting the
+// lets in it so that uses from inside functions can be resolved to them
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, s := range stmts {
+		if let, ok := s.(*ast.LetStatement); ok && let.Name != nil {
+			d := &Declaration{Name: let.Name.Value, Ident: let.Name, Kind: Let, Scope: r.scope}
+			r.scope.pending[d.Name] = append(r.scope.pending[d.Name], d)
+		}
+	}
+
+	for _, s := range stmts {
+		r.node(s)
+	}
+}
+
+func (r *resolver) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		if n.Value != nil {
+			r.node(n.Value)
+		}
+		if n.Name != nil {
+			pending := r.scope.pending[n.Name.Value]
+			d := pending[0]
+			r.scope.pending[n.Name.Value] = pending[1:]
+			r.declare(d)
+		}
+
+	case *ast.Identifier:
+		r.use(n)
+
+	case *ast.FunctionLiteral:
+		r.openScope(n)
+		for _, p := range n.Parameters {
+			if p != nil {
+				r.declare(&Declaration{Name: p.Value, Ident: p, Kind: Parameter, Scope: r.scope})
+			}
+		}
+		if n.Body != nil {
+			r.statements(n.Body.Statements)
+		}
+		r.scope = r.scope.Parent
+
+	case *ast.BlockStatement:
+		r.openScope(n)
+		r.statements(n.Statements)
+		r.scope = r.scope.Parent
+
+	default:
+		// nothing else affects scopes, so the children are resolved in order
+		ast.Inspect(node, func(child ast.Node) bool {
+			if child == node {
+				return true
+			}
+			if child != nil {
+				r.node(child)
+			}
+			return false
+		})
+	}
+}
+
+func (r *resolver) declare(d *Declaration) {
+	if prev, ok := r.scope.declared[d.Name]; ok {
+		r.report(d.Ident, Error, "%s is already declared in this scope, see %s", d.Name, prev.where())
+	} else if outer := r.scope.Parent.Lookup(d.Name); outer != nil {
+		r.report(d.Ident, Warning, "%s shadows %s", d.Name, outer.where())
+	}
+
+	r.scope.Declarations = append(r.scope.Declarations, d)
+	r.scope.declared[d.Name] = d
+	r.info.Defs[d.Ident] = d
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	name := ident.Value
+
+	// once we are looking outside the function the identifier is in, lets
+	// that haven't been reached yet will have been by the time it runs
+	inFunction := false
+	var early *Declaration
+
+	for s := r.scope; s != nil; s = s.Parent {
+		if d, ok := s.declared[name]; ok {
+			r.resolved(ident, d)
+			return
+		}
+		if pending := s.pending[name]; len(pending) > 0 {
+			if inFunction {
+				r.resolved(ident, pending[0])
+				return
+			}
+			if early == nil {
+				early = pending[0]
+			}
+		}
+		if s.isFunction() {
+			inFunction = true
+		}
+	}
+
+	if early != nil {
+		r.report(ident, Error, "%s is used before it is defined", name)
+		r.resolved(ident, early)
+		return
+	}
+	r.report(ident, Error, "undefined variable %s", name)
+}
+
+func (r *resolver) resolved(ident *ast.Identifier, d *Declaration) {
+	r.info.Uses[ident] = d
+	d.Uses = append(d.Uses, ident)
+}
+
+func (r *resolver) report(ident *ast.Identifier, severity Severity, format string, args ...any) {
+	r.info.Diagnostics = append(r.info.Diagnostics, Diagnostic{
+		Pos:      ident.Token.Pos,
+		End:      ident.Token.End,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,157 @@
+package resolver
+
+import (
+	"testing"
+
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+	return program
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x + 1;", []string{}},
+		{"y;", []string{"1:1: error: undefined variable y"}},
+		{"let x = 1;\nlet x = 2;", []string{
+			"2:5: error: x is already declared in this scope, see the declaration at 1:5",
+		}},
+		{"x; let x = 1;", []string{"1:1: error: x is used before it is defined"}},
+		{"let x = x;", []string{"1:9: error: x is used before it is defined"}},
+		{"let x = 1; let f = fn(x) { x };", []string{
+			"1:23: warning: x shadows the declaration at 1:5",
+		}},
+		{"let f = fn(a, a) { a };", []string{
+			"1:15: error: a is already declared in this scope, see the declaration at 1:12",
+		}},
+		{"let x = 1; if (true) { let x = 2; x };", []string{
+			"1:28: warning: x shadows the declaration at 1:5",
+		}},
+		// lets in an if are only visible inside it
+		{"if (true) { let y = 1; }; y;", []string{"1:27: error: undefined variable y"}},
+		// functions can use lets that come later, they run after them
+		{"let f = fn() { g() }; let g = fn() { 1 };", []string{}},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };", []string{}},
+		// but code that runs straight away can't
+		{"if (true) { g() }; let g = fn() { 1 };", []string{"1:13: error: g is used before it is defined"}},
+		{"let f = fn() { let a = b; let b = 1; };", []string{"1:24: error: b is used before it is defined"}},
+		{"\"${name}\"; add(1, 2);", []string{
+			"1:4: error: undefined variable name",
+			"1:12: error: undefined variable add",
+		}},
+		{"let len = 1;", []string{"1:5: warning: len shadows the builtin len"}},
+		{"len(\"abc\");", []string{}},
+	}
+
+	for _, tt := range tests {
+		info := Resolve(parse(t, tt.input), "len")
+
+		got := []string{}
+		for _, d := range info.Diagnostics {
+			got = append(got, d.String())
+		}
+
+		if len(got) != len(tt.expected) {
+			t.Errorf("input %q: wrong diagnostics.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("input %q: diagnostic %d wrong.\nexpected=%q\ngot=%q", tt.input, i, tt.expected[i], got[i])
+			}
+		}
+	}
+}
+
+func TestUses(t *testing.T) {
+	program := parse(t, `
+		let x = 1;
+		let f = fn(x) { x };
+		let x = x + 1;
+		f(x);
+	`)
+	info := Resolve(program)
+
+	// collect the uses of x in source order
+	var xs []*ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && ident.Value == "x" {
+			if _, def := info.Defs[ident]; !def {
+				xs = append(xs, ident)
+			}
+		}
+		return true
+	})
+	if len(xs) != 3 {
+		t.Fatalf("expected 3 uses of x. got=%d", len(xs))
+	}
+
+	expected := []struct {
+		kind Kind
+		line int
+	}{
+		{Parameter, 3}, // x in the body of f
+		{Let, 2},       // x + 1 is the first x
+		{Let, 4},       // f(x) is the second
+	}
+	for i, e := range expected {
+		d := info.Uses[xs[i]]
+		if d == nil {
+			t.Fatalf("use %d of x was not resolved", i)
+		}
+		if d.Kind != e.kind || d.Ident.Token.Pos.Line != e.line {
+			t.Errorf("use %d of x resolved to a %s on line %d. want=%s on line %d",
+				i, d.Kind, d.Ident.Token.Pos.Line, e.kind, e.line)
+		}
+	}
+
+	f := info.Scopes[program].Lookup("f")
+	if f == nil || len(f.Uses) != 1 || f.Uses[0].Token.Pos.Line != 5 {
+		t.Errorf("f should be used once on line 5. got=%v", f)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	program := parse(t, "let f = fn(a) { if (a) { let b = a; b } else { a } };")
+	info := Resolve(program, "len")
+
+	global := info.Scopes[program]
+	if global.Parent != info.Universe {
+		t.Fatalf("program scope should be inside the universe")
+	}
+	if len(global.Children) != 1 {
+		t.Fatalf("expected one function scope. got=%d", len(global.Children))
+	}
+
+	function := global.Children[0]
+	if _, ok := function.Node.(*ast.FunctionLiteral); !ok {
+		t.Fatalf("expected a function scope. got=%T", function.Node)
+	}
+	if len(function.Declarations) != 1 || function.Declarations[0].Name != "a" {
+		t.Errorf("function scope should only declare a")
+	}
+	if len(function.Children) != 2 {
+		t.Fatalf("expected a scope for each branch of the if. got=%d", len(function.Children))
+	}
+
+	then := function.Children[0]
+	if then.Lookup("b") == nil || then.Lookup("a") == nil || then.Lookup("len") == nil {
+		t.Errorf("a, b and len should all be visible in the first branch")
+	}
+	if function.Lookup("b") != nil {
+		t.Errorf("b should not be visible outside the branch it is declared in")
+	}
+}
@@ -1,10 +1,21 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // where the token starts in the input
+	End     Position // just after the last character of the token
+}
+
+// Position is a location in the input given to the lexer
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number in bytes, starting at 1
 }
 
 const (
@@ -15,6 +26,16 @@ const (
 	IDENT = "IDENT" // add, foobar, x, y, ...
 	INT   = "INT"   // 123456
 
+	STRING     = "STRING"     // "hello\n"
+	RAW_STRING = "RAW_STRING" // `no \escapes`
+	HEREDOC    = "HEREDOC"    // <<~EOF ... EOF
+
+	// "a ${x} b ${y} c" is lexed as INTERP_START("a "), the tokens for x,
+	// INTERP_MID(" b "), the tokens for y and then INTERP_END(" c")
+	INTERP_START = "INTERP_START"
+	INTERP_MID   = "INTERP_MID"
+	INTERP_END   = "INTERP_END"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -29,11 +50,15 @@ const (
 	// Delimeters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ARROW     = "->" // between the parameters and result of a function type
 
 	LBRACKET = "("
 	RBRACKET = ")"
 	LBRACE   = "{"
 	RBRACE   = "}"
+	LSQUARE  = "["
+	RSQUARE  = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -67,3 +92,73 @@ func LookupIndent(ident string) TokenType {
 	// else return the TokenType token.IDENT used for all user-defined ids
 	return IDENT
 }
+
+// Dialect is a variant of the language with its own keywords and operators.
+// Each lexer is given its own Dialect, so hosts embedding the language can
+// change it without touching the keywords table above. Keywords can be
+// added, spelled differently and removed, but operators can only be turned
+// off, as the lexer reads each of them with code of its own
+type Dialect struct {
+	keywords map[string]TokenType
+	disabled map[TokenType]bool
+}
+
+// NewDialect returns a Dialect with the default keywords and every operator
+// turned on, ready to be changed
+func NewDialect() *Dialect {
+	d := &Dialect{
+		keywords: make(map[string]TokenType),
+		disabled: make(map[TokenType]bool),
+	}
+	for word, tok := range keywords {
+		d.keywords[word] = tok
+	}
+	return d
+}
+
+// AddKeyword makes word lex as the keyword t, for example adding "func" as
+// another way of writing FUNCTION
+func (d *Dialect) AddKeyword(word string, t TokenType) {
+	d.keywords[word] = t
+}
+
+// RemoveKeyword makes word an ordinary identifier again
+func (d *Dialect) RemoveKeyword(word string) {
+	delete(d.keywords, word)
+}
+
+// operators are the token types DisableOperator takes
+var operators = map[TokenType]bool{
+	ASSIGN: true, PLUS: true, MINUS: true, EXCLAM: true, ASTERISK: true, SLASH: true,
+	LT: true, GT: true, EQ: true, NOT_EQ: true,
+}
+
+// DisableOperator makes the lexer treat the operator t as ILLEGAL. It fails
+// for anything that isn't an operator, like a delimiter or a keyword
+func (d *Dialect) DisableOperator(t TokenType) error {
+	if !operators[t] {
+		return fmt.Errorf("%s is not an operator", t)
+	}
+	d.disabled[t] = true
+	return nil
+}
+
+// EnableOperator turns the operator t back on
+func (d *Dialect) EnableOperator(t TokenType) {
+	delete(d.disabled, t)
+}
+
+// OperatorEnabled is whether the lexer reads the token type t, which is
+// true for everything but the operators given to DisableOperator
+func (d *Dialect) OperatorEnabled(t TokenType) bool {
+	return !d.disabled[t]
+}
+
+// LookupIdent works the same as LookupIndent but uses the keywords of the
+// dialect
+func (d *Dialect) LookupIdent(ident string) TokenType {
+	if tok, ok := d.keywords[ident]; ok {
+		return tok
+	}
+	return IDENT
+}
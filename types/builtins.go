@@ -0,0 +1,80 @@
+package types
+
+// Builtins gives the types of the builtins the evaluator defines, to pass to
+// Check. The ones that take different numbers or kinds of arguments, like
+// len and puts, are a bare type variable, so any call to them is fine
+func Builtins() map[string]Type {
+	// fn is the type of a function from params to result, and generic gives
+	// f two type variables of its own
+	fn := func(result Type, params ...Type) Type {
+		return &Function{Params: params, Result: result}
+	}
+	generic := func(f func(a, b Type) Type) Type {
+		return f(NewVar(), NewVar())
+	}
+	stringArray := &Array{Elem: String}
+
+	return map[string]Type{
+		"len":  NewVar(),
+		"puts": NewVar(),
+
+		"split":       fn(stringArray, String, String),
+		"join":        fn(String, stringArray, String),
+		"trim":        fn(String, String),
+		"upper":       fn(String, String),
+		"lower":       fn(String, String),
+		"contains":    fn(Bool, String, String),
+		"starts_with": fn(Bool, String, String),
+		"ends_with":   fn(Bool, String, String),
+		"replace":     fn(String, String, String, String),
+		"index_of":    fn(Int, String, String),
+		"repeat":      fn(String, String, Int),
+		"format":      NewVar(),
+
+		"map": generic(func(a, b Type) Type {
+			return fn(&Array{Elem: b}, &Array{Elem: a}, fn(b, a))
+		}),
+		// filter, any and all only need the function to give something
+		// truthy, so it can give anything
+		"filter": generic(func(a, b Type) Type {
+			return fn(&Array{Elem: a}, &Array{Elem: a}, fn(b, a))
+		}),
+		"reduce": generic(func(a, b Type) Type {
+			return fn(b, &Array{Elem: a}, b, fn(b, b, a))
+		}),
+		"sort": NewVar(),
+		"reverse": generic(func(a, _ Type) Type {
+			return fn(&Array{Elem: a}, &Array{Elem: a})
+		}),
+		// the pairs zip makes have two types in them
+		"zip":   NewVar(),
+		"range": NewVar(),
+		"first": generic(func(a, _ Type) Type {
+			return fn(a, &Array{Elem: a})
+		}),
+		"rest": generic(func(a, _ Type) Type {
+			return fn(&Array{Elem: a}, &Array{Elem: a})
+		}),
+		"push": generic(func(a, _ Type) Type {
+			return fn(&Array{Elem: a}, &Array{Elem: a}, a)
+		}),
+		"concat": NewVar(),
+		"any": generic(func(a, b Type) Type {
+			return fn(Bool, &Array{Elem: a}, fn(b, a))
+		}),
+		"all": generic(func(a, b Type) Type {
+			return fn(Bool, &Array{Elem: a}, fn(b, a))
+		}),
+
+		// what JSON gives back can be anything
+		"json_parse":     generic(func(a, _ Type) Type { return fn(a, String) }),
+		"json_stringify": NewVar(),
+
+		"read_file":  fn(String, String),
+		"write_file": fn(Null, String, String),
+		"list_dir":   fn(stringArray, String),
+		"read_line":  fn(String),
+		"print":      NewVar(),
+		"println":    NewVar(),
+	}
+}
@@ -0,0 +1,634 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"monkey/ast"
+	"monkey/format"
+	"monkey/resolver"
+	"monkey/token"
+)
+
+// The types package infers the type of everything in a program with
+// Hindley–Milner type inference, without running it. Lets are generalised, so
+//
+//	let id = fn(x) { x };
+//
+// has the type fn('a) -> 'a and can be used with any type, while function
+// parameters have one type each time the function is called.
+//
+// Monkey wasn't designed with types in mind, so a few things are looser than
+// in most typed languages: + works on two ints or two strings, an if
+// condition can be any type, == compares any two values of the same type
+// and an if without an else has the type null.
+//
+// Lets and functions can be annotated with types, as in
+//
+//	let add = fn(a: int, b: int) -> int { a + b };
+//
+// and the annotations are checked against what is inferred. Anything left
+// without one is inferred as before, so annotations can be added a bit at a
+// time.
+//
+// Names are looked up with the resolver, so a program that uses an undefined
+// name still gets checked, with the undefined name able to be any type. The
+// resolver's own errors aren't repeated in Info.Errors
+
+// Info is what Check found out about a program
+type Info struct {
+	// Types holds the type of every expression
+	Types map[ast.Expression]Type
+	// Defs holds the type of every identifier that declares a name
+	Defs map[*ast.Identifier]Type
+	// Errors is sorted by position
+	Errors []Error
+}
+
+type Error struct {
+	Pos     token.Position
+	End     token.Position
+	Message string
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Message)
+}
+
+// Check infers the types in program. builtins gives the types of names
+// that are defined without being declared, type variables made with NewVar
+// in them are generic
+func Check(program *ast.Program, builtins map[string]Type) *Info {
+	names := []string{}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c := &checker{
+		info: &Info{
+			Types:  map[ast.Expression]Type{},
+			Defs:   map[*ast.Identifier]Type{},
+			Errors: []Error{},
+		},
+		names:       resolver.Resolve(program, names...),
+		decls:       map[*resolver.Declaration]Type{},
+		scopeLevels: map[*resolver.Scope]int{},
+		typing:      map[*resolver.Declaration]int{},
+	}
+	for _, d := range c.names.Universe.Declarations {
+		c.decls[d] = builtins[d.Name]
+	}
+
+	c.results = append(c.results, c.newVar())
+	c.statements(program, program.Statements)
+
+	sort.SliceStable(c.info.Errors, func(i, j int) bool {
+		return c.info.Errors[i].Pos.Offset < c.info.Errors[j].Pos.Offset
+	})
+	return c.info
+}
+
+type checker struct {
+	info  *Info
+	names *resolver.Info
+
+	// the type of every declaration that has been reached, or been used
+	// before it was reached
+	decls map[*resolver.Declaration]Type
+	// the level lets in each scope are checked at, and the level of each let
+	// whose value is being checked
+	scopeLevels map[*resolver.Scope]int
+	typing      map[*resolver.Declaration]int
+
+	level int
+	// the result type of each function we are inside, innermost last
+	results []Type
+}
+
+func (c *checker) newVar() *Var {
+	return &Var{level: c.level}
+}
+
+func (c *checker) errorf(node ast.Node, format string, args ...any) {
+	start, end, _ := ast.Span(node)
+	c.info.Errors = append(c.info.Errors, Error{Pos: start, End: end, Message: fmt.Sprintf(format, args...)})
+}
+
+// expect reports an error at node if its type t can't be made the same as
+// the type it should have
+func (c *checker) expect(node ast.Node, t, expected Type) {
+	if ok, why := unify(t, expected); !ok {
+		c.errorf(node, "%s has type %s, expected %s%s", describe(node), TypeString(t), TypeString(expected), because(why))
+	}
+}
+
+// describe gives node as it would be written, cut short if it runs over
+// more than one line
+func describe(node ast.Node) string {
+	s := format.Node(node)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " ..."
+	}
+	return s
+}
+
+// statements checks the statements of the scope belonging to node, giving
+// the type of the last one which is the value of the block
+func (c *checker) statements(node ast.Node, stmts []ast.Statement) Type {
+	if scope, ok := c.names.Scopes[node]; ok {
+		c.scopeLevels[scope] = c.level
+	}
+
+	var t Type = Null
+	for _, s := range stmts {
+		t = c.statement(s)
+	}
+	return t
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(s)
+		return Null
+
+	case *ast.ReturnStatement:
+		if s.ReturnValue != nil {
+			c.expect(s.ReturnValue, c.expr(s.ReturnValue), c.results[len(c.results)-1])
+		}
+		// nothing after a return runs, so the statement fits any type
+		return c.newVar()
+
+	case *ast.ExpressionStatement:
+		if s.Expression == nil {
+			return Null
+		}
+		return c.expr(s.Expression)
+
+	case *ast.BlockStatement:
+		return c.statements(s, s.Statements)
+	}
+
+	return Null
+}
+
+func (c *checker) let(s *ast.LetStatement) {
+	d := c.names.Defs[s.Name]
+
+	// the value is checked one level deeper, so that the variables made for
+	// it can be told apart from ones already in use when generalising
+	c.typing[d] = c.level
+	c.level++
+	var t Type = c.newVar()
+	if s.Value != nil {
+		t = c.expr(s.Value)
+	}
+	if s.Type != nil {
+		annotated := c.annotation(s.Type)
+		if s.Value != nil {
+			c.expect(s.Value, t, annotated)
+		}
+		t = annotated
+	}
+	c.level--
+	delete(c.typing, d)
+
+	// the let was used before we got here, by itself or by a function
+	// declared before it
+	if used, ok := c.decls[d]; ok && s.Value != nil {
+		c.expect(s.Value, t, used)
+	}
+
+	c.generalise(t)
+	c.decls[d] = t
+	c.info.Defs[s.Name] = t
+}
+
+func (c *checker) expr(exp ast.Expression) Type {
+	t := c.infer(exp)
+	c.info.Types[exp] = t
+	return t
+}
+
+func (c *checker) infer(exp ast.Expression) Type {
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		return c.identifier(e)
+
+	case *ast.IntegerLiteral:
+		return Int
+
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.StringLiteral:
+		return String
+
+	case *ast.InterpolatedString:
+		// anything can go in a string
+		for _, part := range e.Expressions {
+			if part != nil {
+				c.expr(part)
+			}
+		}
+		return String
+
+	case *ast.PrefixExpression:
+		if e.Right == nil {
+			return c.newVar()
+		}
+		right := c.expr(e.Right)
+		if e.Operator == "-" {
+			c.expect(e.Right, right, Int)
+			return Int
+		}
+		return Bool
+
+	case *ast.InfixExpression:
+		return c.infix(e)
+
+	case *ast.IfExpression:
+		if e.Condition != nil {
+			c.expr(e.Condition)
+		}
+		consequence := c.block(e.Consequence)
+		if e.Alternative == nil {
+			return Null
+		}
+		alternative := c.block(e.Alternative)
+		if ok, why := unify(alternative, consequence); !ok {
+			c.errorf(e.Alternative, "else branch has type %s, expected %s like the if branch%s",
+				TypeString(alternative), TypeString(consequence), because(why))
+		}
+		return consequence
+
+	case *ast.FunctionLiteral:
+		return c.function(e)
+
+	case *ast.CallExpression:
+		return c.call(e)
+
+	case *ast.ArrayLiteral:
+		elem := c.newVar()
+		for _, el := range e.Elements {
+			if el != nil {
+				c.expect(el, c.expr(el), elem)
+			}
+		}
+		return &Array{Elem: elem}
+
+	case *ast.IndexExpression:
+		elem := c.newVar()
+		if e.Left != nil {
+			c.expect(e.Left, c.expr(e.Left), &Array{Elem: elem})
+		}
+		if e.Index != nil {
+			c.expect(e.Index, c.expr(e.Index), Int)
+		}
+		return elem
+	}
+
+	return c.newVar()
+}
+
+func (c *checker) block(b *ast.BlockStatement) Type {
+	if b == nil {
+		return c.newVar()
+	}
+	return c.statements(b, b.Statements)
+}
+
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	d := c.names.Uses[ident]
+	if d == nil {
+		// the resolver has already reported it
+		return c.newVar()
+	}
+
+	t, ok := c.decls[d]
+	if !ok || t == nil {
+		// a let used before it has been checked, either by itself or by a
+		// function that runs later. It has one type until it is reached, and
+		// when used by something else it can't be generalised at all
+		level := c.scopeLevels[d.Scope]
+		if l, ok := c.typing[d]; ok {
+			level = l + 1
+		}
+		v := &Var{level: level}
+		c.decls[d] = v
+		return v
+	}
+
+	return c.instantiate(t)
+}
+
+// operandTypes is the type both sides of each operator have, apart from +
+// and the equality operators
+var operandTypes = map[string]Type{
+	"-": Int,
+	"*": Int,
+	"/": Int,
+	"<": Int,
+	">": Int,
+}
+
+func (c *checker) infix(e *ast.InfixExpression) Type {
+	if e.Left == nil || e.Right == nil {
+		return c.newVar()
+	}
+	left := c.expr(e.Left)
+	right := c.expr(e.Right)
+
+	switch e.Operator {
+	case "==", "!=":
+		c.expect(e.Right, right, left)
+		return Bool
+
+	case "+":
+		// + adds ints or joins strings, so we go by whichever side is
+		// already known to be a string
+		if prune(left) == String || prune(right) == String {
+			c.expect(e.Left, left, String)
+			c.expect(e.Right, right, String)
+			return String
+		}
+		c.expect(e.Left, left, Int)
+		c.expect(e.Right, right, Int)
+		return Int
+	}
+
+	operand, ok := operandTypes[e.Operator]
+	if !ok {
+		return c.newVar()
+	}
+	c.expect(e.Left, left, operand)
+	c.expect(e.Right, right, operand)
+	if e.Operator == "<" || e.Operator == ">" {
+		return Bool
+	}
+	return operand
+}
+
+func (c *checker) function(fn *ast.FunctionLiteral) Type {
+	t := &Function{Params: []Type{}, Result: c.newVar()}
+
+	if fn.ReturnType != nil {
+		t.Result = c.annotation(fn.ReturnType)
+	}
+
+	for i, p := range fn.Parameters {
+		var v Type = c.newVar()
+		if annotation := fn.ParameterType(i); annotation != nil {
+			v = c.annotation(annotation)
+		}
+		t.Params = append(t.Params, v)
+		if d, ok := c.names.Defs[p]; ok {
+			c.decls[d] = v
+		}
+		c.info.Defs[p] = v
+	}
+
+	c.results = append(c.results, t.Result)
+	var body Type = Null
+	if fn.Body != nil {
+		body = c.statements(fn, fn.Body.Statements)
+	}
+	c.results = c.results[:len(c.results)-1]
+
+	if ok, why := unify(body, t.Result); !ok {
+		var last ast.Node = fn
+		if fn.Body != nil && len(fn.Body.Statements) > 0 {
+			last = fn.Body.Statements[len(fn.Body.Statements)-1]
+		}
+		elsewhere := "returns %s elsewhere"
+		if fn.ReturnType != nil {
+			elsewhere = "is declared to return %s"
+		}
+		c.errorf(last, "function gives %s here but "+elsewhere+"%s",
+			TypeString(body), TypeString(t.Result), because(why))
+	}
+	return t
+}
+
+// namedTypes are the types that can be written as a name in an annotation
+var namedTypes = map[string]Type{
+	"int":    Int,
+	"bool":   Bool,
+	"string": String,
+	"null":   Null,
+}
+
+// annotation gives the type written in a type annotation. Names it doesn't
+// know are reported and can be any type
+func (c *checker) annotation(te ast.TypeExpr) Type {
+	switch te := te.(type) {
+	case *ast.NamedType:
+		if t, ok := namedTypes[te.Name]; ok {
+			return t
+		}
+		c.errorf(te, "unknown type %s", te.Name)
+
+	case *ast.ArrayType:
+		return &Array{Elem: c.annotation(te.Element)}
+
+	case *ast.HashType:
+		return &Hash{Key: c.annotation(te.Key), Value: c.annotation(te.Value)}
+
+	case *ast.FunctionType:
+		f := &Function{Params: []Type{}, Result: c.annotation(te.Result)}
+		for _, p := range te.Parameters {
+			f.Params = append(f.Params, c.annotation(p))
+		}
+		return f
+	}
+
+	return c.newVar()
+}
+
+func (c *checker) call(e *ast.CallExpression) Type {
+	if e.Function == nil {
+		return c.newVar()
+	}
+	function := c.expr(e.Function)
+
+	args := []Type{}
+	for _, a := range e.Arguments {
+		if a == nil {
+			args = append(args, c.newVar())
+			continue
+		}
+		args = append(args, c.expr(a))
+	}
+
+	// when we already know it is a function each argument can be checked on
+	// its own, which gives better messages
+	if f, ok := prune(function).(*Function); ok {
+		if len(f.Params) != len(args) {
+			c.errorf(e, "wrong number of arguments to %s: want %d, got %d",
+				describe(e.Function), len(f.Params), len(args))
+			return f.Result
+		}
+		for i, a := range e.Arguments {
+			if a != nil {
+				c.expect(a, args[i], f.Params[i])
+			}
+		}
+		return f.Result
+	}
+
+	result := c.newVar()
+	c.expect(e.Function, function, &Function{Params: args, Result: result})
+	return result
+}
+
+// generalise makes the variables in t that were made while checking a let's
+// value generic, as nothing outside the value can refer to them
+func (c *checker) generalise(t Type) {
+	switch t := prune(t).(type) {
+	case *Array:
+		c.generalise(t.Elem)
+	case *Hash:
+		c.generalise(t.Key)
+		c.generalise(t.Value)
+	case *Function:
+		for _, p := range t.Params {
+			c.generalise(p)
+		}
+		c.generalise(t.Result)
+	case *Var:
+		if t.level > c.level {
+			t.level = generic
+		}
+	}
+}
+
+// instantiate copies t with fresh variables in place of the generic ones
+func (c *checker) instantiate(t Type) Type {
+	fresh := map[*Var]*Var{}
+
+	var copyType func(t Type) Type
+	copyType = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Array:
+			return &Array{Elem: copyType(t.Elem)}
+		case *Hash:
+			return &Hash{Key: copyType(t.Key), Value: copyType(t.Value)}
+		case *Function:
+			params := make([]Type, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = copyType(p)
+			}
+			return &Function{Params: params, Result: copyType(t.Result)}
+		case *Var:
+			if t.level != generic {
+				return t
+			}
+			if _, ok := fresh[t]; !ok {
+				fresh[t] = c.newVar()
+			}
+			return fresh[t]
+		default:
+			return t
+		}
+	}
+
+	return copyType(t)
+}
+
+// unify makes a and b the same type by binding variables in them. If they
+// can't be made the same it gives the reason when there is more to say than
+// that the types are different
+func unify(a, b Type) (bool, string) {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return true, ""
+	}
+
+	if v, ok := a.(*Var); ok {
+		return bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return bind(v, a)
+	}
+
+	switch a := a.(type) {
+	case *Basic:
+		if b, ok := b.(*Basic); ok && a.Name == b.Name {
+			return true, ""
+		}
+
+	case *Array:
+		if b, ok := b.(*Array); ok {
+			return unify(a.Elem, b.Elem)
+		}
+
+	case *Hash:
+		if b, ok := b.(*Hash); ok {
+			if ok, why := unify(a.Key, b.Key); !ok {
+				return false, why
+			}
+			return unify(a.Value, b.Value)
+		}
+
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok {
+			break
+		}
+		if len(a.Params) != len(b.Params) {
+			return false, fmt.Sprintf("%d parameters and %d", len(a.Params), len(b.Params))
+		}
+		for i := range a.Params {
+			if ok, why := unify(a.Params[i], b.Params[i]); !ok {
+				return false, why
+			}
+		}
+		return unify(a.Result, b.Result)
+	}
+
+	return false, ""
+}
+
+// because puts the reason from unify in brackets for the end of a message
+func because(why string) string {
+	if why == "" {
+		return ""
+	}
+	return " (" + why + ")"
+}
+
+func bind(v *Var, t Type) (bool, string) {
+	if occurs(v, t) {
+		return false, "this would make an infinite type"
+	}
+	v.bound = t
+	return true, ""
+}
+
+// occurs checks whether v appears in t, and brings any variables in t down to
+// v's level so t is no more general than v was
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		if t == v {
+			return true
+		}
+		if t.level > v.level {
+			t.level = v.level
+		}
+	case *Array:
+		return occurs(v, t.Elem)
+	case *Hash:
+		return occurs(v, t.Key) || occurs(v, t.Value)
+	case *Function:
+		for _, p := range t.Params {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Result)
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package types
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/parser"
+)
+
+func check(t *testing.T, input string, builtins map[string]Type) (*ast.Program, *Info) {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+	return program, Check(program, builtins)
+}
+
+// lastType is the type of the last statement, or of the name declared by it
+func lastType(program *ast.Program, info *Info) string {
+	switch s := program.Statements[len(program.Statements)-1].(type) {
+	case *ast.LetStatement:
+		return TypeString(info.Defs[s.Name])
+	case *ast.ExpressionStatement:
+		return TypeString(info.Types[s.Expression])
+	}
+	return ""
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5", "int"},
+		{"true", "bool"},
+		{`"a" + "b"`, "string"},
+		{`"${1} and ${true}"`, "string"},
+		{"1 + 2 * 3", "int"},
+		{"1 < 2 == true", "bool"},
+		{"!5", "bool"},
+		{"[1, 2, 3]", "[int]"},
+		{"[]", "['a]"},
+		{"[[1], []]", "[[int]]"},
+		{"[1, 2][0]", "int"},
+		{"if (true) { 1 } else { 2 }", "int"},
+		{"if (true) { 1 }", "null"},
+		{"let x = 5;", "int"},
+		{"let id = fn(x) { x };", "fn('a) -> 'a"},
+		{"let add = fn(a, b) { a + b };", "fn(int, int) -> int"},
+		{"let join = fn(a, b) { a + \"\" + b };", "fn(string, string) -> string"},
+		{"let apply = fn(f, x) { f(x) };", "fn(fn('a) -> 'b, 'a) -> 'b"},
+		{"let compose = fn(f, g) { fn(x) { g(f(x)) } };", "fn(fn('a) -> 'b, fn('b) -> 'c) -> fn('a) -> 'c"},
+		{"let first = fn(xs) { xs[0] };", "fn(['a]) -> 'a"},
+		{"let f = fn() {};", "fn() -> null"},
+		// let-polymorphism: id is used at two types
+		{"let id = fn(x) { x }; let pair = [id(1), id(2)]; id(true)", "bool"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };", "fn(int) -> int"},
+		{"let max = fn(a, b) { if (a > b) { return a; } return b; };", "fn(int, int) -> int"},
+		{`let f = fn(x) { if (x) { return "big"; } else { return "small"; } };`, "fn('a) -> string"},
+		// functions can use lets that come after them
+		{`
+			let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+			let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		`, "fn(int) -> bool"},
+		{"let x = 1; let f = fn() { let x = true; x }; f()", "bool"},
+	}
+
+	for _, tt := range tests {
+		program, info := check(t, tt.input, nil)
+		if len(info.Errors) != 0 {
+			t.Errorf("input %q: unexpected errors %v", tt.input, info.Errors)
+			continue
+		}
+		if got := lastType(program, info); got != tt.expected {
+			t.Errorf("input %q: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + true", []string{"1:5: true has type bool, expected int"}},
+		{`"a" + 1`, []string{"1:7: 1 has type int, expected string"}},
+		{"-true", []string{"1:2: true has type bool, expected int"}},
+		{"1 == true", []string{"1:6: true has type bool, expected int"}},
+		{"[1, true]", []string{"1:5: true has type bool, expected int"}},
+		{"let xs = [1]; xs[true]", []string{"1:18: true has type bool, expected int"}},
+		{"5[0]", []string{"1:1: 5 has type int, expected ['a]"}},
+		{"if (true) { 1 } else { false }", []string{
+			"1:22: else branch has type bool, expected int like the if branch",
+		}},
+		{"let add = fn(a, b) { a + b }; add(1)", []string{"1:31: wrong number of arguments to add: want 2, got 1"}},
+		{"let add = fn(a, b) { a + b }; add(1, true)", []string{"1:38: true has type bool, expected int"}},
+		{"5(1)", []string{"1:1: 5 has type int, expected fn(int) -> 'a"}},
+		{"let f = fn(x) { x(x) };", []string{
+			"1:17: x has type 'a, expected fn('a) -> 'b (this would make an infinite type)",
+		}},
+		// parameters only have one type inside the function
+		{"let f = fn(g) { g(1); g(true) };", []string{"1:25: true has type bool, expected int"}},
+		{"let f = fn(x) { if (x) { return 1; }; true };", []string{
+			"1:39: function gives bool here but returns int elsewhere",
+		}},
+		{"let f = fn(a) { a + 1 }; let g = fn() { f(\"s\") };", []string{
+			`1:43: "s" has type string, expected int`,
+		}},
+		{"1 + true; 2 + false;", []string{
+			"1:5: true has type bool, expected int",
+			"1:15: false has type bool, expected int",
+		}},
+	}
+
+	for _, tt := range tests {
+		_, info := check(t, tt.input, nil)
+
+		got := []string{}
+		for _, e := range info.Errors {
+			got = append(got, e.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("input %q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		{"let x: int = 5;", "int", nil},
+		{"let xs: [string] = [];", "[string]", nil},
+		{"let id = fn(x: int) { x };", "fn(int) -> int", nil},
+		{"let f = fn(x) -> bool { x };", "fn(bool) -> bool", nil},
+		{"let apply = fn(f: fn(int) -> string, x) { f(x) };", "fn(fn(int) -> string, int) -> string", nil},
+		{"let f: fn(int) -> int = fn(x) { x };", "fn(int) -> int", nil},
+		{"let h: {string: int} = h;", "{string: int}", nil},
+		{"let x: int = true;", "int", []string{"1:14: true has type bool, expected int"}},
+		{"let f = fn(x: string) { x + 1 };", "fn(string) -> string", []string{
+			"1:29: 1 has type int, expected string",
+		}},
+		{"let f = fn(x) -> int { if (x) { return 1; }; true };", "fn('a) -> int", []string{
+			"1:46: function gives bool here but is declared to return int",
+		}},
+		{"let f: fn(int) -> int = fn(a, b) { a };", "fn(int) -> int", []string{
+			"1:25: fn(a, b) { ... has type fn('a, 'b) -> 'a, expected fn(int) -> int (2 parameters and 1)",
+		}},
+		{"let x: float = 1;", "int", []string{"1:8: unknown type float"}},
+	}
+
+	for _, tt := range tests {
+		program, info := check(t, tt.input, nil)
+
+		got := []string{}
+		for _, e := range info.Errors {
+			got = append(got, e.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("input %q: wrong errors.\nexpected=%q\ngot=%q", tt.input, tt.errors, got)
+		}
+		if got := lastType(program, info); got != tt.expected {
+			t.Errorf("input %q: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	a := NewVar()
+	builtins := map[string]Type{
+		"len":   &Function{Params: []Type{&Array{Elem: a}}, Result: Int},
+		"puts":  &Function{Params: []Type{NewVar()}, Result: Null},
+		"first": &Function{Params: []Type{&Array{Elem: a}}, Result: a},
+	}
+
+	program, info := check(t, `len([1, 2]); len(["a"]); puts(1); puts("a"); first([true])`, builtins)
+	if len(info.Errors) != 0 {
+		t.Fatalf("unexpected errors %v", info.Errors)
+	}
+	if got := lastType(program, info); got != "bool" {
+		t.Errorf("first([true]) should be bool. got=%s", got)
+	}
+
+	_, info = check(t, `len(5)`, builtins)
+	if len(info.Errors) != 1 || info.Errors[0].Message != "5 has type int, expected ['a]" {
+		t.Errorf("expected an error for len(5). got=%v", info.Errors)
+	}
+}
+
+func TestStandardBuiltins(t *testing.T) {
+	// every builtin the evaluator has needs a type
+	names := slices.Sorted(maps.Keys(Builtins()))
+	if !reflect.DeepEqual(names, evaluator.BuiltinNames()) {
+		t.Errorf("builtins don't match the evaluator's.\nexpected=%q\ngot=%q", evaluator.BuiltinNames(), names)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`split("a b", " ")`, "[string]"},
+		{`map([1, 2], fn(x) { "${x}" })`, "[string]"},
+		{`filter(["a"], fn(x) { len(x) })`, "[string]"},
+		{`reduce([1, 2], "", fn(acc, x) { acc + "${x}" })`, "string"},
+		{`first(rest(push([true], false)))`, "bool"},
+		{`any([1], fn(x) { x > 0 })`, "bool"},
+		{`len("a") + len([1]) + len(json_parse("{}"))`, "int"},
+		{`puts(1, "a"); format("%d", 1)`, "'a"},
+	}
+	for _, tt := range tests {
+		program, info := check(t, tt.input, Builtins())
+		if len(info.Errors) != 0 {
+			t.Errorf("%q: unexpected errors %v", tt.input, info.Errors)
+			continue
+		}
+		if got := lastType(program, info); got != tt.expected {
+			t.Errorf("%q: expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+
+	_, info := check(t, `map([1], fn(x) { x }, 2); upper(1)`, Builtins())
+	if len(info.Errors) != 2 {
+		t.Errorf("expected errors for the wrong arguments. got=%v", info.Errors)
+	}
+}
+
+func TestUndefinedNames(t *testing.T) {
+	// the resolver reports the name, the checker carries on without it
+	_, info := check(t, "let x = y + 1; x + true", nil)
+	if len(info.Errors) != 1 || info.Errors[0].String() != "1:20: true has type bool, expected int" {
+		t.Errorf("wrong errors. got=%v", info.Errors)
+	}
+}
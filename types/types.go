@@ -0,0 +1,143 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a Monkey value. Types are built from Basic types,
+// arrays, hashes, functions and type variables, which inference fills in
+type Type interface {
+	typ()
+}
+
+type Basic struct {
+	Name string
+}
+
+var (
+	Int    = &Basic{Name: "int"}
+	Bool   = &Basic{Name: "bool"}
+	String = &Basic{Name: "string"}
+	// Null is the type of an if without an else and of blocks that don't end
+	// in an expression
+	Null = &Basic{Name: "null"}
+)
+
+type Array struct {
+	Elem Type
+}
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+type Function struct {
+	Params []Type
+	Result Type
+}
+
+// Var is a type that isn't known yet. Once inference finds out what it is
+// it is bound to that type, and until then it can be unified with anything
+//
+// level is how many lets deep the variable was made, which is how
+// generalisation tells the variables that belong to a let's value from the
+// ones that are shared with code outside it
+type Var struct {
+	bound Type
+	level int
+}
+
+// generic is the level of the variables in a generalised type, each use of
+// the type gets its own fresh copy of them
+const generic = int(^uint(0) >> 1)
+
+// NewVar returns a type variable, for use in the types of builtins where
+// it makes the builtin generic, like fn('a) -> 'a
+func NewVar() *Var {
+	return &Var{level: generic}
+}
+
+func (*Basic) typ()    {}
+func (*Array) typ()    {}
+func (*Hash) typ()     {}
+func (*Function) typ() {}
+func (*Var) typ()      {}
+
+func (b *Basic) String() string    { return TypeString(b) }
+func (a *Array) String() string    { return TypeString(a) }
+func (h *Hash) String() string     { return TypeString(h) }
+func (f *Function) String() string { return TypeString(f) }
+func (v *Var) String() string      { return TypeString(v) }
+
+// prune follows bound variables to the type they stand for
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.bound == nil {
+			return t
+		}
+		t = v.bound
+	}
+}
+
+// TypeString writes a type out like [int], {string: int} or
+// fn('a, int) -> 'a, the same way they are written in annotations. The
+// variables are named in the order they appear
+func TypeString(t Type) string {
+	names := map[*Var]string{}
+	var out strings.Builder
+	writeType(&out, t, names)
+	return out.String()
+}
+
+func writeType(out *strings.Builder, t Type, names map[*Var]string) {
+	switch t := prune(t).(type) {
+	case *Basic:
+		out.WriteString(t.Name)
+
+	case *Array:
+		out.WriteString("[")
+		writeType(out, t.Elem, names)
+		out.WriteString("]")
+
+	case *Hash:
+		out.WriteString("{")
+		writeType(out, t.Key, names)
+		out.WriteString(": ")
+		writeType(out, t.Value, names)
+		out.WriteString("}")
+
+	case *Function:
+		out.WriteString("fn(")
+		for i, p := range t.Params {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			writeType(out, p, names)
+		}
+		out.WriteString(") -> ")
+		writeType(out, t.Result, names)
+
+	case *Var:
+		name, ok := names[t]
+		if !ok {
+			name = varName(len(names))
+			names[t] = name
+		}
+		out.WriteString(name)
+
+	default:
+		fmt.Fprintf(out, "%T", t)
+	}
+}
+
+// varName gives 'a to 'z, then 'a1 and so on
+func varName(i int) string {
+	name := "'" + string(rune('a'+i%26))
+	if i >= 26 {
+		name += fmt.Sprint(i / 26)
+	}
+	return name
+}
@@ -0,0 +1,205 @@
+package monkey
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+// Value is a value from a program
+type Value struct {
+	// obj is nil for the zero Value, which is null
+	obj object.Object
+}
+
+func (v Value) object() object.Object {
+	if v.obj == nil {
+		return evaluator.NULL
+	}
+	return v.obj
+}
+
+// Type gives the kind of value it is, like INTEGER or STRING
+func (v Value) Type() string {
+	return string(v.object().Type())
+}
+
+// String gives the value the way puts would print it
+func (v Value) String() string {
+	return v.object().Inspect()
+}
+
+// Interface converts the value to Go. Integers become int64, floats
+// float64, booleans bool, strings string, null nil and arrays []any. Hashes
+// become map[string]any when all their keys are strings and map[any]any
+// when they aren't.
+// Functions stay a Value, which can be given back to the VM
+func (v Value) Interface() any {
+	return toGo(v.object())
+}
+
+func toGo(obj object.Object) any {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Array:
+		elements := make([]any, len(obj.Elements))
+		for i, e := range obj.Elements {
+			elements[i] = toGo(e)
+		}
+		return elements
+	case *object.Hash:
+		return hashToGo(obj)
+	}
+	return Value{obj: obj}
+}
+
+func hashToGo(hash *object.Hash) any {
+	strings := map[string]any{}
+	for _, pair := range hash.Pairs {
+		s, ok := pair.Key.(*object.String)
+		if !ok {
+			break
+		}
+		strings[s.Value] = toGo(pair.Value)
+	}
+	if len(strings) == len(hash.Pairs) {
+		return strings
+	}
+
+	m := map[any]any{}
+	for _, pair := range hash.Pairs {
+		m[toGo(pair.Key)] = toGo(pair.Value)
+	}
+	return m
+}
+
+// toObject converts a Go value to Monkey, see VM.Set for which values can be
+func toObject(v any) (object.Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case Value:
+		return v.object(), nil
+	}
+	obj, _, err := reflectToObject(reflect.ValueOf(v))
+	return obj, err
+}
+
+// reflectToObject converts rv, also giving how many bytes the values it
+// made take, as the allocation limit counts them
+func reflectToObject(rv reflect.Value) (object.Object, int, error) {
+	var size int
+	obj, err := convert(rv, map[visit]bool{}, &size)
+	return obj, size, err
+}
+
+// visit is a map, slice or pointer being converted. They are kept in seen
+// while what they contain is converted, to find ones that contain
+// themselves
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// convert converts rv, adding how many bytes the values it makes take to
+// size. Values rv already has from the VM aren't counted
+func convert(rv reflect.Value, seen map[visit]bool, size *int) (object.Object, error) {
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer:
+		if rv.IsNil() {
+			break
+		}
+		v := visit{ptr: rv.Pointer(), typ: rv.Type()}
+		if rv.Kind() == reflect.Slice {
+			v.len = rv.Len()
+		}
+		if seen[v] {
+			return nil, fmt.Errorf("can't convert a %s that contains itself", rv.Type())
+		}
+		seen[v] = true
+		defer delete(seen, v)
+	}
+
+	if rv.Type() == reflect.TypeFor[Value]() {
+		return rv.Interface().(Value).object(), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d is too big for an integer", rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: rv.Float()}, nil
+
+	case reflect.String:
+		*size += rv.Len()
+		return &object.String{Value: rv.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, rv.Len())
+		for i := range elements {
+			e, err := convert(rv.Index(i), seen, size)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = e
+		}
+		array := &object.Array{Elements: elements}
+		*size += evaluator.ShallowSize(array)
+		return array, nil
+
+	case reflect.Map:
+		hash := object.NewHash()
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := convert(iter.Key(), seen, size)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("can't use %s as a hash key", iter.Key().Type())
+			}
+			val, err := convert(iter.Value(), seen, size)
+			if err != nil {
+				return nil, fmt.Errorf("value for %s: %w", key.Inspect(), err)
+			}
+			hash.Set(key, val)
+		}
+		*size += evaluator.ShallowSize(hash)
+		return hash, nil
+
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return convert(rv.Elem(), seen, size)
+	}
+
+	return nil, fmt.Errorf("can't convert %s to a Monkey value", rv.Type())
+}
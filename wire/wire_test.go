@@ -0,0 +1,61 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"a":1}`, `{}`, `"é"`} {
+		if err := WriteMessage(&buf, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(&buf)
+	for _, expected := range []string{`{"a":1}`, `{}`, `"é"`} {
+		body, err := ReadMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != expected {
+			t.Errorf("expected=%q, got=%q", expected, body)
+		}
+	}
+	if _, err := ReadMessage(r); err != io.EOF {
+		t.Errorf("expected io.EOF at the end. got=%v", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{"Content-Length: 2\r\nContent-Type: application/json\r\n\r\n{}", "{}", ""},
+		{"content-length:2\n\n{}", "{}", ""},
+		{"Content-Type: x\r\n\r\n{}", "", "message has no Content-Length"},
+		{"Content-Length: x\r\n\r\n{}", "", `bad Content-Length " x"`},
+		{"Content-Length: 5\r\n\r\n{}", "", "reading message: unexpected EOF"},
+		{"oops\r\n\r\n", "", `bad header "oops"`},
+		{"Content-Length: 2\r\n", "", "reading header: EOF"},
+	}
+
+	for _, tt := range tests {
+		body, err := ReadMessage(bufio.NewReader(strings.NewReader(tt.input)))
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: expected error %q. got=%v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || string(body) != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q, %v", tt.input, tt.expected, body, err)
+		}
+	}
+}